@@ -0,0 +1,141 @@
+// backend/handlers/decode.go
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// decodeJSON decodes a request body into target. When lenient is set, string-encoded booleans and numbers, such as
+// "true" or "3", given for boolean and numeric fields of target at any depth are converted first; otherwise they are
+// rejected like any other type mismatch. Untyped values such as extra are decoded as sent.
+func decodeJSON(body io.Reader, target interface{}, lenient bool) error {
+	if !lenient {
+		return json.NewDecoder(body).Decode(target)
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(coerceValue(raw, reflect.TypeOf(target)))).Decode(target)
+}
+
+// coerceValue converts the string-encoded booleans and numbers in raw that decode into booleans or numbers of
+// valueType, following its structs, pointers, slices, arrays and maps. Values of interface type are left alone, since
+// nothing says whether a string such as "123456789012" is meant as a number. Anything else, including JSON that does
+// not fit valueType, is returned unchanged so decoding reports it.
+func coerceValue(raw json.RawMessage, valueType reflect.Type) json.RawMessage {
+	for valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+
+	switch kind := valueType.Kind(); kind {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return coerceField(raw, kind)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return coerceElements(raw, valueType)
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+			return raw
+		}
+		types := fieldTypes(valueType)
+		for name, value := range fields {
+			if fieldType, ok := lookupField(types, name); ok {
+				fields[name] = coerceValue(value, fieldType)
+			}
+		}
+		return marshalOr(fields, raw)
+	default:
+		return raw
+	}
+}
+
+// coerceElements coerces the elements of a JSON array or the members of a JSON object in raw as values of the element
+// type of collectionType.
+func coerceElements(raw json.RawMessage, collectionType reflect.Type) json.RawMessage {
+	elemType := collectionType.Elem()
+
+	var items []json.RawMessage
+	if collectionType.Kind() != reflect.Map && json.Unmarshal(raw, &items) == nil && items != nil {
+		for i, item := range items {
+			items[i] = coerceValue(item, elemType)
+		}
+		return marshalOr(items, raw)
+	}
+	var members map[string]json.RawMessage
+	if collectionType.Kind() == reflect.Map && json.Unmarshal(raw, &members) == nil && members != nil {
+		for key, member := range members {
+			members[key] = coerceValue(member, elemType)
+		}
+		return marshalOr(members, raw)
+	}
+	return raw
+}
+
+// marshalOr encodes value, falling back to raw should encoding fail
+func marshalOr(value interface{}, raw json.RawMessage) json.RawMessage {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return raw
+	}
+	return encoded
+}
+
+// fieldTypes maps the JSON names of a struct's exported fields to their types
+func fieldTypes(structType reflect.Type) map[string]reflect.Type {
+	types := make(map[string]reflect.Type)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		types[name] = field.Type
+	}
+	return types
+}
+
+// lookupField finds the field a JSON member decodes into the way encoding/json does: by exact name first, then
+// ignoring case.
+func lookupField(types map[string]reflect.Type, name string) (reflect.Type, bool) {
+	if fieldType, ok := types[name]; ok {
+		return fieldType, true
+	}
+	for field, fieldType := range types {
+		if strings.EqualFold(field, name) {
+			return fieldType, true
+		}
+	}
+	return nil, false
+}
+
+// coerceField converts a JSON string holding a boolean or number to that type. Anything else, including strings
+// that do not parse, is returned unchanged so decoding reports it.
+func coerceField(raw json.RawMessage, kind reflect.Kind) json.RawMessage {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return raw
+	}
+	value = strings.TrimSpace(value)
+	if kind == reflect.Bool {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return json.RawMessage(strconv.FormatBool(parsed))
+		}
+		return raw
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) {
+		return json.RawMessage(value)
+	}
+	return raw
+}
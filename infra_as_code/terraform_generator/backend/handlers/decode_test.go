@@ -0,0 +1,96 @@
+// backend/handlers/decode_test.go
+
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCoerceField(t *testing.T) {
+	tests := []struct {
+		raw  string
+		kind reflect.Kind
+		want string
+	}{
+		{`"true"`, reflect.Bool, `true`},
+		{`" False "`, reflect.Bool, `false`},
+		{`"1"`, reflect.Bool, `true`},
+		{`"yes"`, reflect.Bool, `"yes"`},
+		{`true`, reflect.Bool, `true`},
+		{`"3"`, reflect.Int, `3`},
+		{`"-2.5"`, reflect.Float64, `-2.5`},
+		{`"0x10"`, reflect.Int, `"0x10"`},
+		{`"007"`, reflect.Int, `"007"`},
+		{`"NaN"`, reflect.Float64, `"NaN"`},
+		{`[1]`, reflect.Int, `[1]`},
+	}
+	for _, tt := range tests {
+		if got := string(coerceField(json.RawMessage(tt.raw), tt.kind)); got != tt.want {
+			t.Errorf("coerceField(%s, %s) = %s, want %s", tt.raw, tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type limits struct {
+		Replicas int  `json:"replicas"`
+		Enabled  bool `json:"enabled"`
+	}
+	type request struct {
+		Name     string                 `json:"name"`
+		DryRun   bool                   `json:"dry_run"`
+		Limits   *limits                `json:"limits"`
+		Sizes    []float64              `json:"sizes"`
+		Flags    map[string]bool        `json:"flags"`
+		Extra    map[string]interface{} `json:"extra"`
+		Internal bool                   `json:"-"`
+	}
+	const body = `{
+		"name": "3",
+		"Dry_Run": "true",
+		"limits": {"replicas": "2", "ENABLED": "1"},
+		"sizes": ["1.5", 2],
+		"flags": {"a": "false"},
+		"extra": {"count": "4", "on": "TRUE", "id": "007", "tag": "web", "nested": [{"n": "1"}]}
+	}`
+
+	var got request
+	if err := decodeJSON(strings.NewReader(body), &got, true); err != nil {
+		t.Fatalf("decodeJSON() error: %v", err)
+	}
+	want := request{
+		Name:   "3",
+		DryRun: true,
+		Limits: &limits{Replicas: 2, Enabled: true},
+		Sizes:  []float64{1.5, 2},
+		Flags:  map[string]bool{"a": false},
+		Extra: map[string]interface{}{
+			"count": "4", "on": "TRUE", "id": "007", "tag": "web",
+			"nested": []interface{}{map[string]interface{}{"n": "1"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeJSON() = %+v, want %+v", got, want)
+	}
+
+	// Strings that do not parse are still rejected, and nothing is converted without lenient
+	if err := decodeJSON(strings.NewReader(`{"limits": {"enabled": "yes"}}`), &got, true); err == nil {
+		t.Error(`decodeJSON() accepted "yes" for a bool`)
+	}
+	if err := decodeJSON(strings.NewReader(`{"dry_run": "true"}`), &got, false); err == nil {
+		t.Error("decodeJSON() converted a string without lenient")
+	}
+
+	// The generate request decodes its flags whatever their case
+	var req models.GenerateRequest
+	if err := decodeJSON(strings.NewReader(`{"No_Overwrite": "true", "extra": {"account_id": "123456789012"}}`), &req, true); err != nil {
+		t.Fatal(err)
+	}
+	if !req.NoOverwrite || req.Extra["account_id"] != "123456789012" {
+		t.Errorf("decoded request = %+v", req)
+	}
+}
@@ -0,0 +1,49 @@
+// backend/handlers/file_handler.go
+
+package handlers
+
+import (
+	"backend/services"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// GetFileHandler serves a single previously generated file as plain text.
+func GetFileHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	organisation := query.Get("organisation_name")
+	product := query.Get("product_name")
+	customer := query.Get("customer")
+	provider := query.Get("provider") // Only needed when the configured output path uses the provider
+	file := query.Get("file")
+
+	if organisation == "" || product == "" || file == "" {
+		http.Error(w, "organisation_name, product_name, and file are required", http.StatusBadRequest)
+		return
+	}
+
+	path, err := services.GeneratedFilePath(provider, organisation, product, customer, file)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidRequest) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
@@ -6,22 +6,113 @@ import (
 	"backend/models"
 	"backend/services"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
-// GenerateTerraformHandler handles HTTP requests to generate Terraform files.
-func GenerateTerraformHandler(w http.ResponseWriter, r *http.Request) {
-	var req models.GenerateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+// GenerateTerraformHandler handles HTTP requests to generate Terraform files. A lenient handler accepts
+// string-encoded booleans, such as "no_overwrite": "true"; otherwise they are rejected. var.<name>=<value> query
+// parameters override the default of a variable, taking precedence over variable_defaults in the body.
+func GenerateTerraformHandler(lenient bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.GenerateRequest
+		if err := decodeJSON(r.Body, &req, lenient); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := queryVariableDefaults(r.URL.Query(), &req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid query parameters: %v", err), http.StatusBadRequest)
+			return
+		}
+		generate(w, &req)
+	}
+}
+
+// queryVariableDefaults copies var.<name> query parameters into the request's variable defaults.
+func queryVariableDefaults(query url.Values, req *models.GenerateRequest) error {
+	for key, values := range query {
+		name, ok := strings.CutPrefix(key, "var.")
+		if !ok {
+			continue
+		}
+		if name == "" {
+			return fmt.Errorf("%s: missing variable name", key)
+		}
+		if len(values) > 1 {
+			return fmt.Errorf("%s given more than once", key)
+		}
+		if req.VariableDefaults == nil {
+			req.VariableDefaults = map[string]string{}
+		}
+		req.VariableDefaults[name] = values[0]
+	}
+	return nil
+}
+
+// generate runs a decoded request and reports the files written.
+func generate(w http.ResponseWriter, req *models.GenerateRequest) {
+	if len(req.Organisations) > 0 {
+		generateOrganisations(w, req)
 		return
 	}
 
-	if err := services.GenerateTerraform(&req); err != nil {
+	results, err := services.GenerateTerraform(req)
+	if errors.Is(err, services.ErrInvalidRequest) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Terraform code generated successfully"))
+	json.NewEncoder(w).Encode(models.GenerateResponse{
+		Message: "Terraform code generated successfully",
+		Files:   results,
+	})
+}
+
+// generateOrganisations runs a multi-organisation request and reports the files written for each organisation. The
+// response is 207 Multi-Status when only some organisations fail, and an error status when all of them do.
+func generateOrganisations(w http.ResponseWriter, req *models.GenerateRequest) {
+	organisations, err := services.GenerateOrganisations(req)
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidRequest) {
+			status = http.StatusBadRequest
+		}
+		if organisations == nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+
+	response := models.GenerateResponse{
+		Message:       "Terraform code generated successfully",
+		Files:         []models.FileResult{},
+		Organisations: organisations,
+	}
+	failed := 0
+	for _, organisation := range organisations {
+		response.Files = append(response.Files, organisation.Files...)
+		if organisation.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		response.Message = fmt.Sprintf("Terraform code generation failed for %d of %d organisations", failed, len(organisations))
+		if failed < len(organisations) {
+			status = http.StatusMultiStatus
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,19 @@
+// backend/handlers/generate_handler_test.go
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRejectsUnsafeOrganisations(t *testing.T) {
+	body := `{"organisation_name": "acme", "organisations": ["../globex"], "product_name": "web", "provider": "azure"}`
+	recorder := httptest.NewRecorder()
+	GenerateTerraformHandler(false)(recorder, httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(body)))
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "../globex") {
+		t.Errorf("status = %d, body %q; want %d naming the organisation", recorder.Code, recorder.Body.String(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,50 @@
+// backend/handlers/graph_handler.go
+
+package handlers
+
+import (
+	"backend/models"
+	"backend/services"
+	"backend/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ModuleGraphHandler returns the module dependency graph of a generate request body as JSON, or as Graphviz DOT
+// with ?format=dot. A lenient handler decodes the body like the generate endpoint's.
+func ModuleGraphHandler(lenient bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "dot" {
+			http.Error(w, fmt.Sprintf("unsupported format %q: expected json or dot", format), http.StatusBadRequest)
+			return
+		}
+		var req models.GenerateRequest
+		if err := decodeJSON(r.Body, &req, lenient); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		graph, err := services.ModuleGraph(&req)
+		if errors.Is(err, services.ErrInvalidRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if format == "dot" {
+			w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(utils.ModuleGraphDOT(graph)))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(graph)
+	}
+}
@@ -0,0 +1,74 @@
+// backend/handlers/matrix_handler.go
+
+package handlers
+
+import (
+	"backend/models"
+	"backend/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GenerateMatrixHandler generates Terraform files for every row of a provider matrix CSV sent as the request body. A
+// lenient handler reads its boolean query parameters like the generate endpoint reads string-encoded booleans.
+func GenerateMatrixHandler(lenient bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		generateMatrix(w, r, lenient)
+	}
+}
+
+// generateMatrix serves a matrix generation request
+func generateMatrix(w http.ResponseWriter, r *http.Request, lenient bool) {
+	query := r.URL.Query()
+	base := models.GenerateRequest{
+		OrganisationName: query.Get("organisation_name"),
+		ProductName:      query.Get("product_name"),
+	}
+	var err error
+	if base.NoOverwrite, err = queryBool(query, "no_overwrite", lenient); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if base.AllowMissingKeys, err = queryBool(query, "allow_missing_keys", lenient); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if modules := query.Get("modules"); modules != "" {
+		for _, module := range strings.Split(modules, ",") {
+			base.Modules = append(base.Modules, strings.TrimSpace(module))
+		}
+	}
+
+	if base.OrganisationName == "" || base.ProductName == "" {
+		http.Error(w, "organisation_name and product_name are required", http.StatusBadRequest)
+		return
+	}
+
+	results, err := services.GenerateFromMatrix(r.Body, base)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
+}
+
+// queryBool reads a boolean query parameter. Only "true" sets it, unless lenient is set, which accepts anything
+// strconv.ParseBool does, such as "1" or "True", and rejects other values.
+func queryBool(query url.Values, name string, lenient bool) (bool, error) {
+	value := strings.TrimSpace(query.Get(name))
+	if !lenient || value == "" {
+		return value == "true", nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s: %q is not a boolean", name, value)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,33 @@
+// backend/handlers/matrix_handler_test.go
+
+package handlers
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestQueryBool(t *testing.T) {
+	tests := []struct {
+		value   string
+		lenient bool
+		want    bool
+		wantErr bool
+	}{
+		{value: "", want: false},
+		{value: "true", want: true},
+		{value: "1", want: false},
+		{value: "yes", want: false},
+		{value: "", lenient: true, want: false},
+		{value: "1", lenient: true, want: true},
+		{value: " True ", lenient: true, want: true},
+		{value: "F", lenient: true, want: false},
+		{value: "yes", lenient: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := queryBool(url.Values{"no_overwrite": {tt.value}}, "no_overwrite", tt.lenient)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("queryBool(%q, lenient %t) = %t, %v; want %t, error %t", tt.value, tt.lenient, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
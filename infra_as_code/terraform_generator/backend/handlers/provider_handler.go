@@ -0,0 +1,28 @@
+// backend/handlers/provider_handler.go
+
+package handlers
+
+import (
+	"backend/services"
+	"encoding/json"
+	"net/http"
+)
+
+// ResolveProviderHandler shows which configured provider a provider input resolves to.
+func ResolveProviderHandler(w http.ResponseWriter, r *http.Request) {
+	input := r.URL.Query().Get("provider")
+	if input == "" {
+		http.Error(w, "provider is required", http.StatusBadRequest)
+		return
+	}
+
+	resolution, err := services.ResolveProvider(input)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resolution)
+}
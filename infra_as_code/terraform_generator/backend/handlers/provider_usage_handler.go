@@ -0,0 +1,33 @@
+// backend/handlers/provider_usage_handler.go
+
+package handlers
+
+import (
+	"backend/services"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ProviderUsageHandler reports the configured providers that none of the given provider inputs resolve to.
+// Inputs are given as repeated or comma-separated provider parameters, e.g. ?provider=azure,aws.
+func ProviderUsageHandler(w http.ResponseWriter, r *http.Request) {
+	var inputs []string
+	for _, value := range r.URL.Query()["provider"] {
+		inputs = append(inputs, strings.Split(value, ",")...)
+	}
+	if len(inputs) == 0 {
+		http.Error(w, "provider is required", http.StatusBadRequest)
+		return
+	}
+
+	usage, err := services.CheckProviderUsage(inputs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(usage)
+}
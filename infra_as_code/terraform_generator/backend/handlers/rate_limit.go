@@ -0,0 +1,121 @@
+// backend/handlers/rate_limit.go
+
+package handlers
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a token bucket refilled continuously at rate tokens per second up to burst tokens.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// newTokenBucket creates a full bucket.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// take removes a token if one is available, otherwise it reports how long until the next one is.
+func (b *tokenBucket) take() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// clientBuckets keeps a token bucket per client IP address, so one busy client cannot use up the requests of others.
+type clientBuckets struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// newClientBuckets creates the buckets of clients allowed rate requests per second with bursts of up to burst.
+func newClientBuckets(rate float64, burst int) *clientBuckets {
+	return &clientBuckets{rate: rate, burst: burst, buckets: make(map[string]*tokenBucket), lastSweep: time.Now(), now: time.Now}
+}
+
+// bucket returns the bucket of client, starting a full one for a client not seen before. Buckets idle for long
+// enough to have refilled are dropped now and then, as a new bucket would be no different, so the map does not grow
+// with every client ever seen.
+func (c *clientBuckets) bucket(client string) *tokenBucket {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	refill := time.Duration(float64(max(c.burst, 1)) / c.rate * float64(time.Second))
+	if now.Sub(c.lastSweep) >= refill {
+		for key, bucket := range c.buckets {
+			bucket.mu.Lock()
+			idle := now.Sub(bucket.last)
+			bucket.mu.Unlock()
+			if idle >= refill {
+				delete(c.buckets, key)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	bucket, ok := c.buckets[client]
+	if !ok {
+		bucket = newTokenBucket(c.rate, c.burst)
+		bucket.now, bucket.last = c.now, now
+		c.buckets[client] = bucket
+	}
+	return bucket
+}
+
+// clientIP returns the IP address a request comes from. Forwarding headers are ignored, since any client can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimited wraps next so that requests beyond the rate of the client's bucket are rejected with 429 Too Many
+// Requests.
+func rateLimited(buckets *clientBuckets, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := buckets.bucket(clientIP(r)).take()
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,54 @@
+// backend/handlers/rate_limit_test.go
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedPerClient(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	buckets := newClientBuckets(1, 2)
+	buckets.now = func() time.Time { return clock }
+	buckets.lastSweep = clock
+	handler := rateLimited(buckets, func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/files", nil)
+		r.RemoteAddr = remoteAddr
+		// A client cannot pick another bucket by claiming to be forwarded
+		r.Header.Set("X-Forwarded-For", "192.0.2.99")
+		recorder := httptest.NewRecorder()
+		handler(recorder, r)
+		return recorder
+	}
+
+	// Each address gets its own burst, whatever port it connects from
+	for i, remoteAddr := range []string{"192.0.2.1:5000", "192.0.2.1:5001"} {
+		if code := request(remoteAddr).Code; code != http.StatusOK {
+			t.Fatalf("request %d from 192.0.2.1 = %d, want %d", i+1, code, http.StatusOK)
+		}
+	}
+	limited := request("192.0.2.1:5002")
+	if limited.Code != http.StatusTooManyRequests || limited.Header().Get("Retry-After") != "1" {
+		t.Fatalf("request over the burst = %d, Retry-After %q; want %d after 1", limited.Code, limited.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+	if code := request("[2001:db8::1]:5000").Code; code != http.StatusOK {
+		t.Fatalf("request from another client = %d, want %d", code, http.StatusOK)
+	}
+
+	clock = clock.Add(time.Second)
+	if code := request("192.0.2.1:5003").Code; code != http.StatusOK {
+		t.Fatalf("request after the refill = %d, want %d", code, http.StatusOK)
+	}
+
+	// Buckets idle long enough to have refilled are dropped
+	clock = clock.Add(2 * time.Second)
+	request("198.51.100.1:5000")
+	if len(buckets.buckets) != 1 {
+		t.Errorf("%d buckets after the others went idle, want 1", len(buckets.buckets))
+	}
+}
@@ -0,0 +1,22 @@
+// backend/handlers/reload_handler.go
+
+package handlers
+
+import (
+	"backend/services"
+	"encoding/json"
+	"net/http"
+)
+
+// ReloadConfigHandler reads the configuration file again without restarting the server. When the file cannot be
+// loaded or is invalid the error is returned and requests keep using the previous configuration.
+func ReloadConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if err := services.ReloadConfig(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Configuration reloaded"})
+}
@@ -0,0 +1,38 @@
+// backend/handlers/replay_handler.go
+
+package handlers
+
+import (
+	"backend/models"
+	"backend/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ReplayManifestHandler regenerates the files described by a stored manifest and reports drift.
+func ReplayManifestHandler(w http.ResponseWriter, r *http.Request) {
+	var manifest models.Manifest
+	if err := json.NewDecoder(r.Body).Decode(&manifest); err != nil {
+		http.Error(w, "Invalid manifest payload", http.StatusBadRequest)
+		return
+	}
+	if len(manifest.Files) == 0 {
+		http.Error(w, "manifest lists no files", http.StatusBadRequest)
+		return
+	}
+
+	result, err := services.Replay(manifest)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidRequest) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
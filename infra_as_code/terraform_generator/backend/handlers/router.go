@@ -0,0 +1,34 @@
+// backend/handlers/router.go
+
+package handlers
+
+import "net/http"
+
+// RouterOptions configures the HTTP API.
+type RouterOptions struct {
+	RateLimit float64 // Requests per second each client IP address may make across the generate and download endpoints; 0 disables limiting
+	RateBurst int     // Requests allowed in a burst above the rate
+	Lenient   bool    // Accept string-encoded booleans and numbers in generate requests, e.g. "true" for a flag, and booleans such as "1" in matrix query parameters
+}
+
+// NewRouter registers the HTTP API routes.
+func NewRouter(opts RouterOptions) http.Handler {
+	// Generation and downloads share each client's bucket so a single client cannot exhaust disk through either
+	limited := func(handler http.HandlerFunc) http.HandlerFunc { return handler }
+	if opts.RateLimit > 0 {
+		buckets := newClientBuckets(opts.RateLimit, opts.RateBurst)
+		limited = func(handler http.HandlerFunc) http.HandlerFunc { return rateLimited(buckets, handler) }
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/generate", limited(GenerateTerraformHandler(opts.Lenient)))
+	mux.HandleFunc("POST /api/generate/matrix", limited(GenerateMatrixHandler(opts.Lenient)))
+	mux.HandleFunc("POST /api/replay", limited(ReplayManifestHandler))
+	mux.HandleFunc("GET /api/files", limited(GetFileHandler))
+	mux.HandleFunc("GET /api/providers/resolve", ResolveProviderHandler)
+	mux.HandleFunc("GET /api/providers/usage", ProviderUsageHandler)
+	mux.HandleFunc("GET /api/templates/report", TemplateReportHandler)
+	mux.HandleFunc("POST /api/graph", ModuleGraphHandler(opts.Lenient))
+	mux.HandleFunc("POST /api/reload", limited(ReloadConfigHandler))
+	return mux
+}
@@ -0,0 +1,22 @@
+// backend/handlers/template_report_handler.go
+
+package handlers
+
+import (
+	"backend/services"
+	"encoding/json"
+	"net/http"
+)
+
+// TemplateReportHandler reports the templates that generation never uses and the ones it references but cannot find.
+func TemplateReportHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := services.CheckTemplateUsage()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
@@ -3,14 +3,17 @@
 package main
 
 import (
+	"backend/handlers"
 	"backend/models"
 	"backend/services"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 )
 
@@ -18,13 +21,50 @@ func main() {
 	// Define subcommands
 	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
 	terraformCmd := flag.NewFlagSet("terraform", flag.ExitOnError)
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	matrixCmd := flag.NewFlagSet("matrix", flag.ExitOnError)
+	templatesCmd := flag.NewFlagSet("templates", flag.ExitOnError)
+	providersCmd := flag.NewFlagSet("providers", flag.ExitOnError)
+	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+
+	// Define flags for 'serve' subcommand
+	rateLimit := serveCmd.Float64("rate-limit", 5, "Requests per second each client IP address may make to the generate and file endpoints (0 disables limiting)")
+	rateBurst := serveCmd.Int("rate-burst", 10, "Requests allowed in a burst above the rate limit")
+	lenient := serveCmd.Bool("lenient", false, "Accept string-encoded booleans and numbers, e.g. \"true\", in generate requests")
+	watchConfig := serveCmd.Bool("watch-config", true, "Reload the configuration when configs/terraform-generator.json changes")
 
 	// Define flags for 'generate' subcommand
-	company := generateCmd.String("company", "", "Company name (required)")
+	company := generateCmd.String("company", "", "Company name, or a comma-separated list to generate the product for several (required)")
 	product := generateCmd.String("product", "", "Product name (required)")
 	provider := generateCmd.String("provider", "", "Provider name (required)")
 	modules := generateCmd.String("modules", "", "Comma-separated list of modules")
 	customers := generateCmd.String("customers", "", "Comma-separated list of customers")
+	region := generateCmd.String("region", "", "Region to generate for, overriding the configured region")
+	noOverwrite := generateCmd.Bool("no-overwrite", false, "Skip files that already exist instead of replacing them")
+	noTfvarsComments := generateCmd.Bool("no-tfvars-comments", false, "Omit variable description comments from tfvars files")
+	requireValues := generateCmd.Bool("require-values", false, "Fail before writing when a required variable has neither a default nor a value")
+	noVars := generateCmd.Bool("no-vars", false, "Skip vars.tfvars files, for values supplied from outside the generator")
+	debug := generateCmd.Bool("debug", false, "Log the template data for each product or customer, with sensitive values redacted")
+	allowMissingKeys := generateCmd.Bool("allow-missing-keys", false, "Render missing template keys as empty instead of failing")
+	flavor := generateCmd.String("flavor", "", "Template set to render, read from templates/<flavor>/<provider> instead of templates/<provider>")
+	migrateBackendFrom := generateCmd.String("migrate-backend-from", "", "Previous backend type (local, s3, azurerm or gcs); scaffolds migrate-backend.sh moving state to the generated backend")
+	format := generateCmd.String("format", models.OutputFormatHCL, "Output syntax for the Terraform configuration (hcl, json or cdktf)")
+	tags := generateCmd.String("tags", "", "Comma-separated key=value provider default tags")
+	requestedBy := generateCmd.String("requested-by", os.Getenv("USER"), "Name recorded in the generation log")
+	scaffold := generateCmd.String("scaffold", "", "Comma-separated list of repository files to scaffold (codeowners, linters, policy, pre-commit, readme, credentials, envrc, workflow, teardown, atlantis, root-main, bootstrap, vars-schema)")
+
+	// Define flags for 'matrix' subcommand
+	matrixFile := matrixCmd.String("file", "", "Path to the provider matrix CSV (required)")
+	matrixCompany := matrixCmd.String("company", "", "Company name (required)")
+	matrixProduct := matrixCmd.String("product", "", "Product name (required)")
+	matrixModules := matrixCmd.String("modules", "", "Comma-separated list of modules")
+
+	// Define flags for 'providers' subcommand
+	usageProviders := providersCmd.String("provider", "", "Comma-separated list of provider inputs to check")
+	usageMatrix := providersCmd.String("file", "", "Path to a provider matrix CSV whose rows' providers are checked")
+
+	// Define flags for 'import' subcommand
+	importPath := importCmd.String("path", "", "Path to an existing variables.tf, or a directory of .tf files (required)")
 
 	// Define flags for 'terraform' subcommand
 	tfCommand := terraformCmd.String("command", "", "Terraform command to execute (init, validate, plan, apply, build, destroy, print)")
@@ -35,7 +75,7 @@ func main() {
 
 	// Ensure a subcommand is provided
 	if len(os.Args) < 2 {
-		fmt.Println("Expected 'generate' or 'terraform' subcommands")
+		fmt.Println("Expected 'generate', 'matrix', 'templates', 'providers', 'import', 'terraform' or 'serve' subcommands")
 		os.Exit(1)
 	}
 
@@ -44,7 +84,47 @@ func main() {
 	case "generate":
 		generateCmd.Parse(os.Args[2:])
 		if generateCmd.Parsed() {
-			handleGenerateCommand(*company, *product, *provider, *modules, *customers)
+			req := models.GenerateRequest{
+				ProductName:        *product,
+				Provider:           *provider,
+				Region:             *region,
+				Modules:            splitList(*modules),
+				NoOverwrite:        *noOverwrite,
+				AllowMissingKeys:   *allowMissingKeys,
+				RequestedBy:        *requestedBy,
+				OutputFormat:       *format,
+				Flavor:             *flavor,
+				MigrateBackendFrom: *migrateBackendFrom,
+				NoTfvarsComments:   *noTfvarsComments,
+				NoVars:             *noVars,
+				RequireValues:      *requireValues,
+				Debug:              *debug,
+			}
+			// The first company is the request's organisation, any others are generated after it
+			if organisations := splitList(*company); len(organisations) > 0 {
+				req.OrganisationName, req.Organisations = organisations[0], organisations[1:]
+			}
+			if *customers != "" {
+				req.Customers = splitList(*customers)
+			}
+			var err error
+			if req.Tags, err = parseTags(*tags); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			for _, name := range splitList(*scaffold) {
+				if err := applyScaffoldOption(&req, name); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			handleGenerateCommand(&req)
+		}
+
+	case "matrix":
+		matrixCmd.Parse(os.Args[2:])
+		if matrixCmd.Parsed() {
+			handleMatrixCommand(*matrixFile, *matrixCompany, *matrixProduct, *matrixModules)
 		}
 
 	case "terraform":
@@ -53,51 +133,330 @@ func main() {
 			handleTerraformCommand(*tfCommand, *tfCompany, *tfProduct, *tfProvider, *tfInfratype)
 		}
 
+	case "templates":
+		templatesCmd.Parse(os.Args[2:])
+		if templatesCmd.Parsed() {
+			handleTemplatesCommand()
+		}
+
+	case "providers":
+		providersCmd.Parse(os.Args[2:])
+		if providersCmd.Parsed() {
+			handleProvidersCommand(*usageProviders, *usageMatrix)
+		}
+
+	case "import":
+		importCmd.Parse(os.Args[2:])
+		if importCmd.Parsed() {
+			handleImportCommand(*importPath)
+		}
+
+	case "serve":
+		serveCmd.Parse(os.Args[2:])
+		if serveCmd.Parsed() {
+			handleServeCommand(handlers.RouterOptions{RateLimit: *rateLimit, RateBurst: *rateBurst, Lenient: *lenient}, *watchConfig)
+		}
+
 	default:
-		fmt.Println("Expected 'generate' or 'terraform' subcommands")
+		fmt.Println("Expected 'generate', 'matrix', 'templates', 'providers', 'import', 'terraform' or 'serve' subcommands")
 		os.Exit(1)
 	}
 }
 
-// handleGenerateCommand processes the 'generate' subcommand
-func handleGenerateCommand(company, product, provider, modules, customers string) {
+// printFileResult prints what generation did with a file or directory
+func printFileResult(result models.FileResult) {
+	if result.Directory {
+		fmt.Printf("%-11s %s/ (directory)\n", result.Action, result.Path)
+		return
+	}
+	fmt.Printf("%-11s %s (%d bytes)\n", result.Action, result.Path, result.Bytes)
+}
+
+// handleGenerateCommand processes the 'generate' subcommand for the request built from its flags
+func handleGenerateCommand(req *models.GenerateRequest) {
 	// Validate required flags
-	if company == "" || product == "" || provider == "" {
+	if req.OrganisationName == "" || req.ProductName == "" || req.Provider == "" {
 		fmt.Println("Error: --company, --product, and --provider are required")
 		os.Exit(1)
 	}
 
-	// Create a GenerateRequest
-	req := models.GenerateRequest{
+	if len(req.Organisations) > 0 {
+		generateOrganisations(req)
+		return
+	}
+
+	// Generate Terraform code
+	results, err := services.GenerateTerraform(req)
+	for _, result := range results {
+		printFileResult(result)
+	}
+	if err != nil {
+		fmt.Printf("Error generating Terraform code: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Terraform code generated successfully")
+}
+
+// splitList splits a comma-separated flag value into its trimmed items, giving an empty list for an empty value
+func splitList(value string) []string {
+	items := []string{}
+	if value == "" {
+		return items
+	}
+	for _, item := range strings.Split(value, ",") {
+		items = append(items, strings.TrimSpace(item))
+	}
+	return items
+}
+
+// parseTags parses a comma-separated list of key=value tags, giving nil for an empty value
+func parseTags(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	tags := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		key, tagValue, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid tag %q, expected key=value", pair)
+		}
+		tags[key] = tagValue
+	}
+	return tags, nil
+}
+
+// applyScaffoldOption enables a named piece of repository scaffolding on the request
+func applyScaffoldOption(req *models.GenerateRequest, name string) error {
+	switch name {
+	case "codeowners":
+		req.GenerateCodeowners = true
+	case "linters":
+		req.GenerateLinters = true
+	case "policy":
+		req.GeneratePolicy = true
+	case "pre-commit":
+		req.GeneratePreCommit = true
+	case "envrc":
+		req.GenerateEnvrc = true
+	case "readme":
+		req.GenerateReadme = true
+	case "credentials":
+		req.GenerateCredentials = true
+	case "atlantis":
+		req.GenerateAtlantis = true
+	case "workflow":
+		req.GenerateWorkflow = true
+	case "root-main":
+		req.GenerateRootMain = true
+	case "teardown":
+		req.GenerateTeardown = true
+	case "bootstrap":
+		req.GenerateBootstrap = true
+	case "vars-schema":
+		req.GenerateVarsSchema = true
+	default:
+		return fmt.Errorf("unknown scaffold option: %s", name)
+	}
+	return nil
+}
+
+// generateOrganisations generates the product for every organisation given to --company and reports each one
+func generateOrganisations(req *models.GenerateRequest) {
+	results, err := services.GenerateOrganisations(req)
+	if err != nil && results == nil {
+		fmt.Printf("Error generating Terraform code: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, result := range results {
+		for _, file := range result.Files {
+			printFileResult(file)
+		}
+		if result.Error != "" {
+			failed = true
+			fmt.Printf("%s: failed: %s\n", result.Organisation, result.Error)
+			continue
+		}
+		fmt.Printf("%s: %d files\n", result.Organisation, len(result.Files))
+	}
+	if failed {
+		os.Exit(1)
+	}
+
+	fmt.Println("Terraform code generated successfully")
+}
+
+// handleMatrixCommand processes the 'matrix' subcommand
+func handleMatrixCommand(file, company, product, modules string) {
+	// Validate required flags
+	if file == "" || company == "" || product == "" {
+		fmt.Println("Error: --file, --company, and --product are required")
+		os.Exit(1)
+	}
+
+	matrix, err := os.Open(file)
+	if err != nil {
+		fmt.Printf("Error opening provider matrix: %v\n", err)
+		os.Exit(1)
+	}
+	defer matrix.Close()
+
+	base := models.GenerateRequest{
 		OrganisationName: company,
 		ProductName:      product,
-		Provider:         provider,
-		Modules:          []string{},
 	}
-
-	// Handle modules
 	if modules != "" {
-		moduleNames := strings.Split(modules, ",")
-		for _, moduleName := range moduleNames {
-			req.Modules = append(req.Modules, strings.TrimSpace(moduleName))
+		for _, moduleName := range strings.Split(modules, ",") {
+			base.Modules = append(base.Modules, strings.TrimSpace(moduleName))
 		}
 	}
 
-	// Handle customers
-	if customers != "" {
-		req.Customers = strings.Split(customers, ",")
-		for i := range req.Customers {
-			req.Customers[i] = strings.TrimSpace(req.Customers[i])
+	results, err := services.GenerateFromMatrix(matrix, base)
+	if err != nil {
+		fmt.Printf("Error generating from provider matrix: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, result := range results {
+		if result.Error != "" {
+			failed = true
+			fmt.Printf("%s (%s): failed: %s\n", result.Customer, result.Provider, result.Error)
+			continue
 		}
+		fmt.Printf("%s (%s): %d files\n", result.Customer, result.Provider, result.Files)
 	}
+	if failed {
+		os.Exit(1)
+	}
+}
 
-	// Generate Terraform code
-	if err := services.GenerateTerraform(&req); err != nil {
-		fmt.Printf("Error generating Terraform code: %v\n", err)
+// handleTemplatesCommand prints a JSON report of unused and missing templates, failing when any are missing
+func handleTemplatesCommand() {
+	report, err := services.CheckTemplateUsage()
+	if err != nil {
+		fmt.Printf("Error checking templates: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("Terraform code generated successfully")
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		os.Exit(1)
+	}
+	if len(report.Missing) > 0 {
+		os.Exit(1)
+	}
+}
+
+// handleProvidersCommand prints a JSON report of the configured providers that none of the given provider inputs,
+// or rows of a provider matrix, request
+func handleProvidersCommand(providers, file string) {
+	if providers == "" && file == "" {
+		fmt.Println("Error: --provider or --file is required")
+		os.Exit(1)
+	}
+
+	var inputs []string
+	if providers != "" {
+		inputs = strings.Split(providers, ",")
+	}
+	if file != "" {
+		matrix, err := os.Open(file)
+		if err != nil {
+			fmt.Printf("Error opening provider matrix: %v\n", err)
+			os.Exit(1)
+		}
+		defer matrix.Close()
+
+		matrixProviders, err := services.MatrixProviders(matrix)
+		if err != nil {
+			fmt.Printf("Error reading provider matrix: %v\n", err)
+			os.Exit(1)
+		}
+		inputs = append(inputs, matrixProviders...)
+	}
+
+	usage, err := services.CheckProviderUsage(inputs)
+	if err != nil {
+		fmt.Printf("Error checking providers: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(usage); err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handleImportCommand prints the variables declared by an existing configuration as a configuration variables section
+func handleImportCommand(path string) {
+	if path == "" {
+		fmt.Println("Error: --path is required")
+		os.Exit(1)
+	}
+
+	variables, err := services.ImportVariables(path)
+	if err != nil {
+		fmt.Printf("Error importing variables: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false) // Keep comparisons in validation conditions readable
+	if err := encoder.Encode(map[string]interface{}{"variables": variables}); err != nil {
+		fmt.Printf("Error writing configuration: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// listenAddrEnv names the environment variable holding the API's listen address, e.g. "127.0.0.1:9090".
+const listenAddrEnv = "TF_GENERATOR_ADDR"
+
+// defaultListenAddr is the listen address used when TF_GENERATOR_ADDR is unset.
+const defaultListenAddr = ":8080"
+
+// handleServeCommand starts the HTTP API
+func handleServeCommand(opts handlers.RouterOptions, watchConfig bool) {
+	if err := services.CheckTemplates(); err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+
+	// The configuration is cached between requests; without the watcher only POST /api/reload refreshes it
+	if watchConfig {
+		stop, err := services.WatchConfig(func(err error) {
+			if err != nil {
+				log.Printf("Configuration not reloaded: %v", err)
+				return
+			}
+			log.Printf("Configuration reloaded")
+		})
+		if err != nil {
+			log.Fatalf("Error watching the configuration: %v\n", err)
+		}
+		defer stop()
+	}
+
+	addr := os.Getenv(listenAddrEnv)
+	if addr == "" {
+		addr = defaultListenAddr
+	}
+
+	// Listen first so the logged address is the bound one, including the port chosen for ":0"
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Error listening on %s: %v\n", addr, err)
+	}
+	log.Printf("Starting Terraform generator API on %s", listener.Addr())
+	if err := http.Serve(listener, handlers.NewRouter(opts)); err != nil {
+		log.Fatalf("Error running server: %v\n", err)
+	}
 }
 
 // handleTerraformCommand processes the 'terraform' subcommand
@@ -108,15 +467,15 @@ func handleTerraformCommand(command, company, product, provider, infratype strin
 		os.Exit(1)
 	}
 
-	// Certain commands require 'infratype'
-	if (command == "init" || command == "build" || command == "print" || command == "validate") && infratype == "" {
-		fmt.Println("Error: --infratype is required for 'init', 'build', 'print', and 'validate' commands")
+	// Every command runs with an environment's backend or vars file
+	if infratype == "" {
+		fmt.Println("Error: --infratype is required")
 		os.Exit(1)
 	}
 
 	// Handle 'print' command separately
 	if command == "print" {
-		printTerraformCommands(command, company, product, provider, infratype)
+		printTerraformCommands(company, product, provider, infratype)
 	} else {
 		// Execute other Terraform commands
 		if err := runTerraformCommand(command, company, product, provider, infratype); err != nil {
@@ -127,83 +486,93 @@ func handleTerraformCommand(command, company, product, provider, infratype strin
 
 // runTerraformCommand executes the specified Terraform command
 func runTerraformCommand(command, company, product, provider, infratype string) error {
-	terraformDir := filepath.Join("output", "terraform", company, product)
+	terraformDir, err := services.ResolveOutputDir(provider, company, product, "")
+	if err != nil {
+		return err
+	}
 	if _, err := os.Stat(terraformDir); os.IsNotExist(err) {
 		return fmt.Errorf("Terraform directory %s does not exist", terraformDir)
 	}
+	files, err := services.ResolveEnvironmentFiles(provider, company, product, infratype)
+	if err != nil {
+		return err
+	}
+	commands, err := terraformCommands(command, files)
+	if err != nil {
+		return err
+	}
 
 	// Change to the Terraform directory
 	if err := os.Chdir(terraformDir); err != nil {
 		return fmt.Errorf("error changing directory to %s: %v", terraformDir, err)
 	}
+	if files.DataDir != "" {
+		os.Setenv("TF_DATA_DIR", files.DataDir)
+	}
 
-	switch command {
-	case "init":
-		// Removed -backend-config
-		args := []string{"init", "-no-color", "-get=true", "-force-copy"}
-		return executeCommand("terraform", args)
-
-	case "validate":
-		args := []string{"validate", "-no-color"}
-		return executeCommand("terraform", args)
-
-	case "plan":
-		args := []string{"plan", "-no-color", "-input=false", "-lock=true", "-refresh=true", "-var-file=./vars.tfvars"}
-		return executeCommand("terraform", args)
-
-	case "apply":
-		args := []string{"apply", "-no-color", "-input=false", "-auto-approve=true", "-lock=true", "-lock-timeout=7200s", "-refresh=true", "-var-file=./vars.tfvars"}
-		return executeCommand("terraform", args)
-
-	case "destroy":
-		args := []string{"destroy", "-no-color", "-auto-approve=true", "-var-file=./vars.tfvars"}
-		return executeCommand("terraform", args)
-
-	case "build":
-		// Sequentially execute init, validate, plan, and apply
-		buildCommands := [][]string{
-			{"init", "-no-color", "-get=true", "-force-copy"},
-			{"validate", "-no-color"},
-			{"plan", "-no-color", "-input=false", "-lock=true", "-refresh=true", "-var-file=./vars.tfvars"},
-			{"apply", "-no-color", "-input=false", "-auto-approve=true", "-lock=true", "-lock-timeout=7200s", "-refresh=true", "-var-file=./vars.tfvars"},
-		}
-
-		for _, args := range buildCommands {
-			if err := executeCommand("terraform", args); err != nil {
-				return err
-			}
+	for _, args := range commands {
+		if err := executeCommand("terraform", args); err != nil {
+			return err
 		}
-
-	default:
-		return fmt.Errorf("unsupported Terraform command: %s", command)
 	}
-
 	return nil
 }
 
-// printTerraformCommands prints the Terraform commands without executing them
-func printTerraformCommands(command, company, product, provider, infratype string) {
-	terraformDir := filepath.Join("output", "terraform", company, product)
-	fmt.Printf("Working directory: %s\n", terraformDir)
+// terraformCommands returns the Terraform arguments to run for a command in order, passing the environment's backend
+// file to init and its vars file to plan, apply and destroy. build runs init, validate, plan and apply.
+func terraformCommands(command string, files services.EnvironmentFiles) ([][]string, error) {
+	init := []string{"init", "-no-color", "-get=true", "-force-copy"}
+	if files.BackendConfig != "" {
+		init = append(init, "-backend-config="+files.BackendConfig)
+	}
+	var varFile []string
+	if files.VarFile != "" {
+		varFile = []string{"-var-file=" + files.VarFile}
+	}
+	validate := []string{"validate", "-no-color"}
+	plan := append([]string{"plan", "-no-color", "-input=false", "-lock=true", "-refresh=true"}, varFile...)
+	apply := append([]string{"apply", "-no-color", "-input=false", "-auto-approve=true", "-lock=true", "-lock-timeout=7200s", "-refresh=true"}, varFile...)
+	destroy := append([]string{"destroy", "-no-color", "-auto-approve=true"}, varFile...)
 
 	switch command {
 	case "init":
-		fmt.Println("terraform init -no-color -get=true -force-copy")
+		return [][]string{init}, nil
 	case "validate":
-		fmt.Println("terraform validate -no-color")
+		return [][]string{validate}, nil
 	case "plan":
-		fmt.Println("terraform plan -no-color -input=false -lock=true -refresh=true -var-file=./vars.tfvars")
+		return [][]string{plan}, nil
 	case "apply":
-		fmt.Println("terraform apply -no-color -input=false -auto-approve=true -lock=true -lock-timeout=7200s -refresh=true -var-file=./vars.tfvars")
+		return [][]string{apply}, nil
 	case "destroy":
-		fmt.Println("terraform destroy -no-color -auto-approve=true -var-file=./vars.tfvars")
+		return [][]string{destroy}, nil
 	case "build":
-		fmt.Println("terraform init -no-color -get=true -force-copy")
-		fmt.Println("terraform validate -no-color")
-		fmt.Println("terraform plan -no-color -input=false -lock=true -refresh=true -var-file=./vars.tfvars")
-		fmt.Println("terraform apply -no-color -input=false -auto-approve=true -lock=true -lock-timeout=7200s -refresh=true -var-file=./vars.tfvars")
+		return [][]string{init, validate, plan, apply}, nil
 	default:
-		fmt.Printf("Unsupported command: %s\n", command)
+		return nil, fmt.Errorf("unsupported Terraform command: %s", command)
+	}
+}
+
+// printTerraformCommands prints the Terraform commands without executing them
+func printTerraformCommands(company, product, provider, infratype string) {
+	terraformDir, err := services.ResolveOutputDir(provider, company, product, "")
+	if err != nil {
+		fmt.Printf("Error resolving the Terraform directory: %v\n", err)
+		os.Exit(1)
+	}
+	files, err := services.ResolveEnvironmentFiles(provider, company, product, infratype)
+	if err != nil {
+		fmt.Printf("Error resolving the %s files: %v\n", infratype, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Working directory: %s\n", terraformDir)
+	if files.DataDir != "" {
+		fmt.Printf("TF_DATA_DIR: %s\n", files.DataDir)
+	}
+
+	// print shows the whole build sequence
+	commands, _ := terraformCommands("build", files)
+	for _, args := range commands {
+		fmt.Println("terraform " + strings.Join(args, " "))
 	}
 }
 
@@ -2,42 +2,393 @@
 
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+)
+
 type Config struct {
-	TerraformVersion string              `json:"terraform_version"`
-	Providers        []Provider          `json:"providers"`
-	Backend          Backend             `json:"backend"`
-	Modules          []Module            `json:"modules"`
-	Variables        map[string]Variable `json:"variables"`
-	Region           string              `json:"region"`
-	Environment      string              `json:"environment"`
+	TerraformVersion   TerraformVersion            `json:"terraform_version"`
+	Providers          []Provider                  `json:"providers"`
+	Backend            Backend                     `json:"backend"`
+	Modules            []Module                    `json:"modules"`
+	Variables          map[string]Variable         `json:"variables"`
+	Resources          []Resource                  `json:"resources,omitempty"` // Ad-hoc resources rendered into resources.tf
+	Region             string                      `json:"region"`
+	Regions            []string                    `json:"regions,omitempty"` // Each gets an aliased provider configuration, e.g. ["us-east-1", "eu-west-1"]
+	Environment        string                      `json:"environment"`
+	ProviderSourceHost string                      `json:"provider_source_host,omitempty"` // Registry mirror host for provider sources, e.g. "registry.internal"
+	Repository         Repository                  `json:"repository,omitempty"`
+	Layout             string                      `json:"layout,omitempty"`           // flat (default) or environments
+	DefaultTags        map[string]string           `json:"default_tags,omitempty"`     // Organisation-wide provider default tags
+	SkipVars           bool                        `json:"skip_vars,omitempty"`        // Never generate vars.tfvars files, e.g. when values come from a secrets manager
+	Proxy              Proxy                       `json:"proxy,omitempty"`            // Corporate proxy exported by the generated .envrc
+	TfvarsFilename     string                      `json:"tfvars_filename,omitempty"`  // Template for per-environment tfvars names, e.g. "{{.Environment}}.{{.Name}}.tfvars"
+	TfvarsOrder        []string                    `json:"tfvars_order,omitempty"`     // Variables written first to tfvars files, in this order; the rest follow by name
+	ProtectedFiles     []string                    `json:"protected_files,omitempty"`  // File name patterns never rewritten once they exist, in addition to *.override.tf
+	RenderTimeout      string                      `json:"render_timeout,omitempty"`   // Longest a single template may take to render, e.g. "10s"; defaults to 30s
+	MaxCustomers       int                         `json:"max_customers,omitempty"`    // Most customers a single request may generate; defaults to 500
+	OutputPath         string                      `json:"output_path,omitempty"`      // Template for output directories under output/terraform, e.g. "{{.Provider}}/{{.OrganisationName}}/{{.ProductName}}/{{.CustomerName}}"
+	CustomerPatches    map[string][]PatchOperation `json:"customer_patches,omitempty"` // JSON Patch operations applied to this configuration for one customer, keyed by customer name
+	StaticFiles        []StaticFile                `json:"static_files,omitempty"`     // Extra files written to every product and customer directory, e.g. LICENSE
+	Cloud              *Cloud                      `json:"cloud,omitempty"`            // HCP Terraform settings rendered into terraform.tf instead of a backend
+	Customers          map[string]CustomerSettings `json:"customers,omitempty"`        // Settings for individual customers, keyed by customer name
+	Removed            []RemovedBlock              `json:"removed,omitempty"`          // Resources and modules dropped from the configuration, rendered into removed.tf
+	LinkedProviders    []LinkedProvider            `json:"linked_providers,omitempty"` // Further providers configured from module outputs, e.g. kubernetes from an eks module
+	ProviderFile       string                      `json:"provider_file,omitempty"`    // File the terraform settings and provider blocks go to: providers.tf (default), main.tf or terraform.tf
+	BackendSuffix      string                      `json:"backend_suffix,omitempty"`   // Extension of backend config files: tfvars (default), tfbackend or type.tfbackend
+}
+
+// LinkedProvider is a further provider rendered into the root providers.tf and configured from the outputs of the
+// generated modules, such as a kubernetes provider pointed at the cluster an aws module creates
+type LinkedProvider struct {
+	Name      string                 `json:"name"`                // Provider local name, e.g. "kubernetes"
+	Source    string                 `json:"source"`              // e.g. "hashicorp/kubernetes"
+	Version   string                 `json:"version"`             // Version constraint, e.g. "~> 2.31"
+	Settings  map[string]interface{} `json:"settings,omitempty"`  // Provider arguments; references such as "module.eks.endpoint" render unquoted
+	Providers []string               `json:"providers,omitempty"` // Only emit alongside these providers; empty means all
+}
+
+// RemovedBlock is a removed block (Terraform 1.7+) that drops a resource or module from the configuration
+type RemovedBlock struct {
+	From    string `json:"from"`    // Resource or module address without instance keys, e.g. "module.legacy_vnet" or "azurerm_resource_group.old"
+	Destroy bool   `json:"destroy"` // Destroy the real infrastructure; false only forgets it from state
+}
+
+// CustomerSettings holds settings that apply to a single customer's directory
+type CustomerSettings struct {
+	Template    string `json:"template,omitempty"`     // main.tf template relative to the templates directory, e.g. "azure/main.govcloud.tf.tmpl"
+	TemplateDir string `json:"template_dir,omitempty"` // Directory under templates holding the customer's main.tf.tmpl, e.g. "azure-govcloud"; exclusive with template
+}
+
+// MainTemplate returns the customer's main.tf template relative to the templates directory, or "" for the provider's
+func (s CustomerSettings) MainTemplate() string {
+	if s.Template != "" {
+		return s.Template
+	}
+	if s.TemplateDir != "" {
+		return filepath.Join(s.TemplateDir, "main.tf.tmpl")
+	}
+	return ""
+}
+
+// Cloud configures the terraform cloud block that stores state in HCP Terraform or Terraform Enterprise
+type Cloud struct {
+	Organization string          `json:"organization"`
+	Hostname     string          `json:"hostname,omitempty"` // Terraform Enterprise host; defaults to app.terraform.io
+	Workspaces   CloudWorkspaces `json:"workspaces"`
+}
+
+// CloudWorkspaces selects the HCP Terraform workspaces a configuration uses: a single named one or every one tagged
+type CloudWorkspaces struct {
+	Name    string   `json:"name,omitempty"`    // A single workspace; exclusive with tags
+	Tags    []string `json:"tags,omitempty"`    // Workspaces carrying all of these tags, selected with terraform workspace select
+	Project string   `json:"project,omitempty"` // HCP Terraform project the workspaces belong to
+}
+
+// StaticFile is a file copied or rendered from the templates directory into every product and customer directory
+type StaticFile struct {
+	Source   string `json:"source"`             // Relative to the templates directory, e.g. "static/SECURITY.md.tmpl"
+	Dest     string `json:"dest"`               // Relative to the output directory, e.g. "SECURITY.md"
+	Verbatim bool   `json:"verbatim,omitempty"` // Copy the source as is instead of rendering it as a template
+}
+
+// PatchOperation is one JSON Patch (RFC 6902) operation, e.g. {"op": "replace", "path": "/region", "value": "eu-west-1"}
+type PatchOperation struct {
+	Op    string          `json:"op"` // add, remove, replace, move, copy or test
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`  // Source pointer for move and copy
+	Value json.RawMessage `json:"value,omitempty"` // Kept raw so an explicit null is told apart from a missing value
+}
+
+// Output layouts for per-environment files
+const (
+	LayoutFlat         = "flat"         // backend/<name>_<env>.tfvars and vars/<name>_<env>.tfvars
+	LayoutEnvironments = "environments" // envs/<env>/backend.tfvars and envs/<env>/vars.tfvars beside the shared root
+)
+
+// Extensions backend partial configuration files can be written with
+const (
+	BackendSuffixTfvars    = "tfvars"         // backend/<name>_<env>.tfvars, the default
+	BackendSuffixTfbackend = "tfbackend"      // backend/<name>_<env>.tfbackend, as recommended by Terraform
+	BackendSuffixTyped     = "type.tfbackend" // backend/<name>_<env>.<type>.tfbackend, e.g. web_prod.s3.tfbackend
+)
+
+// Files the required_providers and provider blocks can be rendered into
+const (
+	ProviderFileProviders = "providers.tf" // A file of their own, the default
+	ProviderFileMain      = "main.tf"      // Ahead of the backend and module calls
+	ProviderFileTerraform = "terraform.tf" // Together with the cloud block, when one is configured
+)
+
+// Repository describes the repository scaffolded around the generated Terraform.
+type Repository struct {
+	Team   string `json:"team,omitempty"` // Owning team handle, e.g. "@acme/platform"
+	Readme Readme `json:"readme,omitempty"`
+}
+
+// Readme holds the header of the generated README.md; terraform-docs fills in the rest.
+type Readme struct {
+	Title       string `json:"title,omitempty"` // Defaults to <organisation>/<product or customer>
+	Description string `json:"description,omitempty"`
+	Owner       string `json:"owner,omitempty"` // Defaults to the repository team
+}
+
+// Proxy holds the proxy settings terraform init needs behind a corporate proxy.
+type Proxy struct {
+	HTTPProxy  string   `json:"http_proxy,omitempty"`
+	HTTPSProxy string   `json:"https_proxy,omitempty"` // Defaults to http_proxy
+	NoProxy    []string `json:"no_proxy,omitempty"`    // Hosts and domains reached directly, e.g. [".internal", "169.254.169.254"]
+}
+
+// TerraformVersion is the required_version constraint, optionally varying by environment.
+// It decodes from a plain string or from an object keyed by environment with a "default" entry.
+type TerraformVersion struct {
+	Default      string
+	Environments map[string]string
+}
+
+// ForEnvironment returns the constraint for env, falling back to the default.
+func (v TerraformVersion) ForEnvironment(env string) string {
+	if version, ok := v.Environments[env]; ok {
+		return version
+	}
+	return v.Default
+}
+
+func (v *TerraformVersion) UnmarshalJSON(data []byte) error {
+	var plain string
+	if err := json.Unmarshal(data, &plain); err == nil {
+		*v = TerraformVersion{Default: plain}
+		return nil
+	}
+
+	var perEnvironment map[string]string
+	if err := json.Unmarshal(data, &perEnvironment); err != nil {
+		return fmt.Errorf("terraform_version must be a string or an object keyed by environment: %w", err)
+	}
+	*v = TerraformVersion{Default: perEnvironment["default"], Environments: map[string]string{}}
+	for env, version := range perEnvironment {
+		if env != "default" {
+			v.Environments[env] = version
+		}
+	}
+	return nil
+}
+
+func (v TerraformVersion) MarshalJSON() ([]byte, error) {
+	if len(v.Environments) == 0 {
+		return json.Marshal(v.Default)
+	}
+	perEnvironment := map[string]string{"default": v.Default}
+	for env, version := range v.Environments {
+		perEnvironment[env] = version
+	}
+	return json.Marshal(perEnvironment)
 }
 
 type Provider struct {
-	Name          string            `json:"name"`
-	Source        string            `json:"source"`
-	Version       string            `json:"version"`
-	AuthVariables map[string]string `json:"auth_variables"`
+	Name          string                      `json:"name"`
+	Source        string                      `json:"source"`
+	Version       string                      `json:"version"`
+	AuthVariables map[string]string           `json:"auth_variables"`
+	Settings      map[string]interface{}      `json:"settings,omitempty"`     // Extra attributes rendered into the provider block
+	Features      map[string]interface{}      `json:"features,omitempty"`     // azurerm features block; nested maps render as nested blocks
+	Environments  map[string]ProviderOverride `json:"environments,omitempty"` // Per-environment settings merged over the base
+	AssumeRole    *AssumeRole                 `json:"assume_role,omitempty"`  // aws only: role assumed from a federated session instead of static keys
+	Retry         *ProviderRetry              `json:"retry,omitempty"`        // Retry and timeout settings for flaky APIs
+}
+
+// Retry modes accepted by the aws provider
+const (
+	RetryModeStandard = "standard"
+	RetryModeAdaptive = "adaptive" // Also rate limits client-side when the API throttles
+)
+
+// ProviderRetry holds the retry and timeout settings rendered into the provider block; unset settings are omitted.
+// Each setting only applies to the providers that support it.
+type ProviderRetry struct {
+	MaxRetries     *int   `json:"max_retries,omitempty"`     // aws; 0 disables retries
+	Mode           string `json:"mode,omitempty"`            // aws: standard or adaptive
+	RequestTimeout string `json:"request_timeout,omitempty"` // google: timeout for each API request, e.g. "60s"
+}
+
+// defaultSessionName is the assume_role session name used when none is configured.
+const defaultSessionName = "terraform-session"
+
+// AssumeRole is the aws provider's assume_role block for federated access. The AWS provider cannot exchange a SAML
+// assertion itself, so a credential helper such as saml2aws stores the federated session in Profile and Terraform
+// assumes RoleARN from it.
+type AssumeRole struct {
+	RoleARN         string `json:"role_arn"`                    // e.g. "arn:aws:iam::123456789012:role/terraform"
+	SessionName     string `json:"session_name,omitempty"`      // Defaults to terraform-session
+	ExternalID      string `json:"external_id,omitempty"`       // Required by some cross-account trust policies
+	Profile         string `json:"profile,omitempty"`           // Shared credentials profile holding the federated session
+	SAMLProviderARN string `json:"saml_provider_arn,omitempty"` // Principal ARN of the SAML identity provider the profile's session comes from; requires profile
+}
+
+// Session returns the configured session name or the default
+func (a AssumeRole) Session() string {
+	if a.SessionName != "" {
+		return a.SessionName
+	}
+	return defaultSessionName
+}
+
+// ProviderOverride holds provider settings that apply to a single environment
+type ProviderOverride struct {
+	AuthVariables map[string]string      `json:"auth_variables,omitempty"`
+	Settings      map[string]interface{} `json:"settings,omitempty"`
+	AssumeRole    *AssumeRole            `json:"assume_role,omitempty"` // Replaces the base assume_role, e.g. to target another account
+}
+
+// ForEnvironment returns the provider with the overrides for env merged over the base settings
+func (p Provider) ForEnvironment(env string) Provider {
+	override, ok := p.Environments[env]
+	if !ok {
+		return p
+	}
+
+	merged := p
+	merged.AuthVariables = make(map[string]string, len(p.AuthVariables)+len(override.AuthVariables))
+	for key, value := range p.AuthVariables {
+		merged.AuthVariables[key] = value
+	}
+	for key, value := range override.AuthVariables {
+		merged.AuthVariables[key] = value
+	}
+	merged.Settings = make(map[string]interface{}, len(p.Settings)+len(override.Settings))
+	for key, value := range p.Settings {
+		merged.Settings[key] = value
+	}
+	for key, value := range override.Settings {
+		merged.Settings[key] = value
+	}
+	if override.AssumeRole != nil {
+		merged.AssumeRole = override.AssumeRole
+	}
+	return merged
 }
 
 type Backend struct {
-	Type               string            `json:"type"`
-	Parameters         map[string]string `json:"parameters"`
-	ResourceGroupName  string            `json:"resource_group_name"`
-	StorageAccountName string            `json:"storage_account_name"`
-	ContainerName      string            `json:"container_name"`
-	Key                string            `json:"key"`
-	SubscriptionId     string            `json:"subscription_id"`
-	TenantID           string            `json:"tenant_id"`
-	ClientID           string            `json:"client_id"`
-	AccessKey          string            `json:"access_key"`
+	Type               string             `json:"type"`
+	Parameters         map[string]string  `json:"parameters"`
+	ResourceGroupName  string             `json:"resource_group_name"`
+	StorageAccountName string             `json:"storage_account_name"`
+	ContainerName      string             `json:"container_name"`
+	Key                string             `json:"key"`
+	SubscriptionId     string             `json:"subscription_id"`
+	TenantID           string             `json:"tenant_id"`
+	ClientID           string             `json:"client_id"`
+	AccessKey          string             `json:"access_key"`
+	Bucket             string             `json:"bucket,omitempty"`               // s3 and gcs
+	Region             string             `json:"region,omitempty"`               // s3; independent of the resource region, which it defaults to
+	Prefix             string             `json:"prefix,omitempty"`               // gcs
+	WorkspaceKeyPrefix string             `json:"workspace_key_prefix,omitempty"` // s3 with workspaces; state is stored under <prefix>/<workspace>/<key>
+	DynamoDBTable      string             `json:"dynamodb_table,omitempty"`       // s3 state locking table
+	RequireLocking     bool               `json:"require_locking,omitempty"`      // Fail generation when the backend has no state locking configured
+	FromEnv            map[string]string  `json:"from_env,omitempty"`             // Backend field name -> environment variable read at generation time
+	Environments       map[string]Backend `json:"environments,omitempty"`         // Backends replacing this one for an environment, e.g. {"nonprod": {"type": "local"}}
+}
+
+// BackendLocal is the backend type that keeps state on disk; it needs no backend tfvars.
+const BackendLocal = "local"
+
+// ForEnvironment returns the backend configured for env, falling back to this one
+func (b Backend) ForEnvironment(env string) Backend {
+	if override, ok := b.Environments[env]; ok {
+		return override
+	}
+	return b
+}
+
+// Field returns a pointer to the backend field with the given JSON name, or nil if there is none
+func (b *Backend) Field(name string) *string {
+	switch name {
+	case "resource_group_name":
+		return &b.ResourceGroupName
+	case "storage_account_name":
+		return &b.StorageAccountName
+	case "container_name":
+		return &b.ContainerName
+	case "key":
+		return &b.Key
+	case "subscription_id":
+		return &b.SubscriptionId
+	case "tenant_id":
+		return &b.TenantID
+	case "client_id":
+		return &b.ClientID
+	case "access_key":
+		return &b.AccessKey
+	case "bucket":
+		return &b.Bucket
+	case "region":
+		return &b.Region
+	case "prefix":
+		return &b.Prefix
+	case "workspace_key_prefix":
+		return &b.WorkspaceKeyPrefix
+	case "dynamodb_table":
+		return &b.DynamoDBTable
+	default:
+		return nil
+	}
+}
+
+// Value returns the backend setting with the given JSON name, falling back to the free-form parameters
+func (b Backend) Value(name string) string {
+	if field := b.Field(name); field != nil && *field != "" {
+		return *field
+	}
+	return b.Parameters[name]
 }
 
 type Module struct {
-	ModuleName string                    `json:"module_name"`
-	Source     string                    `json:"source"`
-	Variables  map[string]ModuleVariable `json:"variables"`
-	Outputs    map[string]ModuleOutput   `json:"outputs,omitempty"`
-	DependsOn  []string                  `json:"depends_on,omitempty"`
+	ModuleName           string                    `json:"module_name"`
+	Label                string                    `json:"label,omitempty"` // Block label; defaults to the module name
+	Source               string                    `json:"source"`
+	Variables            map[string]ModuleVariable `json:"variables"`
+	Outputs              map[string]ModuleOutput   `json:"outputs,omitempty"`
+	DependsOn            []string                  `json:"depends_on,omitempty"`            // Labels of the modules this one depends on
+	Lifecycle            *Lifecycle                `json:"lifecycle,omitempty"`             // Lifecycle settings applied to the module's resources
+	ConfigurationAliases []string                  `json:"configuration_aliases,omitempty"` // Provider aliases the caller must pass in, e.g. ["primary", "secondary"]
+	Count                map[string]int            `json:"count,omitempty"`                 // Instances per environment with an optional "default" entry, e.g. {"prod": 3, "default": 1}
+}
+
+// Resource is a resource block declared directly in the configuration rather than in a module template
+type Resource struct {
+	Type      string                 `json:"type"` // e.g. "aws_s3_bucket"
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"` // A list of objects renders as one nested block per object
+	Providers []string               `json:"providers,omitempty"` // Only emit for these providers; empty means all
+	Count     map[string]int         `json:"count,omitempty"`     // Instances per environment with an optional "default" entry, e.g. {"prod": 3, "default": 1}
+}
+
+// CountVariable names the variable holding the resource's per-environment count
+func (r Resource) CountVariable() string {
+	return r.Type + "_" + r.Name + "_count"
+}
+
+// Lifecycle holds the settings rendered into a resource's lifecycle block
+type Lifecycle struct {
+	PreventDestroy      bool     `json:"prevent_destroy,omitempty"`
+	CreateBeforeDestroy bool     `json:"create_before_destroy,omitempty"`
+	IgnoreChanges       []string `json:"ignore_changes,omitempty"` // Attribute names, or "all"
+}
+
+// CountVariable names the variable holding the module's per-environment count
+func (m Module) CountVariable() string {
+	return m.BlockLabel() + "_count"
+}
+
+// BlockLabel returns the label used for the module block, falling back to the module name
+func (m Module) BlockLabel() string {
+	if m.Label != "" {
+		return m.Label
+	}
+	return m.ModuleName
 }
 
 // Embed Variable within ModuleVariable
@@ -52,13 +403,21 @@ type ModuleOutput struct {
 }
 
 type Variable struct {
-	Type        string                 `json:"type"`
-	Description string                 `json:"description"`
-	Default     interface{}            `json:"default,omitempty"`
-	Sensitive   bool                   `json:"sensitive,omitempty"`
-	Value       interface{}            `json:"value,omitempty"`
-	Attributes  map[string]interface{} `json:"attributes,omitempty"` // Add attributes for object/tuple types
-	Validation  *Validation            `json:"validation,omitempty"`
+	Type           string                 `json:"type"`
+	Description    string                 `json:"description"`
+	Default        interface{}            `json:"default,omitempty"`
+	Sensitive      bool                   `json:"sensitive,omitempty"`
+	Nullable       *bool                  `json:"nullable,omitempty"` // Rendered as nullable = <value> when set; false forbids null values (Terraform 1.1+)
+	Value          interface{}            `json:"value,omitempty"`
+	Attributes     map[string]interface{} `json:"attributes,omitempty"` // Add attributes for object/tuple types
+	Validation     *Validation            `json:"validation,omitempty"`
+	Providers      []string               `json:"providers,omitempty"`       // Only emit for these providers; empty means all
+	LiteralDollar  bool                   `json:"literal_dollar,omitempty"`  // Escape "${" so Terraform does not interpolate it
+	PerEnvironment bool                   `json:"per_environment,omitempty"` // Default is a map keyed by environment, with an optional "default" entry
+	Deprecated     string                 `json:"deprecated,omitempty"`      // Migration note rendered as a "# DEPRECATED:" comment, e.g. "use vnet_name instead"
+	Category       string                 `json:"category,omitempty"`        // Groups the declaration in variables.tf under a "# --- <category> ---" header
+	Expression     bool                   `json:"expression,omitempty"`      // Default and value are HCL expressions rendered verbatim, e.g. "var.env == \"prod\" ? 3 : 1"
+	Heredoc        bool                   `json:"heredoc,omitempty"`         // String default and value are rendered as <<-EOT heredocs, e.g. for an embedded policy document
 }
 
 type Validation struct {
@@ -0,0 +1,36 @@
+// backend/models/fileresult.go
+
+package models
+
+// Actions reported for a generated file.
+const (
+	FileCreated     = "created"
+	FileOverwritten = "overwritten"
+	FileSkipped     = "skipped"
+	FileProtected   = "protected" // A hand-maintained file matching a protected pattern; never rewritten
+	FileRemoved     = "removed"   // A file an earlier run generated that this run no longer does
+)
+
+// FileResult describes what generation did with a single file or directory. A directory is created, or skipped
+// when it already existed.
+type FileResult struct {
+	Path      string `json:"path"`
+	Action    string `json:"action"`
+	Bytes     int    `json:"bytes"`
+	SHA256    string `json:"sha256,omitempty"`    // Hash of the file's content after generation
+	Directory bool   `json:"directory,omitempty"` // The result is for an output directory rather than a file
+}
+
+// OrganisationResult reports the generation run for one organisation of a multi-organisation request.
+type OrganisationResult struct {
+	Organisation string       `json:"organisation"`
+	Files        []FileResult `json:"files"`
+	Error        string       `json:"error,omitempty"`
+}
+
+// GenerateResponse is returned by the generate endpoint.
+type GenerateResponse struct {
+	Message       string               `json:"message"`
+	Files         []FileResult         `json:"files"`
+	Organisations []OrganisationResult `json:"organisations,omitempty"` // Per-organisation results when the request lists several
+}
@@ -2,10 +2,47 @@
 
 package models
 
+// Output formats for the generated Terraform configuration
+const (
+	OutputFormatHCL   = "hcl"   // Native syntax (.tf), the default
+	OutputFormatJSON  = "json"  // JSON configuration syntax (.tf.json)
+	OutputFormatCDKTF = "cdktf" // cdktf.json, a variables manifest and a main.ts stub for CDK for Terraform
+)
+
 type GenerateRequest struct {
-	OrganisationName string   `json:"organisation_name"`
-	ProductName      string   `json:"product_name"`
-	Customers        []string `json:"customers,omitempty"`
-	Provider         string   `json:"provider"`
-	Modules          []string `json:"modules"`
+	OrganisationName    string                 `json:"organisation_name"`
+	Organisations       []string               `json:"organisations,omitempty"` // Further organisations to generate the product for
+	ProductName         string                 `json:"product_name"`
+	Customers           []string               `json:"customers,omitempty"`
+	Provider            string                 `json:"provider"`
+	Modules             []string               `json:"modules"`
+	Region              string                 `json:"region,omitempty"`               // Overrides the configured region
+	Environments        []string               `json:"environments,omitempty"`         // Environments to generate; defaults to nonprod and prod
+	NoOverwrite         bool                   `json:"no_overwrite,omitempty"`         // Skip files that already exist instead of replacing them
+	Extra               map[string]interface{} `json:"extra,omitempty"`                // Ad-hoc values exposed to templates as .Extra
+	GenerateCodeowners  bool                   `json:"generate_codeowners,omitempty"`  // Scaffold .github/CODEOWNERS and a pull request template
+	GenerateLinters     bool                   `json:"generate_linters,omitempty"`     // Scaffold .tflint.hcl and .checkov.yaml for the provider
+	RequestedBy         string                 `json:"requested_by,omitempty"`         // Recorded in the generation log
+	OutputFormat        string                 `json:"output_format,omitempty"`        // hcl (default), json or cdktf
+	Tags                map[string]string      `json:"tags,omitempty"`                 // Provider default tags; override the configured default_tags
+	NoTfvarsComments    bool                   `json:"no_tfvars_comments,omitempty"`   // Omit description comments above tfvars values
+	AllowMissingKeys    bool                   `json:"allow_missing_keys,omitempty"`   // Render missing template keys as empty instead of failing
+	NoVars              bool                   `json:"no_vars,omitempty"`              // Skip vars.tfvars and per-customer vars files; values are supplied externally
+	RequireValues       bool                   `json:"require_values,omitempty"`       // Fail before writing when a required variable has neither a default nor a value
+	GenerateReadme      bool                   `json:"generate_readme,omitempty"`      // Scaffold README.md with terraform-docs markers
+	GeneratePreCommit   bool                   `json:"generate_pre_commit,omitempty"`  // Scaffold .pre-commit-config.yaml with the Terraform hooks
+	GenerateEnvrc       bool                   `json:"generate_envrc,omitempty"`       // Scaffold a direnv .envrc exporting the configured proxy
+	GenerateAtlantis    bool                   `json:"generate_atlantis,omitempty"`    // Write atlantis.yaml listing every generated project and environment
+	GenerateWorkflow    bool                   `json:"generate_workflow,omitempty"`    // Scaffold a GitHub Actions workflow running fmt, validate and plan per environment
+	GenerateRootMain    bool                   `json:"generate_root_main,omitempty"`   // Write an organisation-level main.tf calling every customer directory as a module
+	GenerateTeardown    bool                   `json:"generate_teardown,omitempty"`    // Scaffold teardown.sh destroying every environment after confirmation
+	GenerateCredentials bool                   `json:"generate_credentials,omitempty"` // Scaffold CREDENTIALS.md listing the environment variables the provider authenticates with
+	GeneratePolicy      bool                   `json:"generate_policy,omitempty"`      // Scaffold a policy/ directory with a starter Rego policy and conftest.toml for the provider
+	GenerateVarsSchema  bool                   `json:"generate_vars_schema,omitempty"` // Write schema/variables.tf.json, the declarations of variables.tf in JSON syntax
+	GenerateBootstrap   bool                   `json:"generate_bootstrap,omitempty"`   // Write an organisation-level bootstrap/ configuration creating the backend storage
+	Debug               bool                   `json:"debug,omitempty"`                // Log the template data of each product or customer, with sensitive values redacted
+	Flavor              string                 `json:"flavor,omitempty"`               // Template set under templates/<flavor>/<provider>; empty uses templates/<provider>
+	VariableDefaults    map[string]string      `json:"variable_defaults,omitempty"`    // Default overrides by variable name, parsed as the declared type; the API also reads var.<name> query parameters
+	MigrateBackendFrom  string                 `json:"migrate_backend_from,omitempty"` // Previous backend type (local, s3, azurerm or gcs); scaffolds migrate-backend.sh
+	DryRun              bool                   `json:"-"`                              // Render and hash every file without writing anything; set by replays
 }
@@ -0,0 +1,20 @@
+// backend/models/inventory.go
+
+package models
+
+// Inventory summarises the customers produced by a multi-customer run for downstream systems such as a CMDB.
+type Inventory struct {
+	Organisation string              `json:"organisation"`
+	Product      string              `json:"product"`
+	Customers    []InventoryCustomer `json:"customers"`
+}
+
+// InventoryCustomer describes the directory generated for one customer.
+type InventoryCustomer struct {
+	Name         string   `json:"name"`
+	Path         string   `json:"path"`
+	Provider     string   `json:"provider"` // Terraform provider name, e.g. azurerm
+	Region       string   `json:"region"`   // After the request's override and the customer's patch
+	Environments []string `json:"environments"`
+	Files        []string `json:"files"`
+}
@@ -0,0 +1,31 @@
+// backend/models/manifest.go
+
+package models
+
+// Drift statuses reported when regenerating from a manifest.
+const (
+	DriftChanged = "changed" // The regenerated file's hash differs from the manifest
+	DriftMissing = "missing" // The manifest lists a file that was not regenerated
+	DriftAdded   = "added"   // A file was regenerated that the manifest does not list
+)
+
+// Manifest records the request behind a generation run and the SHA-256 of every file it produced.
+type Manifest struct {
+	Request GenerateRequest   `json:"request"`
+	Files   map[string]string `json:"files"` // Path -> hex SHA-256
+}
+
+// FileDrift describes a file whose regenerated content does not match the manifest.
+type FileDrift struct {
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// ReplayResult is returned when regenerating from a manifest.
+type ReplayResult struct {
+	Match bool         `json:"match"`
+	Drift []FileDrift  `json:"drift"`
+	Files []FileResult `json:"files"`
+}
@@ -0,0 +1,13 @@
+// backend/models/matrix.go
+
+package models
+
+// MatrixResult summarises the generation run for one row of a provider matrix.
+type MatrixResult struct {
+	Customer     string   `json:"customer"`
+	Provider     string   `json:"provider"`
+	Region       string   `json:"region,omitempty"`
+	Environments []string `json:"environments,omitempty"`
+	Files        int      `json:"files"`
+	Error        string   `json:"error,omitempty"`
+}
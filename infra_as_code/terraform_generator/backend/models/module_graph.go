@@ -0,0 +1,25 @@
+// backend/models/module_graph.go
+
+package models
+
+// ModuleGraph is the dependency graph of the module calls a request generates.
+type ModuleGraph struct {
+	Nodes []ModuleGraphNode `json:"nodes"`
+	Edges []ModuleGraphEdge `json:"edges"`
+}
+
+// ModuleGraphNode is a module call, identified by its block label.
+type ModuleGraphNode struct {
+	Label   string `json:"label"`
+	Module  string `json:"module"`
+	Source  string `json:"source"`
+	Missing bool   `json:"missing,omitempty"` // Referenced by an input but not part of the request
+}
+
+// ModuleGraphEdge points from a module call to one it depends on.
+type ModuleGraphEdge struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	DependsOn bool     `json:"depends_on,omitempty"` // Listed in the module's depends_on
+	Outputs   []string `json:"outputs,omitempty"`    // Outputs of To that inputs of From reference
+}
@@ -0,0 +1,10 @@
+// backend/models/provider_resolution.go
+
+package models
+
+// ProviderResolution describes how a provider input is resolved against the configuration
+type ProviderResolution struct {
+	Input      string `json:"input"`
+	Normalized string `json:"normalized"` // Terraform provider name from the alias map; empty if the input is not a known alias
+	Found      bool   `json:"found"`      // A provider with the normalized name exists in the configuration
+}
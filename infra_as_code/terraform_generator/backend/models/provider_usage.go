@@ -0,0 +1,11 @@
+// backend/models/provider_usage.go
+
+package models
+
+// ProviderUsage compares the providers defined in the configuration with the provider inputs of a set of requests
+type ProviderUsage struct {
+	Requested []string `json:"requested"` // Inputs checked, e.g. the provider of every matrix row
+	Used      []string `json:"used"`      // Configured providers at least one input resolves to
+	Unused    []string `json:"unused"`    // Configured providers no input resolves to
+	Unknown   []string `json:"unknown"`   // Inputs that resolve to no configured provider
+}
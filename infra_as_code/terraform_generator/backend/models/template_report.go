@@ -0,0 +1,10 @@
+// backend/models/template_report.go
+
+package models
+
+// TemplateReport lists template files, relative to the templates directory, that generation never renders and
+// that it references but cannot find.
+type TemplateReport struct {
+	Unused  []string `json:"unused"`
+	Missing []string `json:"missing"`
+}
@@ -0,0 +1,106 @@
+// backend/services/atlantis_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// atlantisConfigFile is written to the organisation directory, the common root of every generated project.
+const atlantisConfigFile = "atlantis.yaml"
+
+// atlantisProject is one Atlantis project: a generated directory planned against one environment.
+type atlantisProject struct {
+	Name          string   // <entity>-<env>; also names the project's workflow
+	Dir           string   // Relative to the organisation directory
+	BackendConfig string   // Passed to terraform init as -backend-config, relative to Dir; empty for the local backend
+	VarFile       string   // Passed to terraform plan as -var-file, relative to Dir; empty when vars are not generated
+	AutoplanPaths []string // when_modified patterns, relative to Dir
+}
+
+// atlantisProjects lists a project per generated product or customer directory and environment, using the same
+// targets the environment files are rendered from.
+func atlantisProjects(req *models.GenerateRequest, config *models.Config, basePath string) ([]atlantisProject, error) {
+	// Customers are generated instead of the product, each with its own vars files
+	provider := utils.NormalizeProviderName(req.Provider)
+	productPath, err := OutputDir(config, provider, req.OrganisationName, req.ProductName, "")
+	if err != nil {
+		return nil, err
+	}
+	entities := map[string]string{req.ProductName: productPath}
+	order := []string{req.ProductName}
+	withVars := len(req.Customers) > 0
+	if withVars {
+		entities = make(map[string]string, len(req.Customers))
+		order = nil
+		for _, customer := range req.Customers {
+			customer = strings.TrimSpace(customer)
+			if entities[customer], err = OutputDir(config, provider, req.OrganisationName, req.ProductName, customer); err != nil {
+				return nil, err
+			}
+			order = append(order, customer)
+		}
+	}
+
+	var projects []atlantisProject
+	for _, entity := range order {
+		path := entities[entity]
+		dir, err := filepath.Rel(basePath, path)
+		if err != nil {
+			return nil, err
+		}
+		// The configured output path decides how deep the directory sits below the shared modules
+		modulesDir, err := filepath.Rel(path, filepath.Join(basePath, "modules"))
+		if err != nil {
+			return nil, err
+		}
+		inputs, err := planInputs(req, config, path, entity, withVars)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, input := range inputs {
+			project := atlantisProject{
+				Name:          entity + "-" + input.Environment,
+				Dir:           dir,
+				BackendConfig: input.BackendConfig,
+				VarFile:       input.VarFile,
+				AutoplanPaths: []string{"*.tf", "*.tf.json"},
+			}
+			if project.BackendConfig != "" {
+				project.AutoplanPaths = append(project.AutoplanPaths, project.BackendConfig)
+			}
+			if project.VarFile != "" {
+				project.AutoplanPaths = append(project.AutoplanPaths, project.VarFile)
+			}
+			project.AutoplanPaths = append(project.AutoplanPaths, filepath.ToSlash(modulesDir)+"/**/*.tf")
+			projects = append(projects, project)
+		}
+	}
+	return projects, nil
+}
+
+// generateAtlantisConfig writes atlantis.yaml listing every project generated by the request.
+func generateAtlantisConfig(req *models.GenerateRequest, config *models.Config, basePath string, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	if req.OutputFormat == models.OutputFormatCDKTF {
+		return nil, fmt.Errorf("atlantis configuration is not supported for the %s output format", models.OutputFormatCDKTF)
+	}
+	projects, err := atlantisProjects(req, config, basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"OrganisationName": req.OrganisationName,
+		"ProductName":      req.ProductName,
+		"Projects":         projects,
+	}
+	files := []templateFile{
+		{Template: filepath.Join(templatesDir, "generic", "atlantis.yaml.tmpl"), Dest: filepath.Join(basePath, atlantisConfigFile)},
+	}
+	return renderFiles(files, data, opts)
+}
@@ -0,0 +1,110 @@
+// backend/services/bootstrap_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// bootstrapDir is the organisation-level configuration that creates the remote state storage.
+const bootstrapDir = "bootstrap"
+
+// bootstrapProviders maps backend types to the provider that creates their storage.
+var bootstrapProviders = map[string]string{
+	"s3":      "aws",
+	"gcs":     "google",
+	"azurerm": "azurerm",
+}
+
+// bootstrapTarget is the state storage one bootstrap configuration creates.
+type bootstrapTarget struct {
+	Dir         string
+	Environment string // Empty for the base backend
+	Backend     models.Backend
+}
+
+// storageKey identifies the storage a backend keeps state in, ignoring where in it the state is stored
+func storageKey(backend models.Backend) string {
+	fields := []string{backend.Type}
+	for _, field := range []string{"bucket", "dynamodb_table", "resource_group_name", "storage_account_name", "container_name"} {
+		fields = append(fields, backend.Value(field))
+	}
+	return strings.Join(fields, "|")
+}
+
+// bootstrapTargets returns the base backend, written to bootstrap/, followed by every environment backend with storage
+// of its own, written to bootstrap/<env>/. Local backends need no storage.
+func bootstrapTargets(config *models.Config, basePath string) []bootstrapTarget {
+	var targets []bootstrapTarget
+	seen := make(map[string]bool)
+	add := func(dir, env string, backend models.Backend) {
+		if backend.Type == "" {
+			backend.Type = "azurerm"
+		}
+		if backend.Type == models.BackendLocal || seen[storageKey(backend)] {
+			return
+		}
+		seen[storageKey(backend)] = true
+		targets = append(targets, bootstrapTarget{Dir: dir, Environment: env, Backend: backend})
+	}
+
+	base := filepath.Join(basePath, bootstrapDir)
+	add(base, "", config.Backend)
+	envs := make([]string, 0, len(config.Backend.Environments))
+	for env := range config.Backend.Environments {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	for _, env := range envs {
+		add(filepath.Join(base, env), env, config.Backend.Environments[env])
+	}
+	return targets
+}
+
+// generateBootstrap writes a configuration with a local backend that creates the storage the generated backends
+// keep their state in, so it can be applied before the first terraform init.
+func generateBootstrap(req *models.GenerateRequest, config *models.Config, basePath string, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	if config.Cloud != nil {
+		return nil, fmt.Errorf("nothing to bootstrap: HCP Terraform stores the state of a cloud block")
+	}
+	targets := bootstrapTargets(config, basePath)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("nothing to bootstrap: every backend is local")
+	}
+
+	var results []models.FileResult
+	for _, target := range targets {
+		name, ok := bootstrapProviders[target.Backend.Type]
+		if !ok {
+			return results, fmt.Errorf("bootstrapping a %s backend is not supported", target.Backend.Type)
+		}
+		// Pin the provider the way the configuration does when it is configured, e.g. aws for an s3 backend
+		provider := models.Provider{Name: name, Source: "hashicorp/" + name}
+		if configured := utils.FilterProviderData(config.Providers, name); configured != nil {
+			provider = *configured
+		}
+		provider.Source = utils.ResolveProviderSource(provider.Source, config.ProviderSourceHost)
+
+		data := map[string]interface{}{
+			"OrganisationName": req.OrganisationName,
+			"Environment":      target.Environment,
+			"Backend":          target.Backend,
+			"Provider":         provider,
+			"TerraformVersion": config.TerraformVersion.Default,
+		}
+		files := []templateFile{
+			{Template: filepath.Join(templatesDir, "generic", "bootstrap.tf.tmpl"), Dest: filepath.Join(target.Dir, "main.tf")},
+		}
+		rendered, err := renderFiles(files, data, opts)
+		results = append(results, rendered...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
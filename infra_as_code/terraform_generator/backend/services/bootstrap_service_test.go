@@ -0,0 +1,98 @@
+// backend/services/bootstrap_service_test.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBootstrapTargets(t *testing.T) {
+	config := &models.Config{Backend: models.Backend{
+		Type:   "s3",
+		Bucket: "acme-state",
+		Key:    "app.tfstate",
+		Region: "eu-west-1",
+		Environments: map[string]models.Backend{
+			// Same storage as the base backend under another key
+			"test": {Type: "s3", Bucket: "acme-state", Key: "test.tfstate", Region: "eu-west-1"},
+			"prod": {Type: "s3", Bucket: "acme-prod-state", Region: "eu-west-1"},
+		},
+	}}
+
+	targets := bootstrapTargets(config, "out")
+	want := []struct{ dir, env, bucket string }{
+		{filepath.Join("out", bootstrapDir), "", "acme-state"},
+		{filepath.Join("out", bootstrapDir, "prod"), "prod", "acme-prod-state"},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("bootstrapTargets() = %+v, want %d targets", targets, len(want))
+	}
+	for i, w := range want {
+		if targets[i].Dir != w.dir || targets[i].Environment != w.env || targets[i].Backend.Bucket != w.bucket {
+			t.Errorf("target %d = %s (%q, %s), want %s (%q, %s)", i, targets[i].Dir, targets[i].Environment, targets[i].Backend.Bucket, w.dir, w.env, w.bucket)
+		}
+	}
+
+	// A lock table of its own is separate storage even in the same bucket
+	config.Backend.Environments["test"] = models.Backend{Type: "s3", Bucket: "acme-state", DynamoDBTable: "locks"}
+	if targets := bootstrapTargets(config, "out"); len(targets) != 3 || targets[2].Environment != "test" {
+		t.Errorf("bootstrapTargets() with a lock table = %+v, want test bootstrapped last", targets)
+	}
+
+	// The base type defaults to azurerm, and local backends need no storage
+	config = &models.Config{Backend: models.Backend{ResourceGroupName: "rg-state", StorageAccountName: "stacc", ContainerName: "tfstate"}}
+	if targets := bootstrapTargets(config, "out"); len(targets) != 1 || targets[0].Backend.Type != "azurerm" {
+		t.Errorf("bootstrapTargets() without a type = %+v, want one azurerm target", targets)
+	}
+	config = &models.Config{Backend: models.Backend{Type: models.BackendLocal, Environments: map[string]models.Backend{"prod": {Type: models.BackendLocal}}}}
+	if targets := bootstrapTargets(config, "out"); len(targets) != 0 {
+		t.Errorf("bootstrapTargets() for local backends = %+v, want none", targets)
+	}
+}
+
+func TestGenerateBootstrap(t *testing.T) {
+	chdirBackendRoot(t)
+
+	config := &models.Config{
+		TerraformVersion: models.TerraformVersion{Default: ">= 1.5.0"},
+		Providers:        []models.Provider{{Name: "aws", Source: "hashicorp/aws", Version: "~> 5.0"}},
+		Backend:          models.Backend{Type: "s3", Bucket: "acme-state", Region: "eu-west-1", DynamoDBTable: "acme-locks"},
+	}
+	req := &models.GenerateRequest{OrganisationName: "acme"}
+	out := t.TempDir()
+
+	results, err := generateBootstrap(req, config, out, utils.GenerateOptions{Overwrite: true, Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != filepath.Join(out, bootstrapDir, "main.tf") {
+		t.Fatalf("generateBootstrap() = %+v, want bootstrap/main.tf", results)
+	}
+	content, err := os.ReadFile(results[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`version = "~> 5.0"`, `required_version = ">= 1.5.0"`, `bucket = "acme-state"`, `"acme-locks"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("bootstrap/main.tf has no %s:\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), "backend \"") {
+		t.Errorf("bootstrap/main.tf declares a backend, want its state local:\n%s", content)
+	}
+
+	for name, config := range map[string]*models.Config{
+		"cloud":       {Cloud: &models.Cloud{}},
+		"local":       {Backend: models.Backend{Type: models.BackendLocal}},
+		"unsupported": {Backend: models.Backend{Type: "consul"}},
+	} {
+		if _, err := generateBootstrap(req, config, out, utils.GenerateOptions{DryRun: true}); err == nil {
+			t.Errorf("%s: generateBootstrap() succeeded, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// backend/services/cdktf_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+)
+
+// generateCDKTFFiles creates cdktf.json, a variables.json manifest of the catalog variables and a main.ts stub
+// so the configuration catalog can be reused from a CDK for Terraform program.
+func generateCDKTFFiles(req *models.GenerateRequest, config *models.Config, path string, data map[string]interface{}, provider *models.Provider, modules []models.Module, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	variables := templateVariables(req, config, config.Environment)
+	documents := []struct {
+		Dest    string
+		Content map[string]interface{}
+	}{
+		{Dest: filepath.Join(path, "cdktf.json"), Content: utils.CDKTFConfig(*provider, utils.FilterLinkedProviders(config.LinkedProviders, req.Provider), modules)},
+		{Dest: filepath.Join(path, "variables.json"), Content: utils.VariablesJSON(variables)},
+	}
+
+	var results []models.FileResult
+	for _, document := range documents {
+		result, err := utils.WriteJSONFile(document.Dest, document.Content, opts)
+		if err != nil {
+			return results, fmt.Errorf("error generating %s: %w", document.Dest, err)
+		}
+		results = append(results, result)
+	}
+
+	stub, err := renderFiles([]templateFile{
+		{Template: filepath.Join(templatesDir, "cdktf", "main.ts.tmpl"), Dest: filepath.Join(path, "main.ts")},
+	}, data, opts)
+	return append(results, stub...), err
+}
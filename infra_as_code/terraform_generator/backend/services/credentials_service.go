@@ -0,0 +1,108 @@
+// backend/services/credentials_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// credentialsFile is the onboarding guide scaffolded into each product or customer directory.
+const credentialsFile = "CREDENTIALS.md"
+
+// credentialMethod is one way of authenticating a provider, with the environment variables it reads
+type credentialMethod struct {
+	Name      string
+	Command   string               // Command that sets up the credentials, e.g. "az login"; empty when none is needed
+	Variables []credentialVariable // Environment variables the provider reads for this method
+}
+
+// credentialVariable is an environment variable a provider reads credentials or settings from
+type credentialVariable struct {
+	Name        string
+	Description string
+}
+
+// providerCredentials lists the authentication methods of each supported Terraform provider, most common first.
+var providerCredentials = map[string][]credentialMethod{
+	"azurerm": {
+		{Name: "Azure CLI", Command: "az login", Variables: []credentialVariable{
+			{"ARM_SUBSCRIPTION_ID", "Subscription to deploy to"},
+		}},
+		{Name: "Service principal with a client secret", Variables: []credentialVariable{
+			{"ARM_CLIENT_ID", "Application (client) ID of the service principal"},
+			{"ARM_CLIENT_SECRET", "Client secret of the service principal"},
+			{"ARM_TENANT_ID", "Microsoft Entra tenant ID"},
+			{"ARM_SUBSCRIPTION_ID", "Subscription to deploy to"},
+		}},
+		{Name: "OpenID Connect, e.g. from GitHub Actions", Variables: []credentialVariable{
+			{"ARM_USE_OIDC", "Set to true"},
+			{"ARM_CLIENT_ID", "Application (client) ID of the federated identity"},
+			{"ARM_TENANT_ID", "Microsoft Entra tenant ID"},
+			{"ARM_SUBSCRIPTION_ID", "Subscription to deploy to"},
+		}},
+		{Name: "Managed identity", Variables: []credentialVariable{
+			{"ARM_USE_MSI", "Set to true"},
+			{"ARM_CLIENT_ID", "Client ID of a user-assigned identity; leave unset for the system-assigned identity"},
+			{"ARM_TENANT_ID", "Microsoft Entra tenant ID"},
+			{"ARM_SUBSCRIPTION_ID", "Subscription to deploy to"},
+		}},
+	},
+	"aws": {
+		{Name: "Named profile, e.g. from AWS SSO", Command: "aws sso login --profile <profile>", Variables: []credentialVariable{
+			{"AWS_PROFILE", "Profile in ~/.aws/config to use"},
+			{"AWS_REGION", "Region to deploy to, unless set in the configuration"},
+		}},
+		{Name: "Access keys", Variables: []credentialVariable{
+			{"AWS_ACCESS_KEY_ID", "Access key ID"},
+			{"AWS_SECRET_ACCESS_KEY", "Secret access key"},
+			{"AWS_SESSION_TOKEN", "Session token, for temporary credentials only"},
+			{"AWS_REGION", "Region to deploy to, unless set in the configuration"},
+		}},
+		{Name: "Web identity, e.g. OpenID Connect from CI", Variables: []credentialVariable{
+			{"AWS_ROLE_ARN", "ARN of the role to assume"},
+			{"AWS_WEB_IDENTITY_TOKEN_FILE", "Path to the OIDC token file"},
+			{"AWS_REGION", "Region to deploy to, unless set in the configuration"},
+		}},
+	},
+	"google": {
+		{Name: "Application default credentials", Command: "gcloud auth application-default login", Variables: []credentialVariable{
+			{"GOOGLE_PROJECT", "Project to deploy to, unless set in the configuration"},
+		}},
+		{Name: "Service account key", Variables: []credentialVariable{
+			{"GOOGLE_CREDENTIALS", "Contents of the service account key JSON file"},
+			{"GOOGLE_APPLICATION_CREDENTIALS", "Path to the key file, as an alternative to GOOGLE_CREDENTIALS"},
+			{"GOOGLE_PROJECT", "Project to deploy to, unless set in the configuration"},
+		}},
+		{Name: "Service account impersonation", Command: "gcloud auth application-default login", Variables: []credentialVariable{
+			{"GOOGLE_IMPERSONATE_SERVICE_ACCOUNT", "Email of the service account to impersonate"},
+			{"GOOGLE_PROJECT", "Project to deploy to, unless set in the configuration"},
+		}},
+	},
+}
+
+// generateCredentials writes CREDENTIALS.md, listing the ways to authenticate the provider and the environment
+// variables each needs. A provider without built-in guidance is described by its configured auth_variables alone, and
+// one without either gets no guide.
+func generateCredentials(path string, data map[string]interface{}, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	provider, _ := data["Provider"].(*models.Provider)
+	if provider == nil {
+		return nil, fmt.Errorf("no provider to describe credentials for")
+	}
+	methods, ok := providerCredentials[provider.Name]
+	if !ok && len(provider.AuthVariables) == 0 {
+		log.Printf("warning: no credentials guidance for provider %s and no auth_variables configured; skipping %s", provider.Name, credentialsFile)
+		return nil, nil
+	}
+
+	credentialsData := copyData(data)
+	credentialsData["CredentialMethods"] = methods
+
+	files := []templateFile{
+		{Template: filepath.Join(templatesDir, "generic", "CREDENTIALS.md.tmpl"), Dest: filepath.Join(path, credentialsFile)},
+	}
+	return renderFiles(files, credentialsData, opts)
+}
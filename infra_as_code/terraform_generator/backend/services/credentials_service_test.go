@@ -0,0 +1,73 @@
+// backend/services/credentials_service_test.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateCredentials(t *testing.T) {
+	chdirBackendRoot(t)
+
+	tests := []struct {
+		name     string
+		provider *models.Provider
+		want     []string // Lines CREDENTIALS.md must contain; nil when no guide is written
+		unwanted []string
+	}{
+		{
+			name:     "built-in guidance",
+			provider: &models.Provider{Name: "azurerm", AuthVariables: map[string]string{"client_secret": "ARM_CLIENT_SECRET"}},
+			want:     []string{"## Azure CLI", "Run `az login`, then set:", "## Configured for this project", "| `client_secret` | `ARM_CLIENT_SECRET` |"},
+		},
+		{
+			name:     "auth_variables only",
+			provider: &models.Provider{Name: "kubernetes", AuthVariables: map[string]string{"token": "KUBE_TOKEN"}},
+			want:     []string{"no built-in guidance for the `kubernetes` provider", "## Configured for this project", "| `token` | `KUBE_TOKEN` |"},
+			unwanted: []string{"one of the following methods"},
+		},
+		{
+			name:     "nothing to describe",
+			provider: &models.Provider{Name: "kubernetes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			data := map[string]interface{}{"OrganisationName": "acme", "ProductName": "web", "Provider": tt.provider}
+			results, err := generateCredentials(dir, data, utils.GenerateOptions{Overwrite: true})
+			if err != nil {
+				t.Fatalf("generateCredentials() error: %v", err)
+			}
+			if tt.want == nil {
+				if len(results) != 0 {
+					t.Fatalf("generateCredentials() = %+v, want no files", results)
+				}
+				if _, err := os.Stat(filepath.Join(dir, credentialsFile)); !os.IsNotExist(err) {
+					t.Fatalf("%s was written: %v", credentialsFile, err)
+				}
+				return
+			}
+
+			content, err := os.ReadFile(filepath.Join(dir, credentialsFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(string(content), line) {
+					t.Errorf("%s has no %q:\n%s", credentialsFile, line, content)
+				}
+			}
+			for _, line := range tt.unwanted {
+				if strings.Contains(string(content), line) {
+					t.Errorf("%s has %q:\n%s", credentialsFile, line, content)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// backend/services/generation_log.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GeneratorVersion identifies the generator build in the generation log, the .generator-version files and the
+// GeneratorVersion template data. Set it at build time with -ldflags "-X backend/services.GeneratorVersion=<version>",
+// e.g. the git SHA.
+var GeneratorVersion = "dev"
+
+// generatorVersionFile records the generator build in each generated product or customer directory.
+const generatorVersionFile = ".generator-version"
+
+// generationLogFile is the history file appended to in each organisation's output directory.
+const generationLogFile = "GENERATED.log"
+
+// writeGeneratorVersion writes the .generator-version file to path.
+func writeGeneratorVersion(path string, opts utils.GenerateOptions) (models.FileResult, error) {
+	dest := filepath.Join(path, generatorVersionFile)
+	result, err := utils.WriteFileWithResult(dest, []byte(GeneratorVersion+"\n"), opts)
+	if err != nil {
+		return result, fmt.Errorf("error generating %s: %w", dest, err)
+	}
+	return result, nil
+}
+
+// appendGenerationLog records a generation run in the organisation's GENERATED.log.
+func appendGenerationLog(basePath string, req *models.GenerateRequest, at time.Time) error {
+	requester := req.RequestedBy
+	if requester == "" {
+		requester = "unknown"
+	}
+	customers := "-"
+	if len(req.Customers) > 0 {
+		customers = strings.Join(req.Customers, ",")
+	}
+
+	entry := fmt.Sprintf("%s generator=%s requested_by=%s organisation=%s product=%s provider=%s customers=%s\n",
+		at.UTC().Format(time.RFC3339), logValue(GeneratorVersion), logValue(requester), logValue(req.OrganisationName),
+		logValue(req.ProductName), logValue(req.Provider), logValue(customers))
+
+	file, err := os.OpenFile(filepath.Join(basePath, generationLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
+
+// logValue quotes a value for a GENERATED.log entry unless it is a plain word, so values from the request cannot
+// break the line or forge further fields and entries.
+func logValue(value string) string {
+	if value == "" || strings.ContainsFunc(value, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '=' || r == '\\' || r >= 0x7f
+	}) {
+		return strconv.Quote(value)
+	}
+	return value
+}
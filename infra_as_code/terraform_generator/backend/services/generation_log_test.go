@@ -0,0 +1,41 @@
+// backend/services/generation_log_test.go
+
+package services
+
+import (
+	"backend/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendGenerationLog(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Customers: []string{"c1", "c2"}, RequestedBy: "alice"}
+	if err := appendGenerationLog(dir, req, at); err != nil {
+		t.Fatal(err)
+	}
+	// A requester carrying a newline must not add an entry of its own
+	req = &models.GenerateRequest{OrganisationName: "acme", ProductName: "web app", Provider: "azure",
+		RequestedBy: "bob\n2026-01-01T00:00:00Z generator=dev requested_by=mallory"}
+	if err := appendGenerationLog(dir, req, at); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, generationLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2026-01-02T03:04:05Z generator=dev requested_by=alice organisation=acme product=web provider=azure customers=c1,c2\n" +
+		`2026-01-02T03:04:05Z generator=dev requested_by="bob\n2026-01-01T00:00:00Z generator=dev requested_by=mallory" organisation=acme product="web app" provider=azure customers=-` + "\n"
+	if got := string(content); got != want {
+		t.Errorf("GENERATED.log = %q, want %q", got, want)
+	}
+	if lines := strings.Count(string(content), "\n"); lines != 2 {
+		t.Errorf("GENERATED.log has %d lines, want 2", lines)
+	}
+}
@@ -0,0 +1,32 @@
+// backend/services/graph_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+)
+
+// ModuleGraph loads the configuration and returns the dependency graph of the module calls req would generate,
+// including the modules pulled in through depends_on. Nothing is written.
+func ModuleGraph(req *models.GenerateRequest) (models.ModuleGraph, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return models.ModuleGraph{}, fmt.Errorf("error loading configuration: %w", err)
+	}
+	if err := utils.ValidateConfig(config); err != nil {
+		return models.ModuleGraph{}, err
+	}
+	// Input values kept in files may reference other modules too
+	if err := utils.ResolveFileReferences(config, filepath.Dir(configPath)); err != nil {
+		return models.ModuleGraph{}, err
+	}
+
+	modules, err := utils.ResolveModuleDependencies(req.Modules, config.Modules)
+	if err != nil {
+		return models.ModuleGraph{}, fmt.Errorf("%w: error resolving module dependencies: %w", ErrInvalidRequest, err)
+	}
+	return utils.BuildModuleGraph(modules), nil
+}
@@ -0,0 +1,53 @@
+// backend/services/import_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ImportVariables reads the variable blocks of an existing configuration into a configuration variables section.
+// path is either a single .tf file or a directory whose .tf files are read in name order.
+func ImportVariables(path string) (map[string]models.Variable, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.tf")); err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no .tf files found in %s", path)
+		}
+	}
+
+	variables := make(map[string]models.Variable)
+	declared := make(map[string]string)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", file, err)
+		}
+		parsed, err := utils.ParseVariables(src, file)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", file, err)
+		}
+		for name, variable := range parsed {
+			if previous, exists := declared[name]; exists {
+				return nil, fmt.Errorf("variable %q is declared in both %s and %s", name, previous, file)
+			}
+			declared[name] = file
+			variables[name] = variable
+		}
+	}
+	return variables, nil
+}
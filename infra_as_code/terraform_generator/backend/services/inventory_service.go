@@ -0,0 +1,36 @@
+// backend/services/inventory_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"path/filepath"
+)
+
+// inventoryFile summarises the latest multi-customer run in the organisation directory.
+const inventoryFile = "inventory.json"
+
+// inventoryCustomer records where a customer was generated, for what and which files it received.
+func inventoryCustomer(req *models.GenerateRequest, config *models.Config, provider *models.Provider, customer, customerPath string, results []models.FileResult) models.InventoryCustomer {
+	files := make([]string, 0, len(results))
+	for _, result := range results {
+		if !result.Directory && result.Action != models.FileRemoved {
+			files = append(files, result.Path)
+		}
+	}
+	return models.InventoryCustomer{
+		Name:         customer,
+		Path:         customerPath,
+		Provider:     provider.Name,
+		Region:       resourceRegion(req, config),
+		Environments: environmentsFor(req),
+		Files:        files,
+	}
+}
+
+// writeInventory stores the inventory of a multi-customer run next to the organisation's output.
+func writeInventory(basePath string, inventory models.Inventory) error {
+	_, err := utils.WriteJSONFile(filepath.Join(basePath, inventoryFile), inventory, utils.GenerateOptions{Overwrite: true})
+	return err
+}
@@ -0,0 +1,109 @@
+// backend/services/inventory_service_test.go
+
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInventoryCustomer(t *testing.T) {
+	config := &models.Config{Region: "eastus"}
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Environments: []string{"dev", "prod"}}
+	results := []models.FileResult{
+		{Path: "out/c1", Directory: true},
+		{Path: "out/c1/main.tf", Action: models.FileCreated},
+		{Path: "out/c1/providers.tf", Action: models.FileSkipped},
+		{Path: "out/c1/old.tf", Action: models.FileRemoved},
+	}
+
+	got := inventoryCustomer(req, config, &models.Provider{Name: "azurerm"}, "c1", "out/c1", results)
+	want := models.InventoryCustomer{
+		Name:         "c1",
+		Path:         "out/c1",
+		Provider:     "azurerm",
+		Region:       "eastus",
+		Environments: []string{"dev", "prod"},
+		Files:        []string{"out/c1/main.tf", "out/c1/providers.tf"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inventoryCustomer() = %+v, want %+v", got, want)
+	}
+
+	req.Region = "westus"
+	if got := inventoryCustomer(req, config, &models.Provider{Name: "azurerm"}, "c1", "out/c1", nil); got.Region != "westus" || len(got.Files) != 0 {
+		t.Errorf("inventoryCustomer() with a region override = %+v, want westus and no files", got)
+	}
+}
+
+func TestGenerateTerraformWritesInventory(t *testing.T) {
+	chdirGeneratorRoot(t, strings.Replace(replayTestConfig, `"modules": []`,
+		`"modules": [], "customer_patches": {"c2": [{"op": "replace", "path": "/region", "value": "westeurope"}]}`, 1))
+
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Modules: []string{}, Customers: []string{"c1", " c2 "}, DryRun: true}
+	if _, err := GenerateTerraform(req); err != nil {
+		t.Fatalf("GenerateTerraform() dry run error: %v", err)
+	}
+	if paths := inventoryPaths(t); len(paths) != 0 {
+		t.Fatalf("dry run wrote %v", paths)
+	}
+
+	req.DryRun = false
+	if _, err := GenerateTerraform(req); err != nil {
+		t.Fatalf("GenerateTerraform() error: %v", err)
+	}
+	paths := inventoryPaths(t)
+	if len(paths) != 1 {
+		t.Fatalf("found inventories %v, want one", paths)
+	}
+	content, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inventory models.Inventory
+	if err := json.Unmarshal(content, &inventory); err != nil {
+		t.Fatal(err)
+	}
+
+	if inventory.Organisation != "acme" || inventory.Product != "web" || len(inventory.Customers) != 2 {
+		t.Fatalf("inventory = %+v, want acme/web with two customers", inventory)
+	}
+	for i, want := range []struct{ name, region string }{{"c1", "eastus"}, {"c2", "westeurope"}} {
+		customer := inventory.Customers[i]
+		if customer.Name != want.name || customer.Region != want.region || customer.Provider != "azurerm" {
+			t.Errorf("customer %d = %s in %s with %s, want %s in %s with azurerm", i, customer.Name, customer.Region, customer.Provider, want.name, want.region)
+		}
+		if len(customer.Files) == 0 {
+			t.Errorf("customer %s lists no files", customer.Name)
+		}
+		for _, file := range customer.Files {
+			if !strings.HasPrefix(file, customer.Path+string(filepath.Separator)) {
+				t.Errorf("customer %s lists %s outside %s", customer.Name, file, customer.Path)
+			}
+		}
+	}
+}
+
+// inventoryPaths returns the inventory files under the output directory.
+func inventoryPaths(t *testing.T) []string {
+	t.Helper()
+	var paths []string
+	err := filepath.WalkDir("output", func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Name() == inventoryFile {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return paths
+}
@@ -0,0 +1,79 @@
+// backend/services/manifest_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// manifestPath returns where the manifest for a product's latest generation run is stored in the organisation directory.
+func manifestPath(basePath, product string) (string, error) {
+	if !utils.IsSafePathSegment(product) {
+		return "", fmt.Errorf("%w: invalid product name %q", ErrInvalidRequest, product)
+	}
+	return filepath.Join(basePath, product+".manifest.json"), nil
+}
+
+// NewManifest records the request and the hash of every file in results; directories and removed files are left out.
+func NewManifest(req *models.GenerateRequest, results []models.FileResult) models.Manifest {
+	manifest := models.Manifest{Request: *req, Files: make(map[string]string, len(results))}
+	for _, result := range results {
+		if result.Directory || result.Action == models.FileRemoved {
+			continue
+		}
+		manifest.Files[result.Path] = result.SHA256
+	}
+	return manifest
+}
+
+// writeManifest stores the manifest for a generation run next to the organisation's output.
+func writeManifest(basePath string, req *models.GenerateRequest, results []models.FileResult) error {
+	path, err := manifestPath(basePath, req.ProductName)
+	if err != nil {
+		return err
+	}
+	_, err = utils.WriteJSONFile(path, NewManifest(req, results), utils.GenerateOptions{Overwrite: true})
+	return err
+}
+
+// Replay renders the files for a stored manifest and reports any file whose content no longer matches. Nothing is
+// written: the output, the manifest and the generation log stay as the last real run left them.
+func Replay(manifest models.Manifest) (models.ReplayResult, error) {
+	req := manifest.Request
+	// Every file must be rendered for its hash to reflect the current generator, and only hashed
+	req.NoOverwrite = false
+	req.DryRun = true
+
+	results, err := GenerateTerraform(&req)
+	if err != nil {
+		return models.ReplayResult{Files: results}, fmt.Errorf("error regenerating from manifest: %w", err)
+	}
+
+	drift := CompareManifest(manifest, NewManifest(&req, results))
+	return models.ReplayResult{Match: len(drift) == 0, Drift: drift, Files: results}, nil
+}
+
+// CompareManifest lists the differences between an expected and an actual manifest, ordered by path.
+func CompareManifest(expected, actual models.Manifest) []models.FileDrift {
+	drift := []models.FileDrift{}
+	for path, want := range expected.Files {
+		got, ok := actual.Files[path]
+		switch {
+		case !ok:
+			drift = append(drift, models.FileDrift{Path: path, Status: models.DriftMissing, Expected: want})
+		case got != want:
+			drift = append(drift, models.FileDrift{Path: path, Status: models.DriftChanged, Expected: want, Actual: got})
+		}
+	}
+	for path, got := range actual.Files {
+		if _, ok := expected.Files[path]; !ok {
+			drift = append(drift, models.FileDrift{Path: path, Status: models.DriftAdded, Actual: got})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Path < drift[j].Path })
+	return drift
+}
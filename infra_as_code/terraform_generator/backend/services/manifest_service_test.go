@@ -0,0 +1,198 @@
+// backend/services/manifest_service_test.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareManifest(t *testing.T) {
+	expected := models.Manifest{Files: map[string]string{"main.tf": "a", "providers.tf": "b", "vars.tfvars": "c"}}
+
+	tests := []struct {
+		name   string
+		actual map[string]string
+		want   []models.FileDrift
+	}{
+		{
+			name:   "identical",
+			actual: map[string]string{"main.tf": "a", "providers.tf": "b", "vars.tfvars": "c"},
+			want:   []models.FileDrift{},
+		},
+		{
+			name:   "changed",
+			actual: map[string]string{"main.tf": "a", "providers.tf": "B", "vars.tfvars": "c"},
+			want:   []models.FileDrift{{Path: "providers.tf", Status: models.DriftChanged, Expected: "b", Actual: "B"}},
+		},
+		{
+			name:   "missing and added, ordered by path",
+			actual: map[string]string{"main.tf": "a", "providers.tf": "b", "backend.tfvars": "d"},
+			want: []models.FileDrift{
+				{Path: "backend.tfvars", Status: models.DriftAdded, Actual: "d"},
+				{Path: "vars.tfvars", Status: models.DriftMissing, Expected: "c"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareManifest(expected, models.Manifest{Files: tt.actual})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareManifest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestPathRejectsUnsafeProducts(t *testing.T) {
+	for _, product := range []string{"", "..", "web/../../etc", `web\app`} {
+		if _, err := manifestPath("out", product); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("manifestPath(%q) error = %v, want %v", product, err, ErrInvalidRequest)
+		}
+	}
+	if got, err := manifestPath("out", "web"); err != nil || got != filepath.Join("out", "web.manifest.json") {
+		t.Errorf("manifestPath(web) = %s, %v", got, err)
+	}
+}
+
+// chdirGeneratorRoot runs the test from a scratch directory holding config as the configuration file and the
+// repository's templates, so generation writes nothing into the repository.
+func chdirGeneratorRoot(t *testing.T, config string) {
+	t.Helper()
+	templates, err := filepath.Abs(filepath.Join("..", templatesDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(templates, filepath.Join(dir, templatesDir)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(configPath)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, configPath), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	previous := configCache
+	configCache = utils.NewConfigCache(configPath)
+	t.Cleanup(func() {
+		configCache = previous
+		os.Chdir(wd)
+	})
+}
+
+// replayTestConfig is the smallest configuration an azure product generates from.
+const replayTestConfig = `{
+	"terraform_version": ">= 1.5.0",
+	"region": "eastus",
+	"environment": "nonprod",
+	"providers": [{"name": "azurerm", "source": "hashicorp/azurerm", "version": "~> 3.0"}],
+	"backend": {"type": "local"},
+	"modules": [],
+	"variables": {"location": {"type": "string", "default": "eastus"}}
+}`
+
+func TestReplayDoesNotWrite(t *testing.T) {
+	chdirGeneratorRoot(t, replayTestConfig)
+
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Modules: []string{}}
+	results, err := GenerateTerraform(req)
+	if err != nil {
+		t.Fatalf("GenerateTerraform() error: %v", err)
+	}
+	manifest := NewManifest(req, results)
+
+	result, err := Replay(manifest)
+	if err != nil {
+		t.Fatalf("Replay() error: %v", err)
+	}
+	if !result.Match || len(result.Drift) != 0 {
+		t.Fatalf("Replay() of unchanged output = %+v, want a match", result.Drift)
+	}
+
+	// A file edited by hand must not be restored by the replay, nor the manifest and log updated
+	var edited string
+	for path := range manifest.Files {
+		if filepath.Base(path) == "main.tf" {
+			edited = path
+		}
+	}
+	if edited == "" {
+		t.Fatalf("no main.tf among %v", manifest.Files)
+	}
+	if err := os.WriteFile(edited, []byte("# edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshotFiles(t, "output")
+
+	// The stored hash no longer matches what the generator renders
+	manifest.Files[edited] = "0000"
+	result, err = Replay(manifest)
+	if err != nil {
+		t.Fatalf("Replay() error: %v", err)
+	}
+	if result.Match || len(result.Drift) != 1 || result.Drift[0].Path != edited || result.Drift[0].Status != models.DriftChanged {
+		t.Errorf("Replay() drift = %+v, want %s changed", result.Drift, edited)
+	}
+	if after := snapshotFiles(t, "output"); !reflect.DeepEqual(before, after) {
+		t.Error("Replay() changed the generated output")
+	}
+}
+
+// snapshotFiles reads every file under root by path
+func snapshotFiles(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		files[path] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestGeneratedFilePath(t *testing.T) {
+	chdirGeneratorRoot(t, replayTestConfig)
+
+	for _, tt := range []struct{ provider, organisation, product, customer, file string }{
+		{organisation: "..", product: "web", file: "main.tf"},
+		{organisation: "acme", product: "web/../..", file: "main.tf"},
+		{organisation: "acme", product: "web", customer: `c1\..`, file: "main.tf"},
+		{provider: "../azure", organisation: "acme", product: "web", file: "main.tf"},
+		{organisation: "acme", product: "web", file: "../../configs/terraform-generator.json"},
+		{organisation: "acme", product: "web", file: "/etc/passwd"},
+	} {
+		if _, err := GeneratedFilePath(tt.provider, tt.organisation, tt.product, tt.customer, tt.file); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("GeneratedFilePath(%+v) error = %v, want %v", tt, err, ErrInvalidRequest)
+		}
+	}
+
+	// The default output path keeps a customer's files beside the product's, without the provider
+	for customer, want := range map[string]string{"": "acme/web", "c1": "acme/c1"} {
+		got, err := GeneratedFilePath("azure", "acme", "web", customer, "envs/prod/vars.tfvars")
+		if err != nil {
+			t.Fatalf("GeneratedFilePath(%q) error: %v", customer, err)
+		}
+		if want = filepath.Join(outputRoot, want, "envs", "prod", "vars.tfvars"); got != want {
+			t.Errorf("GeneratedFilePath(%q) = %s, want %s", customer, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// backend/services/matrix_service.go
+
+package services
+
+import (
+	"backend/models"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// matrixColumns are the columns expected in a provider matrix CSV, in order.
+var matrixColumns = []string{"customer", "provider", "region", "environments"}
+
+// GenerateFromMatrix reads a CSV of customer, provider, region and environments and
+// runs GenerateTerraform once per row, using base for the organisation, product and modules.
+// Environments within a row are separated by semicolons or spaces. A failing row is
+// reported in its result and does not stop the remaining rows.
+func GenerateFromMatrix(r io.Reader, base models.GenerateRequest) ([]models.MatrixResult, error) {
+	rows, err := readMatrix(r)
+	if err != nil {
+		return nil, err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	var results []models.MatrixResult
+	var providers []string
+	for i, row := range rows {
+		if len(row) < 2 || len(row) > len(matrixColumns) {
+			return results, fmt.Errorf("provider matrix row %d: expected columns %s", i+1, strings.Join(matrixColumns, ", "))
+		}
+
+		req := base
+		req.Customers = []string{strings.TrimSpace(row[0])}
+		req.Provider = strings.TrimSpace(row[1])
+		providers = append(providers, req.Provider)
+		req.Region = ""
+		req.Environments = nil
+		if len(row) > 2 {
+			req.Region = strings.TrimSpace(row[2])
+		}
+		if len(row) > 3 {
+			req.Environments = strings.FieldsFunc(row[3], func(r rune) bool {
+				return r == ';' || r == ' '
+			})
+		}
+
+		result := models.MatrixResult{
+			Customer:     req.Customers[0],
+			Provider:     req.Provider,
+			Region:       req.Region,
+			Environments: req.Environments,
+		}
+		if req.Customers[0] == "" || req.Provider == "" {
+			result.Error = "customer and provider are required"
+			results = append(results, result)
+			continue
+		}
+
+		files, err := GenerateTerraform(&req)
+		for _, file := range files {
+			if !file.Directory {
+				result.Files++
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	// Across a whole matrix, a configured provider no row requests is likely a stale catalog entry
+	warnUnusedProviders(config, providers)
+	return results, nil
+}
+
+// readMatrix reads the rows of a provider matrix CSV, without the header row if present.
+func readMatrix(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading provider matrix: %w", err)
+	}
+
+	// Skip the header row if present
+	if len(rows) > 0 && strings.EqualFold(strings.TrimSpace(rows[0][0]), matrixColumns[0]) {
+		rows = rows[1:]
+	}
+	return rows, nil
+}
+
+// MatrixProviders returns the provider input of every row of a provider matrix CSV, in row order.
+func MatrixProviders(r io.Reader) ([]string, error) {
+	rows, err := readMatrix(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []string
+	for i, row := range rows {
+		if len(row) < 2 || len(row) > len(matrixColumns) {
+			return nil, fmt.Errorf("provider matrix row %d: expected columns %s", i+1, strings.Join(matrixColumns, ", "))
+		}
+		providers = append(providers, strings.TrimSpace(row[1]))
+	}
+	return providers, nil
+}
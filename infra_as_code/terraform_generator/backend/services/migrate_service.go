@@ -0,0 +1,60 @@
+// backend/services/migrate_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// migrateFile is the backend migration script scaffolded into each product or customer directory.
+const migrateFile = "migrate-backend.sh"
+
+// migrationSources lists the backend types state can be migrated from.
+var migrationSources = []string{models.BackendLocal, "s3", "azurerm", "gcs"}
+
+// checkMigration fails a request whose previous backend type is unknown or whose output cannot be migrated.
+func checkMigration(req *models.GenerateRequest, config *models.Config) error {
+	if req.MigrateBackendFrom == "" {
+		return nil
+	}
+	if !slices.Contains(migrationSources, req.MigrateBackendFrom) {
+		return fmt.Errorf("%w: unsupported migrate_backend_from '%s': expected local, s3, azurerm or gcs", ErrInvalidRequest, req.MigrateBackendFrom)
+	}
+	if req.OutputFormat == models.OutputFormatCDKTF {
+		return fmt.Errorf("%w: a backend migration script is not supported for the %s output format", ErrInvalidRequest, models.OutputFormatCDKTF)
+	}
+	if config.Cloud != nil {
+		return fmt.Errorf("%w: a backend migration script is not supported with a cloud block; use terraform init to migrate to HCP Terraform", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// generateMigration writes an executable script that moves one environment's state from the request's previous
+// backend to the generated backend configuration with terraform init -migrate-state.
+func generateMigration(req *models.GenerateRequest, config *models.Config, path string, data map[string]interface{}, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	if err := checkMigration(req, config); err != nil {
+		return nil, err
+	}
+
+	inputs, err := entityInputs(req, config, path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	migrateData := copyData(data)
+	migrateData["MigrateInputs"] = inputs
+	migrateData["MigrateFrom"] = req.MigrateBackendFrom
+
+	files := []templateFile{
+		{Template: filepath.Join(templatesDir, "generic", "migrate-backend.sh.tmpl"), Dest: filepath.Join(path, migrateFile)},
+	}
+	results, err := renderFiles(files, migrateData, opts)
+	if err != nil {
+		return results, err
+	}
+	return results, makeExecutable(results, opts)
+}
@@ -0,0 +1,92 @@
+// backend/services/migrate_service_test.go
+
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckMigration(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		format  string
+		cloud   bool
+		wantErr bool
+	}{
+		{name: "no migration", from: ""},
+		{name: "no migration with a cloud block", cloud: true},
+		{name: "from local", from: "local"},
+		{name: "from s3 to json output", from: "s3", format: models.OutputFormatJSON},
+		{name: "unknown backend", from: "consul", wantErr: true},
+		{name: "cdktf output", from: "local", format: models.OutputFormatCDKTF, wantErr: true},
+		{name: "cloud block", from: "gcs", cloud: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			if tt.cloud {
+				config.Cloud = &models.Cloud{Organization: "acme", Workspaces: models.CloudWorkspaces{Name: "web"}}
+			}
+			err := checkMigration(&models.GenerateRequest{MigrateBackendFrom: tt.from, OutputFormat: tt.format}, config)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidRequest)) {
+				t.Errorf("checkMigration() error = %v, want %v %t", err, ErrInvalidRequest, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateMigration(t *testing.T) {
+	chdirBackendRoot(t)
+
+	config := testConfig()
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Customers: []string{"c1"}, Environments: []string{"dev", "prod"}, MigrateBackendFrom: "local"}
+	data := prepareTemplateData(req, config, &models.Provider{Name: "azurerm"}, "c1", nil)
+	out := t.TempDir()
+
+	// A dry run reports the script without writing it
+	opts := fileOptions(req, config)
+	opts.DryRun = true
+	if _, err := generateMigration(req, config, out, data, opts); err != nil {
+		t.Fatalf("generateMigration() dry run error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, migrateFile)); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote %s", migrateFile)
+	}
+
+	results, err := generateMigration(req, config, out, data, fileOptions(req, config))
+	if err != nil {
+		t.Fatalf("generateMigration() error: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != filepath.Join(out, migrateFile) {
+		t.Fatalf("generateMigration() = %+v, want %s", results, migrateFile)
+	}
+	info, err := os.Stat(results[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("%s mode = %v, want executable", migrateFile, info.Mode().Perm())
+	}
+
+	script, err := os.ReadFile(results[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Generated for acme/c1",
+		"from the previous local backend",
+		"if [ ! -f terraform.tfstate ]; then",
+		"  'dev')\n    terraform init -migrate-state -backend-config='backend/c1_dev.tfvars' \"$@\"",
+		"  'prod')\n    terraform init -migrate-state -backend-config='backend/c1_prod.tfvars' \"$@\"",
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("%s does not contain %q:\n%s", migrateFile, want, script)
+		}
+	}
+}
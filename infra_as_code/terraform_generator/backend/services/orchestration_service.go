@@ -0,0 +1,114 @@
+// backend/services/orchestration_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// rootMainFile is the orchestration configuration written to the organisation directory.
+const rootMainFile = "main.tf"
+
+// customerModule is a module block in the organisation-level main.tf calling one customer directory.
+type customerModule struct {
+	Label     string            // Block label; the customer name
+	Source    string            // Relative to the organisation directory, e.g. "./acme-retail"; set once the output path is known
+	Arguments map[string]string // Rendered value of each customer variable passed to the module
+}
+
+// customerModules lists a module per processed customer, in request order.
+func customerModules(req *models.GenerateRequest) ([]customerModule, error) {
+	if len(req.Customers) == 0 {
+		return nil, fmt.Errorf("an organisation-level %s needs customers", rootMainFile)
+	}
+	if req.OutputFormat == models.OutputFormatCDKTF {
+		return nil, fmt.Errorf("an organisation-level %s is not supported for the %s output format", rootMainFile, models.OutputFormatCDKTF)
+	}
+
+	var modules []customerModule
+	seen := make(map[string]bool)
+	for _, customer := range req.Customers {
+		customer = strings.TrimSpace(customer)
+		if seen[customer] {
+			continue
+		}
+		seen[customer] = true
+		if !utils.IsIdentifier(customer) {
+			return nil, fmt.Errorf("customer %q cannot be used as a module label", customer)
+		}
+		modules = append(modules, customerModule{Label: customer})
+	}
+	return modules, nil
+}
+
+// generateRootMain writes an organisation-level main.tf with a module block per customer, giving a single entrypoint
+// that plans and applies every customer together.
+func generateRootMain(req *models.GenerateRequest, config *models.Config, provider, basePath string, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	modules, err := customerModules(req)
+	if err != nil {
+		return nil, err
+	}
+	// Sources follow the configured output path, which may nest customers below the organisation directory
+	forwarded := make(map[string]models.Variable)
+	for i, module := range modules {
+		customerPath, err := OutputDir(config, provider, req.OrganisationName, req.ProductName, module.Label)
+		if err != nil {
+			return nil, err
+		}
+		relative, err := filepath.Rel(basePath, customerPath)
+		if err != nil {
+			return nil, err
+		}
+		// Local module sources must start with ./ or ../
+		modules[i].Source = filepath.ToSlash(relative)
+		if !strings.HasPrefix(modules[i].Source, "../") {
+			modules[i].Source = "./" + modules[i].Source
+		}
+		if modules[i].Arguments, err = customerArguments(req, config, module.Label, forwarded); err != nil {
+			return nil, err
+		}
+	}
+
+	data := map[string]interface{}{
+		"OrganisationName": req.OrganisationName,
+		"ProductName":      req.ProductName,
+		"Customers":        modules,
+		"Variables":        forwarded,
+	}
+	files := []templateFile{
+		{Template: filepath.Join(templatesDir, "generic", "root_main.tf.tmpl"), Dest: filepath.Join(basePath, rootMainFile)},
+	}
+	return renderFiles(files, data, opts)
+}
+
+// customerArguments renders the module arguments for a customer's variables: the values its tfvars would hold, and
+// for a variable without a value or default, a reference to an organisation-level variable named
+// <customer>_<variable>, added to forwarded. Values are left to the forwarded variables when the request or
+// configuration skips vars files; variables with a default are left to it.
+func customerArguments(req *models.GenerateRequest, config *models.Config, customer string, forwarded map[string]models.Variable) (map[string]string, error) {
+	patched, _, _, err := customerConfig(req, config, customer, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	withValues := !varsDisabled(req, patched)
+
+	arguments := make(map[string]string)
+	for name, variable := range templateVariables(req, patched, patched.Environment) {
+		switch {
+		case withValues && variable.Value != nil:
+			arguments[name] = utils.FormatConstantValue(variable)
+		case variable.Default == nil:
+			rootName := customer + "_" + name
+			if _, ok := forwarded[rootName]; ok {
+				return nil, fmt.Errorf("customer %s: variable %s would be forwarded as %s, which another customer's variable already uses", customer, name, rootName)
+			}
+			forwarded[rootName] = models.Variable{Type: variable.Type, Attributes: variable.Attributes, Description: variable.Description, Sensitive: variable.Sensitive}
+			arguments[name] = "var." + rootName
+		}
+	}
+	return arguments, nil
+}
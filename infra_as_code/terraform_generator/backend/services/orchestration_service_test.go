@@ -0,0 +1,90 @@
+// backend/services/orchestration_service_test.go
+
+package services
+
+import (
+	"backend/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+func TestGenerateRootMainPassesCustomerVariables(t *testing.T) {
+	chdirGeneratorRoot(t, strings.Replace(replayTestConfig,
+		`"variables": {"location": {"type": "string", "default": "eastus"}}`,
+		`"variables": {
+			"location": {"type": "string", "default": "eastus"},
+			"sku": {"type": "string", "value": "Standard"},
+			"admin_password": {"type": "string", "sensitive": true}
+		},
+		"customer_patches": {"c2": [{"op": "replace", "path": "/variables/sku/value", "value": "Premium"}]}`, 1))
+
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Modules: []string{}, Customers: []string{"c1", "c2"}, GenerateRootMain: true}
+	if _, err := GenerateTerraform(req); err != nil {
+		t.Fatalf("GenerateTerraform() error: %v", err)
+	}
+
+	root := parseHCLBody(t, filepath.Join("output", "terraform", "acme", rootMainFile))
+	declared := make(map[string]bool)
+	for _, block := range root.Blocks {
+		if block.Type == "variable" {
+			declared[block.Labels[0]] = true
+		}
+	}
+
+	modules := 0
+	for _, block := range root.Blocks {
+		if block.Type != "module" {
+			continue
+		}
+		modules++
+		customer := block.Labels[0]
+
+		// Every argument is declared by the customer, and every variable without a default is set
+		variables := parseHCLBody(t, filepath.Join("output", "terraform", "acme", customer, "variables.tf"))
+		inputs := make(map[string]bool)
+		for _, variable := range variables.Blocks {
+			_, hasDefault := variable.Body.Attributes["default"]
+			inputs[variable.Labels[0]] = true
+			if _, ok := block.Body.Attributes[variable.Labels[0]]; !ok && !hasDefault {
+				t.Errorf("module %s does not set %s, which has no default", customer, variable.Labels[0])
+			}
+		}
+		for name := range block.Body.Attributes {
+			if name != "source" && !inputs[name] {
+				t.Errorf("module %s passes %s, which %s/variables.tf does not declare", customer, name, customer)
+			}
+		}
+
+		want := map[string]string{"c1": "Standard", "c2": "Premium"}[customer]
+		if sku, diags := block.Body.Attributes["sku"].Expr.Value(nil); diags.HasErrors() || sku.AsString() != want {
+			t.Errorf("module %s passes sku = %#v, want %q", customer, sku, want)
+		}
+		// A value the generator does not know is forwarded from an organisation-level variable
+		password := block.Body.Attributes["admin_password"]
+		if traversal, diags := hcl.AbsTraversalForExpr(password.Expr); diags.HasErrors() || len(traversal) != 2 || traversal.RootName() != "var" || !declared[customer+"_admin_password"] {
+			t.Errorf("module %s does not forward admin_password from a declared variable %s_admin_password", customer, customer)
+		}
+	}
+	if modules != 2 {
+		t.Errorf("root main.tf calls %d modules, want 2", modules)
+	}
+}
+
+// parseHCLBody parses an HCL file, failing the test when it is missing or invalid.
+func parseHCLBody(t *testing.T, path string) *hclsyntax.Body {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, diags := hclsyntax.ParseConfig(content, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("%s is not valid HCL: %s\n%s", path, diags.Error(), content)
+	}
+	return file.Body.(*hclsyntax.Body)
+}
@@ -0,0 +1,69 @@
+// backend/services/organisation_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// organisationsFor returns the organisation name followed by the listed organisations, without duplicates.
+func organisationsFor(req *models.GenerateRequest) ([]string, error) {
+	var organisations []string
+	seen := make(map[string]bool)
+	for _, organisation := range append([]string{req.OrganisationName}, req.Organisations...) {
+		organisation = strings.TrimSpace(organisation)
+		if organisation == "" || seen[organisation] {
+			continue
+		}
+		if !utils.IsSafePathSegment(organisation) {
+			return nil, fmt.Errorf("%w: invalid organisation name %q", ErrInvalidRequest, organisation)
+		}
+		seen[organisation] = true
+		organisations = append(organisations, organisation)
+	}
+	if len(organisations) == 0 {
+		return nil, fmt.Errorf("%w: organisation_name or organisations is required", ErrInvalidRequest)
+	}
+	return organisations, nil
+}
+
+// GenerateOrganisations runs GenerateTerraform once per organisation named by the request, each into its own
+// organisation directory. A failing organisation is reported in its result and does not stop the others; when every
+// organisation fails, their errors are also returned alongside the results.
+func GenerateOrganisations(req *models.GenerateRequest) ([]models.OrganisationResult, error) {
+	organisations, err := organisationsFor(req)
+	if err != nil {
+		return nil, err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+	if err := checkCustomerLimit(config, len(req.Customers)*len(organisations)); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.OrganisationResult, 0, len(organisations))
+	var failures []error
+	for _, organisation := range organisations {
+		orgReq := *req
+		orgReq.OrganisationName = organisation
+		orgReq.Organisations = nil
+
+		files, err := GenerateTerraform(&orgReq)
+		result := models.OrganisationResult{Organisation: organisation, Files: files}
+		if err != nil {
+			result.Error = err.Error()
+			failures = append(failures, fmt.Errorf("%s: %w", organisation, err))
+		}
+		results = append(results, result)
+	}
+	if len(failures) == len(organisations) {
+		return results, errors.Join(failures...)
+	}
+	return results, nil
+}
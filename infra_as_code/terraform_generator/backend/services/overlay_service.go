@@ -0,0 +1,30 @@
+// backend/services/overlay_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+)
+
+// customerConfig applies the customer's configured patch and re-derives the provider and modules from the patched
+// configuration. Customers without a patch share the organisation's configuration unchanged.
+func customerConfig(req *models.GenerateRequest, config *models.Config, customer string, provider *models.Provider, modules []models.Module) (*models.Config, *models.Provider, []models.Module, error) {
+	operations, ok := config.CustomerPatches[customer]
+	if !ok {
+		return config, provider, modules, nil
+	}
+
+	patched, err := utils.ApplyConfigPatch(config, operations)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("customer_patches.%s: %w", customer, err)
+	}
+	// A patch may add a backend, variable or module that still needs the checks and defaults the organisation's
+	// configuration was given
+	patchedProvider, patchedModules, err := prepareConfig(req, patched)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("customer_patches.%s: %w", customer, err)
+	}
+	return patched, patchedProvider, patchedModules, nil
+}
@@ -0,0 +1,75 @@
+// backend/services/provider_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ResolveProvider reports the Terraform provider name an input resolves to and whether the configuration defines it.
+func ResolveProvider(input string) (models.ProviderResolution, error) {
+	resolution := models.ProviderResolution{
+		Input:      input,
+		Normalized: utils.NormalizeProviderName(input),
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return resolution, fmt.Errorf("error loading configuration: %w", err)
+	}
+	resolution.Found = utils.FilterProviderData(config.Providers, input) != nil
+
+	return resolution, nil
+}
+
+// CheckProviderUsage loads the configuration and reports the configured providers none of inputs resolves to.
+func CheckProviderUsage(inputs []string) (models.ProviderUsage, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return models.ProviderUsage{}, fmt.Errorf("error loading configuration: %w", err)
+	}
+	return ProviderUsage(config, inputs), nil
+}
+
+// ProviderUsage reports which configured providers the provider inputs of a set of requests resolve to, in
+// configuration order. Each input is resolved like a request's provider, so azure matches azurerm.
+func ProviderUsage(config *models.Config, inputs []string) models.ProviderUsage {
+	usage := models.ProviderUsage{Requested: []string{}, Used: []string{}, Unused: []string{}, Unknown: []string{}}
+	used := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, input := range inputs {
+		input = strings.TrimSpace(input)
+		if input == "" || seen[input] {
+			continue
+		}
+		seen[input] = true
+		usage.Requested = append(usage.Requested, input)
+
+		provider := utils.FilterProviderData(config.Providers, input)
+		if provider == nil {
+			usage.Unknown = append(usage.Unknown, input)
+			continue
+		}
+		used[provider.Name] = true
+	}
+
+	for _, provider := range config.Providers {
+		if used[provider.Name] {
+			usage.Used = append(usage.Used, provider.Name)
+		} else {
+			usage.Unused = append(usage.Unused, provider.Name)
+		}
+	}
+	return usage
+}
+
+// warnUnusedProviders logs the providers configured in config that none of inputs resolves to.
+func warnUnusedProviders(config *models.Config, inputs []string) {
+	for _, name := range ProviderUsage(config, inputs).Unused {
+		log.Printf("warning: provider %s is configured but not requested", name)
+	}
+}
@@ -0,0 +1,46 @@
+// backend/services/provider_service_test.go
+
+package services
+
+import (
+	"backend/models"
+	"reflect"
+	"testing"
+)
+
+func TestProviderUsage(t *testing.T) {
+	config := &models.Config{Providers: []models.Provider{
+		{Name: "azurerm", Source: "hashicorp/azurerm"},
+		{Name: "aws", Source: "hashicorp/aws"},
+		{Name: "google", Source: "hashicorp/google"},
+	}}
+
+	tests := []struct {
+		name   string
+		inputs []string
+		want   models.ProviderUsage
+	}{
+		{
+			name:   "nothing requested",
+			inputs: nil,
+			want:   models.ProviderUsage{Requested: []string{}, Used: []string{}, Unused: []string{"azurerm", "aws", "google"}, Unknown: []string{}},
+		},
+		{
+			name:   "aliases resolve to the configured provider once",
+			inputs: []string{"azure", " azurerm ", "azure", ""},
+			want:   models.ProviderUsage{Requested: []string{"azure", "azurerm"}, Used: []string{"azurerm"}, Unused: []string{"aws", "google"}, Unknown: []string{}},
+		},
+		{
+			name:   "used in configuration order with unknown inputs",
+			inputs: []string{"gcp", "oracle", "aws"},
+			want:   models.ProviderUsage{Requested: []string{"gcp", "oracle", "aws"}, Used: []string{"aws", "google"}, Unused: []string{"azurerm"}, Unknown: []string{"oracle"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProviderUsage(config, tt.inputs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProviderUsage(%q) = %+v, want %+v", tt.inputs, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// backend/services/scaffold_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+)
+
+// generateScaffoldFiles writes the optional repository files requested alongside the Terraform files.
+func generateScaffoldFiles(req *models.GenerateRequest, config *models.Config, path string, data map[string]interface{}, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	results, err := generateStaticFiles(config, path, data, opts)
+	if err != nil {
+		return results, err
+	}
+
+	var files []templateFile
+
+	if req.GenerateCodeowners {
+		if config.Repository.Team == "" {
+			return nil, fmt.Errorf("repository.team is required to generate CODEOWNERS")
+		}
+		files = append(files,
+			templateFile{Template: filepath.Join(templatesDir, "generic", "CODEOWNERS.tmpl"), Dest: filepath.Join(path, ".github", "CODEOWNERS")},
+			templateFile{Template: filepath.Join(templatesDir, "generic", "pull_request_template.md.tmpl"), Dest: filepath.Join(path, ".github", "pull_request_template.md")},
+		)
+	}
+
+	if req.GenerateLinters {
+		files = append(files,
+			templateFile{Template: filepath.Join(templatesDir, "generic", "tflint.hcl.tmpl"), Dest: filepath.Join(path, ".tflint.hcl")},
+			templateFile{Template: filepath.Join(templatesDir, "generic", "checkov.yaml.tmpl"), Dest: filepath.Join(path, ".checkov.yaml")},
+		)
+	}
+
+	if req.GeneratePolicy {
+		files = append(files,
+			templateFile{Template: filepath.Join(templatesDir, "generic", "policy.rego.tmpl"), Dest: filepath.Join(path, "policy", "main.rego")},
+			templateFile{Template: filepath.Join(templatesDir, "generic", "conftest.toml.tmpl"), Dest: filepath.Join(path, "conftest.toml")},
+		)
+	}
+
+	if req.GeneratePreCommit {
+		files = append(files, templateFile{Template: filepath.Join(templatesDir, "generic", "pre-commit-config.yaml.tmpl"), Dest: filepath.Join(path, ".pre-commit-config.yaml")})
+	}
+
+	if req.GenerateEnvrc {
+		if config.Proxy.HTTPProxy == "" {
+			return nil, fmt.Errorf("proxy.http_proxy is required to generate .envrc")
+		}
+		files = append(files, templateFile{Template: filepath.Join(templatesDir, "generic", "envrc.tmpl"), Dest: filepath.Join(path, ".envrc")})
+	}
+
+	if req.GenerateReadme {
+		files = append(files, templateFile{Template: filepath.Join(templatesDir, "generic", "README.md.tmpl"), Dest: filepath.Join(path, "README.md")})
+	}
+
+	rendered, err := renderFiles(files, data, opts)
+	results = append(results, rendered...)
+	if err != nil {
+		return results, err
+	}
+
+	if req.GenerateWorkflow {
+		workflowResults, err := generateWorkflow(req, config, path, data, opts)
+		results = append(results, workflowResults...)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	if req.GenerateTeardown {
+		teardownResults, err := generateTeardown(req, config, path, data, opts)
+		results = append(results, teardownResults...)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	if req.MigrateBackendFrom != "" {
+		migrateResults, err := generateMigration(req, config, path, data, opts)
+		results = append(results, migrateResults...)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	if req.GenerateVarsSchema {
+		schemaResults, err := generateVariablesSchema(req, path, data, opts)
+		results = append(results, schemaResults...)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	if req.GenerateCredentials {
+		credentialsResults, err := generateCredentials(path, data, opts)
+		results = append(results, credentialsResults...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// generateStaticFiles writes the configured static files, rendering each from the templates directory unless it is
+// marked verbatim.
+func generateStaticFiles(config *models.Config, path string, data map[string]interface{}, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	var results []models.FileResult
+	for _, file := range config.StaticFiles {
+		source, dest := filepath.Join(templatesDir, file.Source), filepath.Join(path, file.Dest)
+		if !file.Verbatim {
+			rendered, err := renderFiles([]templateFile{{Template: source, Dest: dest}}, data, opts)
+			results = append(results, rendered...)
+			if err != nil {
+				return results, err
+			}
+			continue
+		}
+
+		result, err := utils.CopyFileWithResult(source, dest, opts)
+		if err != nil {
+			return results, fmt.Errorf("error copying %s: %w", dest, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,48 @@
+// backend/services/teardown_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// teardownFile is the destroy script scaffolded into each product or customer directory.
+const teardownFile = "teardown.sh"
+
+// generateTeardown writes an executable script that, after a confirmation prompt per environment, destroys the
+// environments in reverse order and each environment's modules in reverse dependency order.
+func generateTeardown(req *models.GenerateRequest, config *models.Config, path string, data map[string]interface{}, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	if req.OutputFormat == models.OutputFormatCDKTF {
+		return nil, fmt.Errorf("a teardown script is not supported for the %s output format", models.OutputFormatCDKTF)
+	}
+
+	inputs, err := entityInputs(req, config, path, data)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(inputs)
+
+	// Modules are resolved dependencies first, so destroying them backwards removes dependants before dependencies
+	modules, _ := data["Modules"].([]models.Module)
+	labels := make([]string, 0, len(modules))
+	for i := len(modules) - 1; i >= 0; i-- {
+		labels = append(labels, modules[i].BlockLabel())
+	}
+
+	teardownData := copyData(data)
+	teardownData["TeardownInputs"] = inputs
+	teardownData["TeardownModules"] = labels
+
+	files := []templateFile{
+		{Template: filepath.Join(templatesDir, "generic", "teardown.sh.tmpl"), Dest: filepath.Join(path, teardownFile)},
+	}
+	results, err := renderFiles(files, teardownData, opts)
+	if err != nil {
+		return results, err
+	}
+	return results, makeExecutable(results, opts)
+}
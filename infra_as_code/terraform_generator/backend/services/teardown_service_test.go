@@ -0,0 +1,73 @@
+// backend/services/teardown_service_test.go
+
+package services
+
+import (
+	"backend/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTeardown(t *testing.T) {
+	chdirBackendRoot(t)
+
+	config := testConfig()
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Customers: []string{"c1"}, Environments: []string{"dev", "test", "prod"}}
+	// Modules come resolved dependencies first: the network before the cluster using it
+	modules := []models.Module{{ModuleName: "vnet"}, {ModuleName: "aks", Label: "cluster"}, {ModuleName: "dns"}}
+	data := prepareTemplateData(req, config, &models.Provider{Name: "azurerm"}, "c1", modules)
+	out := t.TempDir()
+
+	results, err := generateTeardown(req, config, out, data, fileOptions(req, config))
+	if err != nil {
+		t.Fatalf("generateTeardown() error: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != filepath.Join(out, teardownFile) {
+		t.Fatalf("generateTeardown() = %+v, want %s", results, teardownFile)
+	}
+	info, err := os.Stat(results[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("%s mode = %v, want executable", teardownFile, info.Mode().Perm())
+	}
+	content, err := os.ReadFile(results[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := string(content)
+
+	// Environments are destroyed last generated first
+	var previous int
+	for i, env := range []string{"prod", "test", "dev"} {
+		at := strings.Index(script, "if confirm '"+env+"'; then")
+		if at < 0 {
+			t.Fatalf("%s has no confirmation for %s:\n%s", teardownFile, env, script)
+		}
+		if i > 0 && at < previous {
+			t.Errorf("%s destroys %s before the environment generated after it", teardownFile, env)
+		}
+		previous = at
+	}
+
+	// Within an environment, dependants are destroyed before their dependencies and a full destroy follows
+	prod := script[strings.Index(script, "if confirm 'prod'"):strings.Index(script, "if confirm 'test'")]
+	want := strings.Join([]string{
+		"  terraform init -input=false -reconfigure -backend-config='backend/c1_prod.tfvars'",
+		"  terraform destroy -input=false -auto-approve -var-file='vars/c1_prod.tfvars' -target=module.dns",
+		"  terraform destroy -input=false -auto-approve -var-file='vars/c1_prod.tfvars' -target=module.cluster",
+		"  terraform destroy -input=false -auto-approve -var-file='vars/c1_prod.tfvars' -target=module.vnet",
+		"  terraform destroy -input=false -auto-approve -var-file='vars/c1_prod.tfvars'",
+	}, "\n")
+	if !strings.Contains(prod, want) {
+		t.Errorf("prod teardown =\n%s\nwant it to contain\n%s", prod, want)
+	}
+
+	req.OutputFormat = models.OutputFormatCDKTF
+	if _, err := generateTeardown(req, config, out, data, fileOptions(req, config)); err == nil {
+		t.Error("generateTeardown() accepted the cdktf output format")
+	}
+}
@@ -0,0 +1,135 @@
+// backend/services/template_report_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// genericTemplates are the templates under templates/generic that generation renders, depending on the request.
+var genericTemplates = []string{
+	"providers.tf.tmpl", "variables.tf.tmpl", "vars.tfvars.tmpl", "resources.tf.tmpl", "backend.tfvars.tmpl",
+	"versions.tf.tmpl", "atlantis.yaml.tmpl", "CODEOWNERS.tmpl", "pull_request_template.md.tmpl",
+	"tflint.hcl.tmpl", "checkov.yaml.tmpl", "pre-commit-config.yaml.tmpl", "envrc.tmpl", "README.md.tmpl",
+	"root_main.tf.tmpl", "terraform-workflow.yml.tmpl", "teardown.sh.tmpl", "terraform.tf.tmpl", "removed.tf.tmpl", "bootstrap.tf.tmpl",
+	"policy.rego.tmpl", "conftest.toml.tmpl", "CREDENTIALS.md.tmpl", "migrate-backend.sh.tmpl", "backend.tf.tmpl",
+	"environment-terraform.sh.tmpl",
+}
+
+// CheckTemplateUsage loads the configuration and reports unused and missing templates.
+func CheckTemplateUsage() (models.TemplateReport, error) {
+	if err := CheckTemplates(); err != nil {
+		return models.TemplateReport{}, err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return models.TemplateReport{}, fmt.Errorf("error loading configuration: %w", err)
+	}
+	return TemplateUsage(config, templatesDir)
+}
+
+// TemplateUsage compares the templates generation references for config with the files under dir.
+// Provider templates are looked up under every input that resolves to a configured provider, e.g. azure for azurerm.
+func TemplateUsage(config *models.Config, dir string) (models.TemplateReport, error) {
+	// Resolve the root so a symlinked templates directory is walked rather than reported as a single file
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return models.TemplateReport{}, fmt.Errorf("error resolving templates directory: %w", err)
+	}
+
+	existing := make(map[string]bool)
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		existing[filepath.ToSlash(relative)] = true
+		return nil
+	})
+	if err != nil {
+		return models.TemplateReport{}, err
+	}
+
+	used := make(map[string]bool)
+	var missing []string
+	require := func(name string) {
+		used[name] = true
+		if !existing[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	for _, name := range genericTemplates {
+		require("generic/" + name)
+	}
+	require("cdktf/main.ts.tmpl")
+	for _, file := range config.StaticFiles {
+		require(filepath.ToSlash(filepath.Clean(file.Source)))
+	}
+	for _, settings := range config.Customers {
+		if template := settings.MainTemplate(); template != "" {
+			require(filepath.ToSlash(filepath.Clean(template)))
+		}
+	}
+
+	for name := range existing {
+		// Shared partials and provider base and product partials are parsed whenever they exist
+		if strings.HasPrefix(name, "partials/") {
+			used[name] = true
+		}
+	}
+
+	for _, provider := range config.Providers {
+		providerDir := providerTemplateDir(provider.Name, existing)
+		require(providerDir + "/main.tf.tmpl")
+		for name := range existing {
+			if name == providerDir+"/base.tf.tmpl" || strings.HasPrefix(name, providerDir+"/products/") {
+				used[name] = true
+			}
+		}
+
+		rendered := make(map[string]bool)
+		for _, module := range config.Modules {
+			if rendered[module.ModuleName] {
+				continue
+			}
+			rendered[module.ModuleName] = true
+			require(providerDir + "/" + module.ModuleName + "/main.tf.tmpl")
+			require(providerDir + "/" + module.ModuleName + "/variables.tf.tmpl")
+			if len(module.Outputs) > 0 {
+				require(providerDir + "/" + module.ModuleName + "/outputs.tf.tmpl")
+			}
+		}
+	}
+
+	report := models.TemplateReport{Unused: []string{}, Missing: []string{}}
+	for name := range existing {
+		if !used[name] {
+			report.Unused = append(report.Unused, name)
+		}
+	}
+	sort.Strings(report.Unused)
+	report.Missing = append(report.Missing, missing...)
+	sort.Strings(report.Missing)
+	return report, nil
+}
+
+// providerTemplateDir returns the template directory for a configured provider: the first input resolving to it that
+// has a main.tf.tmpl, or the provider name itself when none does.
+func providerTemplateDir(providerName string, existing map[string]bool) string {
+	for _, input := range utils.ProviderInputs(providerName) {
+		if existing[input+"/main.tf.tmpl"] {
+			return input
+		}
+	}
+	return providerName
+}
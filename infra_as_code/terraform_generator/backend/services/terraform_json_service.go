@@ -0,0 +1,111 @@
+// backend/services/terraform_json_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+)
+
+// generateConfigurationFiles writes the root configuration in the requested output format. customerName is empty
+// for a product directory.
+func generateConfigurationFiles(req *models.GenerateRequest, config *models.Config, path, customerName string, data map[string]interface{}, provider *models.Provider, modules []models.Module, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	switch req.OutputFormat {
+	case models.OutputFormatJSON:
+		return generateTerraformJSONFiles(req, config, path, provider, modules, opts)
+	case models.OutputFormatCDKTF:
+		return generateCDKTFFiles(req, config, path, data, provider, modules, opts)
+	default:
+		return generateTerraformFiles(path, data, providerTemplates(req), req.ProductName, mainTemplate(config, providerTemplates(req), customerName), config.ProviderFile, !varsDisabled(req, config), opts)
+	}
+}
+
+// jsonDocument pairs a JSON configuration document with the file it is written to.
+type jsonDocument struct {
+	Dest    string
+	Content map[string]interface{}
+}
+
+// variablesSchemaFile holds the variable declarations of variables.tf in JSON syntax for tools that edit them. It sits
+// in a subdirectory because Terraform would reject the declarations as duplicates next to variables.tf.
+var variablesSchemaFile = filepath.Join("schema", "variables.tf.json")
+
+// generateVariablesSchema writes the variable declarations rendered into variables.tf as a JSON syntax document.
+func generateVariablesSchema(req *models.GenerateRequest, path string, data map[string]interface{}, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	if req.OutputFormat != "" && req.OutputFormat != models.OutputFormatHCL {
+		return nil, fmt.Errorf("%s is only generated for the %s output format", variablesSchemaFile, models.OutputFormatHCL)
+	}
+	variables, _ := data["Variables"].(map[string]models.Variable)
+	dest := filepath.Join(path, variablesSchemaFile)
+	result, err := utils.WriteJSONFile(dest, utils.VariablesJSON(variables), opts)
+	if err != nil {
+		return nil, fmt.Errorf("error generating %s: %w", dest, err)
+	}
+	return []models.FileResult{result}, nil
+}
+
+// generateTerraformJSONFiles creates providers.tf.json, main.tf.json, variables.tf.json, vars.tfvars.json unless vars
+// are disabled, resources.tf.json when resources are configured, removed.tf.json when removed blocks are and
+// terraform.tf.json when a cloud block is. provider_file moves documents together as in generateTerraformFiles.
+// The documents are built from the configuration models rather than the HCL templates.
+func generateTerraformJSONFiles(req *models.GenerateRequest, config *models.Config, path string, provider *models.Provider, modules []models.Module, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	variables := templateVariables(req, config, config.Environment)
+	providers := utils.ProvidersJSON(provider.ForEnvironment(config.Environment), utils.FilterLinkedProviders(config.LinkedProviders, req.Provider), config.TerraformVersion.ForEnvironment(config.Environment), utils.MergeTags(config.DefaultTags, req.Tags, config.Environment), utils.ProviderAliases(config.Regions))
+	main := utils.MainJSON(modules, moduleCallVariables(modules))
+	var backend, cloud map[string]interface{}
+	if config.Cloud != nil {
+		cloud = utils.CloudJSON(*config.Cloud)
+	} else {
+		backend = utils.BackendJSON(config.Backend)
+	}
+
+	// The provider document joins the file chosen by provider_file together with the backend or cloud block, like
+	// the HCL templates
+	var documents []jsonDocument
+	switch config.ProviderFile {
+	case models.ProviderFileMain:
+		documents = append(documents, jsonDocument{Dest: filepath.Join(path, "main.tf.json"), Content: utils.MergeJSONDocuments(providers, backend, cloud, main)})
+	case models.ProviderFileTerraform:
+		documents = append(documents,
+			jsonDocument{Dest: filepath.Join(path, "terraform.tf.json"), Content: utils.MergeJSONDocuments(providers, backend, cloud)},
+			jsonDocument{Dest: filepath.Join(path, "main.tf.json"), Content: main},
+		)
+	default:
+		documents = append(documents,
+			jsonDocument{Dest: filepath.Join(path, "providers.tf.json"), Content: providers},
+			jsonDocument{Dest: filepath.Join(path, "main.tf.json"), Content: utils.MergeJSONDocuments(backend, main)},
+		)
+		if cloud != nil {
+			documents = append(documents, jsonDocument{Dest: filepath.Join(path, "terraform.tf.json"), Content: cloud})
+		}
+	}
+	documents = append(documents, jsonDocument{Dest: filepath.Join(path, "variables.tf.json"), Content: utils.VariablesJSON(variables)})
+	if len(config.Removed) > 0 {
+		documents = append(documents, jsonDocument{Dest: filepath.Join(path, "removed.tf.json"), Content: utils.RemovedJSON(config.Removed)})
+	}
+	if !varsDisabled(req, config) {
+		documents = append(documents, jsonDocument{Dest: filepath.Join(path, "vars.tfvars.json"), Content: utils.TfvarsJSON(variables)})
+	}
+	if resources := utils.FilterResourcesByProvider(config.Resources, req.Provider); len(resources) > 0 {
+		documents = append(documents, jsonDocument{Dest: filepath.Join(path, "resources.tf.json"), Content: utils.ResourcesJSON(resources)})
+	}
+
+	var results []models.FileResult
+	for _, document := range documents {
+		result, err := utils.WriteJSONFile(document.Dest, document.Content, opts)
+		if err != nil {
+			return results, fmt.Errorf("error generating %s: %w", document.Dest, err)
+		}
+		results = append(results, result)
+	}
+	for _, name := range staleSettingsFiles(config.ProviderFile, ".tf.json") {
+		removed, err := removeStaleFile(filepath.Join(path, name), opts)
+		results = append(results, removed...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
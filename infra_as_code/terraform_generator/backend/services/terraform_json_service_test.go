@@ -0,0 +1,63 @@
+// backend/services/terraform_json_service_test.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateVariablesSchema(t *testing.T) {
+	config := testConfig()
+	config.Variables["api_key"] = models.Variable{Type: "string", Sensitive: true}
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure"}
+	data := prepareTemplateData(req, config, &models.Provider{Name: "azurerm"}, "", nil)
+	out := t.TempDir()
+
+	results, err := generateVariablesSchema(req, out, data, utils.GenerateOptions{Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != filepath.Join(out, "schema", "variables.tf.json") {
+		t.Fatalf("generateVariablesSchema() = %+v, want schema/variables.tf.json", results)
+	}
+	content, err := os.ReadFile(results[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Variable map[string]map[string]interface{} `json:"variable"`
+	}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		t.Fatalf("schema/variables.tf.json is not JSON: %v\n%s", err, content)
+	}
+	if len(schema.Variable) != 2 {
+		t.Fatalf("schema declares %d variables, want sku and api_key:\n%s", len(schema.Variable), content)
+	}
+	if sku := schema.Variable["sku"]; sku["type"] != "string" || sku["description"] != "SKU" {
+		t.Errorf("sku = %v, want a string described as SKU", sku)
+	}
+	if apiKey := schema.Variable["api_key"]; apiKey["sensitive"] != true {
+		t.Errorf("api_key = %v, want it sensitive", apiKey)
+	}
+
+	// A dry run reports the file without writing it
+	dryRun := filepath.Join(t.TempDir(), "dry")
+	if results, err := generateVariablesSchema(req, dryRun, data, utils.GenerateOptions{DryRun: true}); err != nil || len(results) != 1 {
+		t.Fatalf("generateVariablesSchema() dry run = %+v, %v", results, err)
+	}
+	if _, err := os.Stat(dryRun); !os.IsNotExist(err) {
+		t.Errorf("dry run created %s", dryRun)
+	}
+
+	for _, format := range []string{models.OutputFormatJSON, models.OutputFormatCDKTF} {
+		req.OutputFormat = format
+		if _, err := generateVariablesSchema(req, out, data, utils.GenerateOptions{Overwrite: true}); err == nil {
+			t.Errorf("generateVariablesSchema() for %s succeeded, want an error", format)
+		}
+	}
+}
@@ -5,63 +5,538 @@ package services
 import (
 	"backend/models"
 	"backend/utils"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
+	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
-// GenerateTerraform processes the request to generate Terraform files.
-func GenerateTerraform(req *models.GenerateRequest) error {
+// configPath is the generator configuration file, relative to the working directory.
+const configPath = "configs/terraform-generator.json"
+
+// configCache keeps the configuration between calls; it is reloaded by ReloadConfig and WatchConfig. Values read
+// from Vault are cached with it, so rotated secrets are only read again on a reload.
+var configCache = utils.NewConfigCache(configPath)
+
+// loadConfig returns a copy of the cached configuration that the caller may change.
+func loadConfig() (*models.Config, error) {
+	return configCache.Load()
+}
+
+// ReloadConfig reads the configuration file again. An invalid file is reported and the previous configuration kept.
+func ReloadConfig() error {
+	return configCache.Reload()
+}
+
+// WatchConfig reloads the configuration whenever its file changes, reporting the outcome of each reload, until the
+// returned function is called.
+func WatchConfig(report func(error)) (func() error, error) {
+	return configCache.Watch(report)
+}
+
+// templatesDir is the root of the template tree, relative to the working directory.
+const templatesDir = "templates"
+
+// ErrInvalidRequest marks errors caused by the request rather than the configuration or the file system.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// CheckTemplates reports a descriptive error when the templates directory cannot be found.
+func CheckTemplates() error {
+	return utils.CheckDirectory(templatesDir, "templates")
+}
+
+// GenerateTerraform processes the request to generate Terraform files and reports every file it touched.
+func GenerateTerraform(req *models.GenerateRequest) ([]models.FileResult, error) {
 	if req.OrganisationName == "" || req.ProductName == "" || req.Provider == "" {
-		return fmt.Errorf("organisation_name, product_name, and provider are required")
+		return nil, fmt.Errorf("organisation_name, product_name, and provider are required")
+	}
+	switch req.OutputFormat {
+	case "", models.OutputFormatHCL, models.OutputFormatJSON, models.OutputFormatCDKTF:
+	default:
+		return nil, fmt.Errorf("unsupported output_format '%s': expected %s, %s or %s", req.OutputFormat, models.OutputFormatHCL, models.OutputFormatJSON, models.OutputFormatCDKTF)
+	}
+
+	if err := checkRequestNames(req); err != nil {
+		return nil, err
+	}
+
+	// Customers that cannot be called from the organisation-level main.tf fail the run before anything is written
+	if req.GenerateRootMain {
+		if _, err := customerModules(req); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fail with the resolved path rather than an opaque error from the first template parse
+	if err := CheckTemplates(); err != nil {
+		return nil, err
+	}
+	if err := checkFlavor(req); err != nil {
+		return nil, err
 	}
 
 	// Load configuration from terraform-generator.json
-	config, err := utils.LoadConfig("configs/terraform-generator.json")
+	config, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+	// Nothing is written for a request over the limit
+	if err := checkCustomerLimit(config, len(req.Customers)); err != nil {
+		return nil, err
+	}
+	providerData, modules, err := prepareConfig(req, config)
 	if err != nil {
-		return fmt.Errorf("error loading configuration: %w", err)
+		return nil, err
+	}
+
+	// A required variable without a value only fails at plan time, so these runs report it before anything is
+	// written. Values supplied outside the generator cannot be checked.
+	if req.RequireValues && !varsDisabled(req, config) {
+		if err := checkRequiredValues(req, config, providerData, modules); err != nil {
+			return nil, err
+		}
+	}
+
+	// Modules and other organisation-wide files go to the directory shared by the products and customers
+	basePath, err := organisationPath(config, providerData.Name, req.OrganisationName)
+	if err != nil {
+		return nil, err
+	}
+	opts := fileOptions(req, config)
+
+	// Generate module files
+	results, err := generateModuleFiles(basePath, modules, providerTemplates(req), providerData, opts)
+	if err != nil {
+		return results, fmt.Errorf("error generating module files: %w", err)
+	}
+
+	// Generate files for a single product or customers
+	var generated []models.FileResult
+	if len(req.Customers) > 0 {
+		generated, err = processCustomers(req, config, basePath, providerData, modules)
+	} else {
+		// Generate product-specific files
+		var productPath string
+		if productPath, err = OutputDir(config, providerData.Name, req.OrganisationName, req.ProductName, ""); err != nil {
+			return results, err
+		}
+		var directories []models.FileResult
+		directories, err = utils.CreateDirectories(environmentDirectories(config, productPath, false), opts)
+		results = append(results, directories...)
+		if err != nil {
+			return results, fmt.Errorf("error creating directories for product: %w", err)
+		}
+		generated, err = generateProductFiles(req, config, productPath, providerData, modules)
+	}
+	results = append(results, generated...)
+	if err != nil {
+		return results, err
+	}
+
+	// Atlantis projects span every product or customer directory, so the file sits in the organisation directory
+	if req.GenerateAtlantis {
+		atlantisResults, err := generateAtlantisConfig(req, config, basePath, opts)
+		results = append(results, atlantisResults...)
+		if err != nil {
+			return results, fmt.Errorf("error generating %s: %w", atlantisConfigFile, err)
+		}
+	}
+
+	// The orchestration configuration calls the customer directories just generated
+	if req.GenerateRootMain {
+		rootResults, err := generateRootMain(req, config, providerData.Name, basePath, opts)
+		results = append(results, rootResults...)
+		if err != nil {
+			return results, fmt.Errorf("error generating organisation %s: %w", rootMainFile, err)
+		}
+	}
+
+	// The state storage is shared by the organisation's products and customers
+	if req.GenerateBootstrap {
+		bootstrapResults, err := generateBootstrap(req, config, basePath, opts)
+		results = append(results, bootstrapResults...)
+		if err != nil {
+			return results, fmt.Errorf("error generating %s: %w", bootstrapDir, err)
+		}
+	}
+
+	// A dry run leaves the record of the last real run alone
+	if req.DryRun {
+		return results, nil
+	}
+	if err := writeManifest(basePath, req, results); err != nil {
+		return results, fmt.Errorf("error writing manifest: %w", err)
+	}
+
+	// The history log lives outside the Terraform files, so a failure to record it does not fail the run
+	if err := appendGenerationLog(basePath, req, time.Now()); err != nil {
+		log.Printf("warning: could not update %s: %v", generationLogFile, err)
+	}
+	return results, nil
+}
+
+// checkRequiredValues reports the variables left without a default or value for the product or, through their
+// patched configurations, for any of the requested customers.
+func checkRequiredValues(req *models.GenerateRequest, config *models.Config, provider *models.Provider, modules []models.Module) error {
+	if len(req.Customers) == 0 {
+		return checkRequiredVariables(req, config)
+	}
+	for _, customer := range req.Customers {
+		customer = strings.TrimSpace(customer)
+		patched, _, _, err := customerConfig(req, config, customer, provider, modules)
+		if err != nil {
+			return err
+		}
+		if err := checkRequiredVariables(req, patched); err != nil {
+			return fmt.Errorf("customer %s: %w", customer, err)
+		}
+	}
+	return nil
+}
+
+// checkRequiredVariables reports the variables left without a default or value in any generated environment.
+func checkRequiredVariables(req *models.GenerateRequest, config *models.Config) error {
+	environments := environmentsFor(req)
+	if config.Environment != "" && !slices.Contains(environments, config.Environment) {
+		environments = append([]string{config.Environment}, environments...)
+	}
+
+	missing := make(map[string][]string)
+	var names []string
+	for _, env := range environments {
+		for _, name := range utils.MissingRequiredVariables(templateVariables(req, config, env)) {
+			if _, seen := missing[name]; !seen {
+				names = append(names, name)
+			}
+			missing[name] = append(missing[name], env)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	sort.Strings(names)
+	problems := make([]string, 0, len(names))
+	for _, name := range names {
+		problems = append(problems, fmt.Sprintf("%s (%s)", name, strings.Join(missing[name], ", ")))
+	}
+	return fmt.Errorf("%w: required variables have no default or value: %s", ErrInvalidRequest, strings.Join(problems, "; "))
+}
+
+// validateRequestRegion checks a request's region override against the configured regions, when any are listed
+func validateRequestRegion(req *models.GenerateRequest, config *models.Config) error {
+	if req.Region == "" {
+		return nil
+	}
+	if strings.TrimSpace(req.Region) != req.Region {
+		return fmt.Errorf("%w: region %q must not have surrounding whitespace", ErrInvalidRequest, req.Region)
+	}
+	if len(config.Regions) == 0 {
+		return nil
+	}
+
+	allowed := config.Regions
+	if config.Region != "" && !slices.Contains(allowed, config.Region) {
+		allowed = append([]string{config.Region}, allowed...)
+	}
+	if !slices.Contains(allowed, req.Region) {
+		return fmt.Errorf("%w: region %q is not configured: expected one of %s", ErrInvalidRequest, req.Region, strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// applyVariableDefaults replaces the defaults of the variables the request overrides with the given text, parsed as
+// the variable's declared type. Overrides apply to every environment of a per-environment default.
+func applyVariableDefaults(req *models.GenerateRequest, config *models.Config) error {
+	for _, name := range slices.Sorted(maps.Keys(req.VariableDefaults)) {
+		variable, ok := config.Variables[name]
+		if !ok {
+			return fmt.Errorf("%w: variable '%s' is not declared", ErrInvalidRequest, name)
+		}
+		value, err := utils.ParseVariableValue(name, req.VariableDefaults[name], variable.Type)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+		}
+		variable.Default, variable.PerEnvironment = value, false
+		config.Variables[name] = variable
+	}
+	return nil
+}
+
+// validateCounts checks that every per-environment count of the rendered modules and resources covers the generated
+// environments and the configured environment variables.tf defaults to, or has a "default" entry.
+func validateCounts(req *models.GenerateRequest, config *models.Config, modules []models.Module) error {
+	envs := environmentsFor(req)
+	if config.Environment != "" && !slices.Contains(envs, config.Environment) {
+		envs = append([]string{config.Environment}, envs...)
+	}
+	problems := utils.UncoveredCounts(modules, utils.FilterResourcesByProvider(config.Resources, req.Provider), envs)
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid count: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateLinkedProviders checks that every module output the request's linked providers reference belongs to a
+// rendered module, since the output would not exist otherwise.
+func validateLinkedProviders(req *models.GenerateRequest, config *models.Config, modules []models.Module) error {
+	rendered := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		rendered[module.BlockLabel()] = true
+	}
+	for _, provider := range utils.FilterLinkedProviders(config.LinkedProviders, req.Provider) {
+		for _, reference := range utils.ModuleReferences(provider.Settings) {
+			if !rendered[reference.Label] {
+				return fmt.Errorf("linked provider '%s' references module.%s.%s, but module '%s' is not generated", provider.Name, reference.Label, reference.Output, reference.Label)
+			}
+		}
+	}
+	return nil
+}
+
+// prepareConfig validates a loaded or patched configuration against the request and completes it in place: file
+// references, backends, variable types, values and defaults. It returns the request's provider and the modules to
+// generate with their dependencies.
+func prepareConfig(req *models.GenerateRequest, config *models.Config) (*models.Provider, []models.Module, error) {
+	if err := utils.ValidateConfig(config); err != nil {
+		return nil, nil, err
+	}
+	if err := utils.ResolveFileReferences(config, filepath.Dir(configPath)); err != nil {
+		return nil, nil, err
+	}
+	if err := validateRequestRegion(req, config); err != nil {
+		return nil, nil, err
+	}
+	if err := checkMigration(req, config); err != nil {
+		return nil, nil, err
+	}
+	if err := checkCustomerTemplates(req, config); err != nil {
+		return nil, nil, err
+	}
+	var err error
+	if config.Backend, err = resolveBackend(req, config, config.Backend); err != nil {
+		return nil, nil, err
+	}
+	for env, backend := range config.Backend.Environments {
+		if config.Backend.Environments[env], err = resolveBackend(req, config, backend); err != nil {
+			return nil, nil, fmt.Errorf("backend.environments.%s: %w", env, err)
+		}
+	}
+	utils.InferVariableTypes(config)
+	utils.CoerceVariableValues(config)
+	if err := applyVariableDefaults(req, config); err != nil {
+		return nil, nil, err
 	}
 
 	// Filter provider data based on the input provider
 	providerData := utils.FilterProviderData(config.Providers, req.Provider)
 	if providerData == nil {
-		return fmt.Errorf("specified provider '%s' not found in configuration", req.Provider)
+		return nil, nil, fmt.Errorf("specified provider '%s' not found in configuration", req.Provider)
 	}
+	providerData.Source = utils.ResolveProviderSource(providerData.Source, config.ProviderSourceHost)
 
 	// Resolve module dependencies
 	modules, err := utils.ResolveModuleDependencies(req.Modules, config.Modules)
 	if err != nil {
-		return fmt.Errorf("error resolving module dependencies: %w", err)
+		return nil, nil, fmt.Errorf("error resolving module dependencies: %w", err)
+	}
+	if err := validateCounts(req, config, modules); err != nil {
+		return nil, nil, err
+	}
+	if err := validateLinkedProviders(req, config, modules); err != nil {
+		return nil, nil, err
 	}
+	return providerData, modules, nil
+}
+
+// resolveBackend reads the backend's from_env fields, fills in the defaults derived from the request and validates it.
+func resolveBackend(req *models.GenerateRequest, config *models.Config, backend models.Backend) (models.Backend, error) {
+	if config.Cloud != nil {
+		// Validation keeps the backend empty next to a cloud block, and it must stay empty
+		return backend, nil
+	}
+	backend, err := utils.ResolveBackendFromEnv(backend)
+	if err != nil {
+		return backend, err
+	}
+	if backend.Region == "" {
+		// State usually lives with the resources unless a central region is configured
+		backend.Region = resourceRegion(req, config)
+	}
+	// The default key differs per entity and environment, so environmentData fills it in; validation checks the
+	// product-level one in its place
+	return backend, utils.ValidateBackend(utils.DefaultBackendKey(backend, req.OrganisationName, req.ProductName, "", ""))
+}
 
-	// Update basePath to include 'output' directory
-	basePath := filepath.Join("output", "terraform", req.OrganisationName)
+// outputRoot is the directory every configured output path is relative to.
+var outputRoot = filepath.Join("output", "terraform")
 
-	// Generate module files
-	if err := generateModuleFiles(basePath, modules, req.Provider); err != nil {
-		return fmt.Errorf("error generating module files: %w", err)
+// OutputDir returns the directory generated files are written to for a product, or for a customer when one is given,
+// laid out by the configured output path.
+func OutputDir(config *models.Config, provider, organisation, product, customer string) (string, error) {
+	relative, err := utils.OutputPath(config.OutputPath, utils.OutputPathData{
+		Provider:         provider,
+		OrganisationName: organisation,
+		ProductName:      product,
+		CustomerName:     customer,
+	})
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(outputRoot, relative), nil
+}
 
-	// Generate files for a single product or customers
-	if len(req.Customers) > 0 {
-		return processCustomers(req, config, basePath, providerData, modules)
+// organisationPath returns the directory shared by an organisation's products and customers: the output path
+// rendered without either. Modules, manifests and the generation log are written there.
+func organisationPath(config *models.Config, provider, organisation string) (string, error) {
+	return OutputDir(config, provider, organisation, "", "")
+}
+
+// ResolveOutputDir loads the configuration and returns the output directory for a product or customer. The provider
+// input is resolved to its Terraform name; it only matters when the output path uses it.
+func ResolveOutputDir(provider, organisation, product, customer string) (string, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return "", fmt.Errorf("error loading configuration: %w", err)
 	}
+	if name := utils.NormalizeProviderName(provider); name != "" {
+		provider = name
+	}
+	return OutputDir(config, provider, organisation, product, customer)
+}
 
-	// Generate product-specific files
-	productPath := filepath.Join(basePath, req.ProductName)
-	if err := utils.CreateDirectories([]string{filepath.Join(productPath, "backend")}); err != nil {
-		return fmt.Errorf("error creating directories for product: %w", err)
+// EnvironmentFiles holds the Terraform arguments for running a generated product's root configuration in one
+// environment. Paths are relative to the product's output directory.
+type EnvironmentFiles struct {
+	BackendConfig string // Passed to terraform init as -backend-config; empty with a local backend in the flat layout or a cloud block
+	VarFile       string // Passed to plan, apply and destroy as -var-file; empty when no vars file is generated
+	DataDir       string // TF_DATA_DIR keeping the environment's initialised backend apart; empty in the flat layout
+}
+
+// ResolveEnvironmentFiles loads the configuration and returns the backend and vars files generation wrote for a
+// product's environment, named and laid out as environmentTargets names them. A vars.tfvars.json written by JSON
+// output is preferred when it exists.
+func ResolveEnvironmentFiles(provider, organisation, product, env string) (EnvironmentFiles, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return EnvironmentFiles{}, fmt.Errorf("error loading configuration: %w", err)
+	}
+	if !utils.IsSafePathSegment(env) {
+		return EnvironmentFiles{}, fmt.Errorf("%w: invalid environment %q", ErrInvalidRequest, env)
+	}
+	dir, err := ResolveOutputDir(provider, organisation, product, "")
+	if err != nil {
+		return EnvironmentFiles{}, err
 	}
+	req := &models.GenerateRequest{OrganisationName: organisation, ProductName: product, Provider: provider, Environments: []string{env}}
+	targets, err := environmentTargets(req, config, "", nil, product, true)
+	if err != nil {
+		return EnvironmentFiles{}, err
+	}
+
+	target := targets[0]
+	files := EnvironmentFiles{BackendConfig: target.BackendPath, VarFile: target.VarsPath}
+	if config.Layout == models.LayoutEnvironments {
+		files.DataDir = filepath.Join("envs", env, ".terraform")
+		if config.Cloud == nil && config.Backend.Type == models.BackendLocal {
+			// As in the environment's terraform.sh, each environment keeps its state in its own directory
+			files.BackendConfig = "path=" + filepath.Join("envs", env, "terraform.tfstate")
+		}
+	}
+	if files.VarFile != "" {
+		if _, err := os.Stat(filepath.Join(dir, files.VarFile+".json")); err == nil {
+			files.VarFile += ".json"
+		}
+	}
+	return files, nil
+}
+
+// GeneratedFilePath resolves a previously generated file in the output directory of a product or customer, rejecting
+// names that would escape it. The directory follows the configured output_path, which only includes the provider when
+// the pattern uses it.
+func GeneratedFilePath(provider, organisation, product, customer, file string) (string, error) {
+	segments := map[string]string{"organisation_name": organisation, "product_name": product}
+	if customer != "" {
+		segments["customer"] = customer
+	}
+	if provider != "" {
+		segments["provider"] = provider
+	}
+	for _, name := range []string{"organisation_name", "product_name", "customer", "provider"} {
+		if segment, ok := segments[name]; ok && !utils.IsSafePathSegment(segment) {
+			return "", fmt.Errorf("%w: invalid %s %q", ErrInvalidRequest, name, segment)
+		}
+	}
+	if !filepath.IsLocal(file) {
+		return "", fmt.Errorf("%w: invalid file path %q", ErrInvalidRequest, file)
+	}
+	dir, err := ResolveOutputDir(provider, organisation, product, customer)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, file), nil
+}
+
+// defaultEnvironments are generated when the request does not list its own.
+var defaultEnvironments = []string{"nonprod", "prod"}
+
+// environmentsFor returns the environments to generate environment-specific files for.
+func environmentsFor(req *models.GenerateRequest) []string {
+	if len(req.Environments) > 0 {
+		return req.Environments
+	}
+	return defaultEnvironments
+}
+
+// varsDisabled reports whether vars.tfvars files are left to an external source by the request or configuration.
+func varsDisabled(req *models.GenerateRequest, config *models.Config) bool {
+	return req.NoVars || config.SkipVars
+}
 
-	return generateProductFiles(req, config, productPath, providerData, modules)
+// fileOptions derives the file writing options from the request and the configured protected files and render timeout.
+func fileOptions(req *models.GenerateRequest, config *models.Config) utils.GenerateOptions {
+	timeout := defaultRenderTimeout
+	if config.RenderTimeout != "" {
+		// Validated with the configuration
+		timeout, _ = time.ParseDuration(config.RenderTimeout)
+	}
+	return utils.GenerateOptions{
+		Overwrite: !req.NoOverwrite,
+		Strict:    !req.AllowMissingKeys,
+		Partials:  filepath.Join(templatesDir, "partials"),
+		Protected: append(slices.Clone(utils.DefaultProtectedFiles), config.ProtectedFiles...),
+		Timeout:   timeout,
+		DryRun:    req.DryRun,
+	}
 }
 
-// generateModuleFiles creates module directories and files.
-func generateModuleFiles(basePath string, modules []models.Module, provider string) error {
+// defaultRenderTimeout bounds the rendering of a single template when the configuration sets no render_timeout.
+const defaultRenderTimeout = 30 * time.Second
+
+// generateModuleFiles creates module directories and files from the module templates under templateDir.
+// Modules that expect aliased provider configurations from their caller also get a versions.tf declaring them.
+func generateModuleFiles(basePath string, modules []models.Module, templateDir string, providerData *models.Provider, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	var results []models.FileResult
+	rendered := make(map[string]bool)
 	for _, module := range modules {
+		// Instances of the same module share one set of module files
+		if rendered[module.ModuleName] {
+			continue
+		}
+		rendered[module.ModuleName] = true
+
 		modulePath := filepath.Join(basePath, "modules", module.ModuleName)
-		if err := utils.CreateDirectories([]string{modulePath}); err != nil {
-			return err
+		directories, err := utils.CreateDirectories([]string{modulePath}, opts)
+		results = append(results, directories...)
+		if err != nil {
+			return results, err
 		}
 
 		// Prepare data for templates
@@ -69,183 +544,767 @@ func generateModuleFiles(basePath string, modules []models.Module, provider stri
 			"Module":          module,
 			"ResourceName":    module.ModuleName,
 			"ModuleVariables": module.Variables, // Pass module variables directly
+			"Provider":        providerData,
+			"RootModule":      false,
 		}
 
-		files := []struct {
-			Template string
-			Dest     string
-		}{
+		files := []templateFile{
 			{
-				Template: filepath.Join("templates", provider, module.ModuleName, "main.tf.tmpl"),
+				Template: filepath.Join(templateDir, module.ModuleName, "main.tf.tmpl"),
 				Dest:     filepath.Join(modulePath, "main.tf"),
 			},
 			{
-				Template: filepath.Join("templates", provider, module.ModuleName, "variables.tf.tmpl"),
+				Template: filepath.Join(templateDir, module.ModuleName, "variables.tf.tmpl"),
 				Dest:     filepath.Join(modulePath, "variables.tf"),
 			},
 		}
 
 		// Include outputs.tf if outputs are defined
 		if len(module.Outputs) > 0 {
-			files = append(files, struct {
-				Template string
-				Dest     string
-			}{
-				Template: filepath.Join("templates", provider, module.ModuleName, "outputs.tf.tmpl"),
+			files = append(files, templateFile{
+				Template: filepath.Join(templateDir, module.ModuleName, "outputs.tf.tmpl"),
 				Dest:     filepath.Join(modulePath, "outputs.tf"),
 			})
 		}
 
+		if len(module.ConfigurationAliases) > 0 {
+			files = append(files, templateFile{
+				Template: filepath.Join(templatesDir, "generic", "versions.tf.tmpl"),
+				Dest:     filepath.Join(modulePath, "versions.tf"),
+			})
+		}
+
 		// Generate files
-		for _, file := range files {
-			if err := utils.GenerateFileFromTemplate(file.Template, file.Dest, data); err != nil {
-				return fmt.Errorf("error generating file %s: %w", file.Dest, err)
-			}
+		moduleResults, err := renderFiles(files, data, opts)
+		results = append(results, moduleResults...)
+		if err != nil {
+			return results, err
 		}
 	}
-	return nil
+	return results, nil
 }
 
 // generateProductFiles creates Terraform files for a single product.
-func generateProductFiles(req *models.GenerateRequest, config *models.Config, productPath string, provider *models.Provider, modules []models.Module) error {
+func generateProductFiles(req *models.GenerateRequest, config *models.Config, productPath string, provider *models.Provider, modules []models.Module) ([]models.FileResult, error) {
 	data := prepareTemplateData(req, config, provider, "", modules)
+	opts := fileOptions(req, config)
+	if req.Debug {
+		utils.LogTemplateData(req.OrganisationName+"/"+req.ProductName, data)
+	}
 
 	// Generate files
-	if err := generateTerraformFiles(productPath, data, req.Provider, req.ProductName); err != nil {
-		return err
+	results, err := generateConfigurationFiles(req, config, productPath, "", data, provider, modules, opts)
+	if err != nil {
+		return results, err
+	}
+
+	// Record the generator build that produced the directory
+	versionResult, err := writeGeneratorVersion(productPath, opts)
+	if err != nil {
+		return results, err
 	}
+	results = append(results, versionResult)
 
 	// Generate backend tfvars files
-	return generateBackendTfvarsFiles(productPath, data, req.ProductName)
+	backendResults, err := generateEnvironmentFiles(req, config, productPath, data, req.ProductName, false, opts)
+	results = append(results, backendResults...)
+	if err != nil {
+		return results, err
+	}
+
+	// Generate optional repository scaffolding
+	scaffoldResults, err := generateScaffoldFiles(req, config, productPath, data, opts)
+	return append(results, scaffoldResults...), err
 }
 
-// processCustomers generates Terraform files for multiple customers.
-func processCustomers(req *models.GenerateRequest, config *models.Config, basePath string, provider *models.Provider, modules []models.Module) error {
+// defaultMaxCustomers caps the customers of a single request when the configuration sets no max_customers, so one
+// request cannot fill the disk of a shared instance.
+const defaultMaxCustomers = 500
+
+// checkCustomerLimit fails a request generating more customer directories than the configuration allows at once.
+// Multi-organisation requests count each customer once per organisation.
+func checkCustomerLimit(config *models.Config, customers int) error {
+	limit := defaultMaxCustomers
+	if config.MaxCustomers > 0 {
+		limit = config.MaxCustomers
+	}
+	if customers > limit {
+		return fmt.Errorf("%w: %d customers requested, at most %d are allowed per request; split the customers across several requests", ErrInvalidRequest, customers, limit)
+	}
+	return nil
+}
+
+// checkRequestNames rejects organisation, product and customer names that are not a single path segment, since each
+// names an output directory.
+func checkRequestNames(req *models.GenerateRequest) error {
+	names := []struct{ field, name string }{{"organisation_name", req.OrganisationName}, {"product_name", req.ProductName}}
+	for _, customer := range req.Customers {
+		names = append(names, struct{ field, name string }{"customer", strings.TrimSpace(customer)})
+	}
+	for _, name := range names {
+		if !utils.IsSafePathSegment(name.name) {
+			return fmt.Errorf("%w: invalid %s %q", ErrInvalidRequest, name.field, name.name)
+		}
+	}
+	return nil
+}
+
+// processCustomers generates Terraform files for multiple customers and records them in the organisation's inventory.json.
+func processCustomers(req *models.GenerateRequest, config *models.Config, basePath string, provider *models.Provider, modules []models.Module) ([]models.FileResult, error) {
+	// A customer patch that fails fails the run before any customer is written
+	customerConfigs := make(map[string]*models.Config, len(req.Customers))
 	for _, customer := range req.Customers {
 		customer = strings.TrimSpace(customer)
-		customerPath := filepath.Join(basePath, customer)
-		paths := []string{
-			filepath.Join(customerPath, "backend"),
-			filepath.Join(customerPath, "vars"),
+		patched, _, _, err := customerConfig(req, config, customer, provider, modules)
+		if err != nil {
+			return nil, err
 		}
+		customerConfigs[customer] = patched
+	}
 
+	var results []models.FileResult
+	inventory := models.Inventory{Organisation: req.OrganisationName, Product: req.ProductName, Customers: []models.InventoryCustomer{}}
+	for _, customer := range req.Customers {
+		customer = strings.TrimSpace(customer)
+		customerPath, err := OutputDir(config, provider.Name, req.OrganisationName, req.ProductName, customer)
+		if err != nil {
+			return results, err
+		}
 		// Create directories
-		if err := utils.CreateDirectories(paths); err != nil {
-			return err
+		directories, err := utils.CreateDirectories(environmentDirectories(config, customerPath, !varsDisabled(req, config)), fileOptions(req, config))
+		results = append(results, directories...)
+		if err != nil {
+			return results, err
 		}
 
 		// Generate files for the customer
-		if err := generateCustomerFiles(req, config, customerPath, customer, provider, modules); err != nil {
-			return err
+		customerResults, err := generateCustomerFiles(req, config, customerPath, customer, provider, modules)
+		results = append(results, customerResults...)
+		if err != nil {
+			return results, err
 		}
+		inventory.Customers = append(inventory.Customers, inventoryCustomer(req, customerConfigs[customer], provider, customer, customerPath, customerResults))
 	}
-	return nil
+
+	if req.DryRun {
+		return results, nil
+	}
+	if err := writeInventory(basePath, inventory); err != nil {
+		return results, fmt.Errorf("error writing %s: %w", inventoryFile, err)
+	}
+	return results, nil
 }
 
 // generateCustomerFiles creates Terraform files for a single customer.
-func generateCustomerFiles(req *models.GenerateRequest, config *models.Config, customerPath, customerName string, provider *models.Provider, modules []models.Module) error {
+func generateCustomerFiles(req *models.GenerateRequest, config *models.Config, customerPath, customerName string, provider *models.Provider, modules []models.Module) ([]models.FileResult, error) {
+	config, provider, modules, err := customerConfig(req, config, customerName, provider, modules)
+	if err != nil {
+		return nil, err
+	}
 	data := prepareTemplateData(req, config, provider, customerName, modules)
+	opts := fileOptions(req, config)
+	if req.Debug {
+		utils.LogTemplateData(req.OrganisationName+"/"+customerName, data)
+	}
 
 	// Generate files
-	if err := generateTerraformFiles(customerPath, data, req.Provider, customerName); err != nil {
-		return err
+	results, err := generateConfigurationFiles(req, config, customerPath, customerName, data, provider, modules, opts)
+	if err != nil {
+		return results, err
 	}
 
+	// Record the generator build that produced the directory
+	versionResult, err := writeGeneratorVersion(customerPath, opts)
+	if err != nil {
+		return results, err
+	}
+	results = append(results, versionResult)
+
 	// Generate backend and vars tfvars files
-	return generateBackendAndVarsTfvarsFiles(customerPath, data, customerName)
+	tfvarsResults, err := generateEnvironmentFiles(req, config, customerPath, data, customerName, true, opts)
+	results = append(results, tfvarsResults...)
+	if err != nil {
+		return results, err
+	}
+
+	// Generate optional repository scaffolding
+	scaffoldResults, err := generateScaffoldFiles(req, config, customerPath, data, opts)
+	return append(results, scaffoldResults...), err
 }
 
 // prepareTemplateData prepares the data structure for the templates
 func prepareTemplateData(req *models.GenerateRequest, config *models.Config, provider *models.Provider, customerName string, modules []models.Module) map[string]interface{} {
 	// Extract generic variables from config
-	genericVariables := config.Variables
+	genericVariables := templateVariables(req, config, config.Environment)
 
 	// Prepare module variables for module calls in main.tf
-	moduleVariables := make(map[string]map[string]models.Variable)
-	for _, module := range modules {
-		vars := make(map[string]models.Variable)
-		for varName, varDef := range module.Variables {
-			// Extract the embedded Variable from ModuleVariable
-			vars[varName] = models.Variable{
-				Type:        varDef.Type,
-				Description: varDef.Description,
-				Default:     varDef.Default,
-				Sensitive:   varDef.Sensitive,
-				Value:       varDef.Value,
-			}
-		}
-		moduleVariables[module.ModuleName] = vars
-	}
+	moduleVariables := moduleCallVariables(modules)
+
+	// The root configuration renders the provider overrides for the configured environment
+	rootProvider := provider.ForEnvironment(config.Environment)
+
+	// providers.tf renders the default configuration followed by one aliased configuration per region
+	providerAliases := utils.ProviderAliases(config.Regions)
+	providerBlocks := append([]utils.ProviderAlias{{}}, providerAliases...)
 
 	data := map[string]interface{}{
-		"Provider":         provider,
-		"TerraformVersion": config.TerraformVersion,
+		"Provider":         &rootProvider,
+		"ProviderAliases":  providerAliases, // Lets module calls map an aliased provider per region
+		"ProviderBlocks":   providerBlocks,
+		"TerraformVersion": config.TerraformVersion.ForEnvironment(config.Environment),
 		"Modules":          modules,
 		"ModuleVariables":  moduleVariables, // Now using map[string]map[string]models.Variable
+		"RootModule":       true,
 		"OrganisationName": req.OrganisationName,
 		"ProductName":      req.ProductName,
 		"CustomerName":     customerName,
-		"Region":           config.Region,
+		"Region":           resourceRegion(req, config),
 		"Environment":      config.Environment,
 		"Backend":          config.Backend,
+		"Cloud":            config.Cloud,
 		"Variables":        genericVariables,
+		"Resources":        utils.FilterResourcesByProvider(config.Resources, req.Provider),
+		"LinkedProviders":  utils.FilterLinkedProviders(config.LinkedProviders, req.Provider),
+		"Removed":          config.Removed,
+		"Extra":            req.Extra,
+		"Environments":     environmentsFor(req),
+		"Repository":       config.Repository,
+		"Proxy":            config.Proxy,
+		"DefaultTags":      utils.MergeTags(config.DefaultTags, req.Tags, config.Environment),
+		"TfvarsComments":   !req.NoTfvarsComments,
+		"TfvarsOrder":      config.TfvarsOrder,
+		"GenerateLinters":  req.GenerateLinters, // Lets other scaffolding point at .tflint.hcl and .checkov.yaml
+		"GeneratorVersion": GeneratorVersion,
 	}
 
 	return data
 }
 
-// generateTerraformFiles creates Terraform files like providers.tf, main.tf, variables.tf, and vars.tfvars.
-func generateTerraformFiles(path string, data map[string]interface{}, provider, entityName string) error {
-	files := []struct {
-		Template string
-		Dest     string
-	}{
-		{Template: filepath.Join("templates", "generic", "providers.tf.tmpl"), Dest: filepath.Join(path, "providers.tf")},
-		{Template: filepath.Join("templates", provider, "main.tf.tmpl"), Dest: filepath.Join(path, "main.tf")},
-		{Template: filepath.Join("templates", "generic", "variables.tf.tmpl"), Dest: filepath.Join(path, "variables.tf")},
-		{Template: filepath.Join("templates", "generic", "vars.tfvars.tmpl"), Dest: filepath.Join(path, "vars.tfvars")},
+// resourceRegion returns the region resources deploy to: the request's region, or the configured one.
+func resourceRegion(req *models.GenerateRequest, config *models.Config) string {
+	if req.Region != "" {
+		return req.Region
 	}
+	return config.Region
+}
 
-	for _, file := range files {
-		if err := utils.GenerateFileFromTemplate(file.Template, file.Dest, data); err != nil {
-			return fmt.Errorf("error generating %s: %w", file.Dest, err)
+// moduleCallVariables returns the inputs of each module call keyed by block label.
+func moduleCallVariables(modules []models.Module) map[string]map[string]models.Variable {
+	moduleVariables := make(map[string]map[string]models.Variable)
+	for _, module := range modules {
+		vars := make(map[string]models.Variable)
+		for varName, varDef := range module.Variables {
+			// Extract the embedded Variable from ModuleVariable
+			vars[varName] = models.Variable{
+				Type:          varDef.Type,
+				Description:   varDef.Description,
+				Default:       varDef.Default,
+				Sensitive:     varDef.Sensitive,
+				Value:         varDef.Value,
+				LiteralDollar: varDef.LiteralDollar,
+				Expression:    varDef.Expression,
+				Heredoc:       varDef.Heredoc,
+			}
+		}
+		moduleVariables[module.BlockLabel()] = vars
+	}
+	return moduleVariables
+}
+
+// templateVariables returns the generic variables for the requested provider and the count variables of the rendered
+// modules and resources, with per-environment defaults resolved for env.
+func templateVariables(req *models.GenerateRequest, config *models.Config, env string) map[string]models.Variable {
+	variables := utils.FilterVariablesByProvider(config.Variables, req.Provider)
+	for name, variable := range countVariables(req, config) {
+		variables[name] = variable
+	}
+	return utils.ResolveEnvironmentVariables(variables, env)
+}
+
+// countVariables returns the variables holding the per-environment counts of the rendered modules and resources. The
+// modules were resolved without error before any file is generated.
+func countVariables(req *models.GenerateRequest, config *models.Config) map[string]models.Variable {
+	modules, _ := utils.ResolveModuleDependencies(req.Modules, config.Modules)
+	return utils.CountVariables(modules, utils.FilterResourcesByProvider(config.Resources, req.Provider))
+}
+
+// generateTerraformFiles creates Terraform files like providers.tf, main.tf, variables.tf, vars.tfvars when withVars is set,
+// resources.tf when the configuration declares resources for the provider and removed.tf when it declares removed
+// blocks. main.tf renders mainTemplate. providerFile, providers.tf unless set, receives the required_providers and
+// provider blocks; in main.tf or terraform.tf they are joined by the backend block, or the cloud block when one is
+// configured, so every terraform setting sits in one file. Otherwise the backend block opens main.tf and the cloud
+// block has terraform.tf to itself. With main.tf or terraform.tf, the settings file an earlier run wrote for another
+// providerFile is removed, see staleSettingsFiles.
+// Each template is parsed together with the provider's base.tf.tmpl and the product's partials, see templatePartials.
+func generateTerraformFiles(path string, data map[string]interface{}, templateDir, productName, mainTemplate, providerFile string, withVars bool, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	partials, err := templatePartials(templateDir, productName)
+	if err != nil {
+		return nil, err
+	}
+
+	providersTemplate := filepath.Join(templatesDir, "generic", "providers.tf.tmpl")
+	// The state is kept either by a backend or by HCP Terraform, never both
+	stateTemplate := filepath.Join(templatesDir, "generic", "backend.tf.tmpl")
+	cloudTemplate := ""
+	if cloud, _ := data["Cloud"].(*models.Cloud); cloud != nil {
+		stateTemplate = filepath.Join(templatesDir, "generic", "terraform.tf.tmpl")
+		cloudTemplate = stateTemplate
+	}
+
+	var files []templateFile
+	switch providerFile {
+	case models.ProviderFileMain:
+		files = append(files, templateFile{Template: providersTemplate, Dest: filepath.Join(path, "main.tf"), Partials: partials, Sections: []string{stateTemplate, mainTemplate}})
+		cloudTemplate = ""
+	case models.ProviderFileTerraform:
+		files = append(files,
+			templateFile{Template: providersTemplate, Dest: filepath.Join(path, "terraform.tf"), Partials: partials, Sections: []string{stateTemplate}},
+			templateFile{Template: mainTemplate, Dest: filepath.Join(path, "main.tf"), Partials: partials},
+		)
+		cloudTemplate = ""
+	default:
+		mainFile := templateFile{Template: mainTemplate, Dest: filepath.Join(path, "main.tf"), Partials: partials}
+		if cloudTemplate == "" {
+			mainFile = templateFile{Template: stateTemplate, Dest: mainFile.Dest, Partials: partials, Sections: []string{mainTemplate}}
+		}
+		files = append(files, templateFile{Template: providersTemplate, Dest: filepath.Join(path, "providers.tf"), Partials: partials}, mainFile)
+	}
+	files = append(files, templateFile{Template: filepath.Join(templatesDir, "generic", "variables.tf.tmpl"), Dest: filepath.Join(path, "variables.tf"), Partials: partials})
+	if withVars {
+		files = append(files, templateFile{Template: filepath.Join(templatesDir, "generic", "vars.tfvars.tmpl"), Dest: filepath.Join(path, "vars.tfvars"), Partials: partials})
+	}
+	if resources, _ := data["Resources"].([]models.Resource); len(resources) > 0 {
+		files = append(files, templateFile{Template: filepath.Join(templatesDir, "generic", "resources.tf.tmpl"), Dest: filepath.Join(path, "resources.tf"), Partials: partials})
+	}
+	if removed, _ := data["Removed"].([]models.RemovedBlock); len(removed) > 0 {
+		files = append(files, templateFile{Template: filepath.Join(templatesDir, "generic", "removed.tf.tmpl"), Dest: filepath.Join(path, "removed.tf"), Partials: partials})
+	}
+	if cloudTemplate != "" {
+		files = append(files, templateFile{Template: cloudTemplate, Dest: filepath.Join(path, "terraform.tf"), Partials: partials})
+	}
+
+	results, err := renderFiles(files, data, opts)
+	if err != nil {
+		return results, err
+	}
+	for _, name := range staleSettingsFiles(providerFile, ".tf") {
+		removed, err := removeStaleFile(filepath.Join(path, name), opts)
+		results = append(results, removed...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// staleSettingsFiles names the files, ending in extension, that hold the terraform settings for another providerFile
+// and would declare them twice. The default providers.tf leaves terraform.tf alone, which may be maintained by hand.
+func staleSettingsFiles(providerFile, extension string) []string {
+	switch providerFile {
+	case models.ProviderFileMain:
+		return []string{"providers" + extension, "terraform" + extension}
+	case models.ProviderFileTerraform:
+		return []string{"providers" + extension}
+	default:
+		return nil
+	}
+}
+
+// removeStaleFile deletes a file an earlier run generated but this run does not, so Terraform cannot load its blocks
+// twice, and reports it as removed. Protected files and dry runs leave it in place.
+func removeStaleFile(path string, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if utils.IsProtected(path, opts.Protected) {
+		log.Printf("warning: %s is no longer generated but is protected; remove it by hand", path)
+		return nil, nil
+	}
+	if !opts.DryRun {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("error removing stale %s: %w", path, err)
 		}
 	}
+	return []models.FileResult{{Path: path, Action: models.FileRemoved}}, nil
+}
+
+// providerTemplates returns the directory holding the request's provider templates: templates/<provider>, or
+// templates/<flavor>/<provider> when the request selects a flavor.
+func providerTemplates(req *models.GenerateRequest) string {
+	return filepath.Join(templatesDir, req.Flavor, req.Provider)
+}
+
+// checkFlavor fails a request whose flavor is not a single directory name or has no templates for its provider.
+func checkFlavor(req *models.GenerateRequest) error {
+	if req.Flavor == "" {
+		return nil
+	}
+	if !filepath.IsLocal(req.Flavor) || strings.ContainsAny(req.Flavor, `/\`) {
+		return fmt.Errorf("invalid flavor '%s': expected a directory name under %s", req.Flavor, templatesDir)
+	}
+	if info, err := os.Stat(providerTemplates(req)); err != nil || !info.IsDir() {
+		return fmt.Errorf("flavor '%s' has no templates for provider '%s' (expected %s)", req.Flavor, req.Provider, providerTemplates(req))
+	}
 	return nil
 }
 
-// generateBackendTfvarsFiles creates backend tfvars files for a product.
-func generateBackendTfvarsFiles(path string, data map[string]interface{}, productName string) error {
-	environments := []string{"nonprod", "prod"}
-	for _, env := range environments {
-		data["Environment"] = env
-		filename := productName + "_" + env + ".tfvars"
-		destPath := filepath.Join(path, "backend", filename)
-		if err := utils.GenerateFileFromTemplate(filepath.Join("templates", "generic", "backend.tfvars.tmpl"), destPath, data); err != nil {
-			return err
+// checkCustomerTemplates fails a request for json or cdktf output naming a customer configured with its own main.tf
+// template, since those formats are not rendered from templates and the customer's variant would be dropped.
+func checkCustomerTemplates(req *models.GenerateRequest, config *models.Config) error {
+	if req.OutputFormat != models.OutputFormatJSON && req.OutputFormat != models.OutputFormatCDKTF {
+		return nil
+	}
+	for _, customer := range req.Customers {
+		customer = strings.TrimSpace(customer)
+		if config.Customers[customer].MainTemplate() != "" {
+			return fmt.Errorf("%w: customer '%s' has its own main.tf template, which the %s output format does not use", ErrInvalidRequest, customer, req.OutputFormat)
 		}
 	}
 	return nil
 }
 
-// generateBackendAndVarsTfvarsFiles creates backend and vars tfvars files for a customer.
-func generateBackendAndVarsTfvarsFiles(path string, data map[string]interface{}, customerName string) error {
-	environments := []string{"nonprod", "prod"}
+// mainTemplate returns the template main.tf is rendered from: the one configured for the customer, or
+// main.tf.tmpl under templateDir
+func mainTemplate(config *models.Config, templateDir, customerName string) string {
+	if template := config.Customers[customerName].MainTemplate(); template != "" {
+		return filepath.Join(templatesDir, template)
+	}
+	return filepath.Join(templateDir, "main.tf.tmpl")
+}
+
+// templatePartials returns the templates parsed alongside each root template: base.tf.tmpl under templateDir, then
+// every products/<product>/*.tmpl under it. Later files override blocks defined by earlier ones, and a product
+// partial named like a root template (e.g. main.tf.tmpl) replaces it entirely.
+func templatePartials(templateDir, productName string) ([]string, error) {
+	var partials []string
+
+	base := filepath.Join(templateDir, "base.tf.tmpl")
+	if _, err := os.Stat(base); err == nil {
+		partials = append(partials, base)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// The product name is part of the path, and listing the directory keeps glob metacharacters in it literal
+	if !utils.IsSafePathSegment(productName) {
+		return nil, fmt.Errorf("%w: invalid product name %q", ErrInvalidRequest, productName)
+	}
+	productDir := filepath.Join(templateDir, "products", productName)
+	entries, err := os.ReadDir(productDir)
+	if os.IsNotExist(err) {
+		return partials, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".tmpl") {
+			partials = append(partials, filepath.Join(productDir, entry.Name()))
+		}
+	}
+	return partials, nil
+}
+
+// templateFile pairs a template with the file it renders to.
+type templateFile struct {
+	Template string
+	Dest     string
+	Partials []string // Templates parsed after Template that may define or override its blocks
+	Sections []string // Further templates rendered after Template into the same file, each with the same partials
+}
+
+// renderWorkers bounds how many files renderFiles renders at once.
+var renderWorkers = 4
+
+// renderFiles renders each template to its destination on a small worker pool, returning the results in the order
+// of files. Every render gets its own deep copy of data, so renders running at once share nothing. A failed file does
+// not stop the others: the results of every file that was written are returned with the errors of all that failed.
+func renderFiles(files []templateFile, data map[string]interface{}, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	results := make([]models.FileResult, len(files))
+	errs := make([]error, len(files))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(renderWorkers, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				file := files[i]
+				if len(file.Sections) == 0 {
+					results[i], errs[i] = utils.GenerateFileFromTemplateSet(append([]string{file.Template}, file.Partials...), file.Dest, utils.CloneTemplateData(data), opts)
+					continue
+				}
+				sections := make([][]string, 0, len(file.Sections)+1)
+				for _, section := range append([]string{file.Template}, file.Sections...) {
+					sections = append(sections, append([]string{section}, file.Partials...))
+				}
+				results[i], errs[i] = utils.GenerateFileFromTemplateSections(sections, file.Dest, utils.CloneTemplateData(data), opts)
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	written := make([]models.FileResult, 0, len(results))
+	var failures []error
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Errorf("error generating %s: %w", files[i].Dest, err))
+		}
+		if err == nil && results[i].Path != "" {
+			written = append(written, results[i])
+		}
+	}
+	return written, errors.Join(failures...)
+}
+
+// copyData returns a shallow copy of template data whose keys can be set without affecting data. The values are
+// shared, so they must not be changed in place; renderFiles deep-copies data before rendering it.
+func copyData(data map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		copied[key] = value
+	}
+	return copied
+}
+
+// environmentScriptFile runs the shared root configuration from an environment directory of the environments layout.
+const environmentScriptFile = "terraform.sh"
+
+// environmentTarget is one entity/environment combination and the files rendered for it.
+type environmentTarget struct {
+	Entity      string
+	Environment string
+	BackendPath string                 // Empty when the environment uses the local backend
+	VarsPath    string                 // Empty when no vars file is written for the target
+	ScriptPath  string                 // Script running the shared root for the environment; empty in the flat layout
+	Data        map[string]interface{} // Template data owned by this target alone
+}
+
+// environmentTargets builds a target per environment, in request order. Each target gets its own copy of the
+// template data so rendering one environment can never leak values into another.
+// With the environments layout both files are always written to envs/<env>/ next to the shared root configuration,
+// with a script running the root from there, and the vars file is written with the count variables alone when vars
+// are disabled.
+func environmentTargets(req *models.GenerateRequest, config *models.Config, path string, data map[string]interface{}, entityName string, withVars bool) ([]environmentTarget, error) {
+	skipVars := varsDisabled(req, config)
+	withVars = withVars && !skipVars
+	environments := environmentsFor(req)
+	targets := make([]environmentTarget, 0, len(environments))
 	for _, env := range environments {
-		data["Environment"] = env
-		files := []struct {
-			Template string
-			Dest     string
-		}{
-			{Template: filepath.Join("templates", "generic", "backend.tfvars.tmpl"), Dest: filepath.Join(path, "backend", customerName+"_"+env+".tfvars")},
-			{Template: filepath.Join("templates", "generic", "vars.tfvars.tmpl"), Dest: filepath.Join(path, "vars", customerName+"_"+env+".tfvars")},
-		}
-
-		for _, file := range files {
-			if err := utils.GenerateFileFromTemplate(file.Template, file.Dest, data); err != nil {
-				return err
+		filename, err := utils.TfvarsFilename(config.TfvarsFilename, entityName, env)
+		if err != nil {
+			return nil, err
+		}
+		backendType := config.Backend.ForEnvironment(env).Type
+		target := environmentTarget{
+			Entity:      entityName,
+			Environment: env,
+			BackendPath: filepath.Join(path, "backend", backendFilename(filename, config.BackendSuffix, backendType)),
+			Data:        environmentData(req, config, data, entityName, env),
+		}
+		if withVars {
+			target.VarsPath = filepath.Join(path, "vars", filename)
+		}
+		if config.Layout == models.LayoutEnvironments {
+			target.BackendPath = filepath.Join(path, "envs", env, backendFilename("backend.tfvars", config.BackendSuffix, backendType))
+			if !skipVars {
+				target.VarsPath = filepath.Join(path, "envs", env, "vars.tfvars")
+			} else if counts := countVariables(req, config); len(counts) > 0 {
+				// The shared main.tf cannot hold an environment's counts, so its vars file sets them even when the
+				// other variables come from elsewhere
+				target.VarsPath = filepath.Join(path, "envs", env, "vars.tfvars")
+				target.Data["Variables"] = utils.ResolveEnvironmentVariables(counts, env)
+			}
+			if req.OutputFormat != models.OutputFormatCDKTF {
+				target.ScriptPath = filepath.Join(path, "envs", env, environmentScriptFile)
+			}
+		}
+		if backendType == models.BackendLocal || config.Cloud != nil {
+			target.BackendPath = ""
+		}
+		if target.VarsPath != "" && req.OutputFormat == models.OutputFormatJSON {
+			target.VarsPath += ".json"
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// backendFilename swaps the .tfvars extension of a backend file name for suffix, naming the backend type for
+// type.tfbackend, e.g. web_prod.s3.tfbackend. Without a backend type the name ends in .tfbackend alone.
+func backendFilename(filename, suffix, backendType string) string {
+	name := strings.TrimSuffix(filename, ".tfvars")
+	switch {
+	case suffix == models.BackendSuffixTyped && backendType != "":
+		return name + "." + backendType + ".tfbackend"
+	case suffix == models.BackendSuffixTfbackend, suffix == models.BackendSuffixTyped:
+		return name + ".tfbackend"
+	default:
+		return filename
+	}
+}
+
+// planInput is the backend configuration and vars file terraform init and plan use for one environment.
+type planInput struct {
+	Environment   string
+	BackendConfig string // Relative to the working directory; empty for the local backend
+	VarFile       string // Relative to the working directory; empty when vars are not generated
+}
+
+// planInputs lists the plan inputs of every environment generated for the entity in path. Products without
+// per-environment vars plan against the shared root vars file.
+func planInputs(req *models.GenerateRequest, config *models.Config, path, entityName string, withVars bool) ([]planInput, error) {
+	targets, err := environmentTargets(req, config, path, nil, entityName, withVars)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make([]planInput, 0, len(targets))
+	for _, target := range targets {
+		varsPath := target.VarsPath
+		if varsPath == "" && !varsDisabled(req, config) {
+			varsPath = filepath.Join(path, "vars.tfvars")
+			if req.OutputFormat == models.OutputFormatJSON {
+				varsPath += ".json"
+			}
+		}
+
+		input := planInput{Environment: target.Environment}
+		if target.BackendPath != "" {
+			if input.BackendConfig, err = filepath.Rel(path, target.BackendPath); err != nil {
+				return nil, err
+			}
+		}
+		if varsPath != "" {
+			if input.VarFile, err = filepath.Rel(path, varsPath); err != nil {
+				return nil, err
+			}
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+// entityInputs lists the plan inputs of the product or customer whose template data is data. Customers get
+// per-environment vars files; products share the root vars file.
+func entityInputs(req *models.GenerateRequest, config *models.Config, path string, data map[string]interface{}) ([]planInput, error) {
+	if customer, _ := data["CustomerName"].(string); customer != "" {
+		return planInputs(req, config, path, customer, true)
+	}
+	return planInputs(req, config, path, req.ProductName, false)
+}
+
+// makeExecutable marks the scripts generation created or overwrote as executable. Nothing is changed on a dry run.
+func makeExecutable(results []models.FileResult, opts utils.GenerateOptions) error {
+	if opts.DryRun {
+		return nil
+	}
+	for _, result := range results {
+		if result.Action == models.FileCreated || result.Action == models.FileOverwritten {
+			if err := os.Chmod(result.Path, 0755); err != nil {
+				return fmt.Errorf("error making %s executable: %w", result.Path, err)
 			}
 		}
 	}
 	return nil
 }
+
+// environmentData copies data and sets the values that differ per environment, including the default state key of
+// entityName in it.
+func environmentData(req *models.GenerateRequest, config *models.Config, data map[string]interface{}, entityName, env string) map[string]interface{} {
+	envData := copyData(data)
+	envData["Environment"] = env
+	envData["TerraformVersion"] = config.TerraformVersion.ForEnvironment(env)
+	envData["Variables"] = templateVariables(req, config, env)
+	envData["DefaultTags"] = utils.MergeTags(config.DefaultTags, req.Tags, env)
+	envData["Backend"] = utils.DefaultBackendKey(config.Backend.ForEnvironment(env), req.OrganisationName, req.ProductName, entityName, env)
+	return envData
+}
+
+// generateEnvironmentFiles creates the backend tfvars, and the vars tfvars when withVars is set, for every environment.
+func generateEnvironmentFiles(req *models.GenerateRequest, config *models.Config, path string, data map[string]interface{}, entityName string, withVars bool, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	targets, err := environmentTargets(req, config, path, data, entityName, withVars)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []models.FileResult
+	for _, target := range targets {
+		var result models.FileResult
+		if target.BackendPath != "" {
+			result, err = utils.GenerateFileFromTemplate(filepath.Join(templatesDir, "generic", "backend.tfvars.tmpl"), target.BackendPath, target.Data, opts)
+			if err != nil {
+				return results, fmt.Errorf("error generating %s: %w", target.BackendPath, err)
+			}
+			results = append(results, result)
+		}
+
+		if target.VarsPath != "" {
+			if req.OutputFormat == models.OutputFormatJSON {
+				result, err = utils.WriteJSONFile(target.VarsPath, utils.TfvarsJSON(target.Data["Variables"].(map[string]models.Variable)), opts)
+			} else {
+				result, err = utils.GenerateFileFromTemplate(filepath.Join(templatesDir, "generic", "vars.tfvars.tmpl"), target.VarsPath, target.Data, opts)
+			}
+			if err != nil {
+				return results, fmt.Errorf("error generating %s: %w", target.VarsPath, err)
+			}
+			results = append(results, result)
+		}
+
+		if target.ScriptPath != "" {
+			scriptResults, err := generateEnvironmentScript(config, target, opts)
+			results = append(results, scriptResults...)
+			if err != nil {
+				return results, err
+			}
+		}
+	}
+	return results, nil
+}
+
+// generateEnvironmentScript writes the executable script that runs the shared root configuration with the backend
+// and vars files of the target's environment directory. With the local backend the environment's state is kept in
+// its directory too, since the shared root would otherwise hold a single state for every environment.
+func generateEnvironmentScript(config *models.Config, target environmentTarget, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	scriptData := copyData(target.Data)
+	scriptData["EnvironmentBackendFile"] = ""
+	if target.BackendPath != "" {
+		scriptData["EnvironmentBackendFile"] = filepath.Base(target.BackendPath)
+	}
+	scriptData["EnvironmentLocalState"] = config.Cloud == nil && config.Backend.Type == models.BackendLocal && target.BackendPath == ""
+	scriptData["EnvironmentVarFile"] = ""
+	if target.VarsPath != "" {
+		scriptData["EnvironmentVarFile"] = filepath.Base(target.VarsPath)
+	}
+
+	files := []templateFile{
+		{Template: filepath.Join(templatesDir, "generic", "environment-terraform.sh.tmpl"), Dest: target.ScriptPath},
+	}
+	results, err := renderFiles(files, scriptData, opts)
+	if err != nil {
+		return results, err
+	}
+	return results, makeExecutable(results, opts)
+}
+
+// environmentDirectories returns the per-environment directories the flat layout writes into.
+func environmentDirectories(config *models.Config, path string, withVars bool) []string {
+	if config.Layout == models.LayoutEnvironments {
+		return nil
+	}
+	var dirs []string
+	// State lives in HCP Terraform with a cloud block, so there are no backend tfvars
+	if config.Cloud == nil {
+		dirs = append(dirs, filepath.Join(path, "backend"))
+	}
+	if withVars {
+		dirs = append(dirs, filepath.Join(path, "vars"))
+	}
+	return dirs
+}
@@ -0,0 +1,882 @@
+// backend/services/terraform_service_test.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testConfig returns a configuration with a per-environment variable and a per-environment Terraform version.
+func testConfig() *models.Config {
+	return &models.Config{
+		TerraformVersion: models.TerraformVersion{Default: ">= 1.5.0", Environments: map[string]string{"prod": "= 1.7.5"}},
+		Backend: models.Backend{
+			Type:               "azurerm",
+			ResourceGroupName:  "rg-state",
+			StorageAccountName: "stacc",
+			ContainerName:      "tfstate",
+			Key:                "app.tfstate",
+		},
+		Variables: map[string]models.Variable{
+			"sku": {
+				Type:           "string",
+				Description:    "SKU",
+				Default:        map[string]interface{}{"default": "basic", "prod": "premium"},
+				PerEnvironment: true,
+			},
+		},
+		Environment: "dev",
+	}
+}
+
+// chdirBackendRoot runs the test from the backend directory so the templates resolve.
+func chdirBackendRoot(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestEnvironmentTargets(t *testing.T) {
+	config := testConfig()
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Environments: []string{"dev", "test", "prod"}}
+	data := prepareTemplateData(req, config, &models.Provider{Name: "azurerm"}, "c1", nil)
+
+	targets, err := environmentTargets(req, config, "out", data, "c1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 3 {
+		t.Fatalf("got %d targets, want 3", len(targets))
+	}
+
+	wantSKU := map[string]string{"dev": "basic", "test": "basic", "prod": "premium"}
+	for i, env := range req.Environments {
+		target := targets[i]
+		if target.Environment != env || target.Entity != "c1" {
+			t.Errorf("target %d = %s/%s, want c1/%s", i, target.Entity, target.Environment, env)
+		}
+		if want := filepath.Join("out", "backend", "c1_"+env+".tfvars"); target.BackendPath != want {
+			t.Errorf("%s backend path = %s, want %s", env, target.BackendPath, want)
+		}
+		if want := filepath.Join("out", "vars", "c1_"+env+".tfvars"); target.VarsPath != want {
+			t.Errorf("%s vars path = %s, want %s", env, target.VarsPath, want)
+		}
+		if got := target.Data["Environment"]; got != env {
+			t.Errorf("%s data Environment = %v", env, got)
+		}
+		variables := target.Data["Variables"].(map[string]models.Variable)
+		if got := variables["sku"].Value; got != wantSKU[env] {
+			t.Errorf("%s sku = %v, want %s", env, got, wantSKU[env])
+		}
+	}
+
+	if got := targets[2].Data["TerraformVersion"]; got != "= 1.7.5" {
+		t.Errorf("prod TerraformVersion = %v, want = 1.7.5", got)
+	}
+	if got := data["Environment"]; got != "dev" {
+		t.Errorf("base data Environment was changed to %v", got)
+	}
+}
+
+func TestEnvironmentTargetsBackendSuffix(t *testing.T) {
+	config := testConfig()
+	config.Backend.Environments = map[string]models.Backend{"prod": {Type: "azurerm", ResourceGroupName: "rg-state", StorageAccountName: "stprod", ContainerName: "tfstate"}}
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Environments: []string{"dev", "prod"}}
+
+	for suffix, want := range map[string][]string{
+		models.BackendSuffixTfvars:    {"c1_dev.tfvars", "c1_prod.tfvars"},
+		models.BackendSuffixTfbackend: {"c1_dev.tfbackend", "c1_prod.tfbackend"},
+		models.BackendSuffixTyped:     {"c1_dev.azurerm.tfbackend", "c1_prod.azurerm.tfbackend"},
+	} {
+		config.BackendSuffix = suffix
+		targets, err := environmentTargets(req, config, "out", nil, "c1", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, target := range targets {
+			if target.BackendPath != filepath.Join("out", "backend", want[i]) {
+				t.Errorf("%s: %s backend path = %s, want backend/%s", suffix, target.Environment, target.BackendPath, want[i])
+			}
+			if target.VarsPath != filepath.Join("out", "vars", "c1_"+target.Environment+".tfvars") {
+				t.Errorf("%s: %s vars path = %s, want it unchanged", suffix, target.Environment, target.VarsPath)
+			}
+		}
+	}
+}
+
+func TestEnvironmentTargetsCountsWithoutVars(t *testing.T) {
+	config := testConfig()
+	config.Layout = models.LayoutEnvironments
+	config.SkipVars = true
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Environments: []string{"dev", "prod"}}
+
+	// Without counts, disabled vars leave every environment without a vars file
+	targets, err := environmentTargets(req, config, "out", nil, "web", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range targets {
+		if target.VarsPath != "" {
+			t.Errorf("%s vars path = %s, want none", target.Environment, target.VarsPath)
+		}
+	}
+
+	// Each environment still gets its own count, and nothing else
+	config.Resources = []models.Resource{{Type: "azurerm_public_ip", Name: "egress", Count: map[string]int{"prod": 3, "default": 1}}}
+	if targets, err = environmentTargets(req, config, "out", nil, "web", false); err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range targets {
+		if want := filepath.Join("out", "envs", target.Environment, "vars.tfvars"); target.VarsPath != want {
+			t.Errorf("%s vars path = %s, want %s", target.Environment, target.VarsPath, want)
+		}
+		variables := target.Data["Variables"].(map[string]models.Variable)
+		want := map[string]float64{"dev": 1, "prod": 3}[target.Environment]
+		if len(variables) != 1 || variables["azurerm_public_ip_egress_count"].Value != want {
+			t.Errorf("%s variables = %+v, want only azurerm_public_ip_egress_count = %v", target.Environment, variables, want)
+		}
+	}
+}
+
+func TestEnvironmentTargetsDefaultBackendKey(t *testing.T) {
+	config := testConfig()
+	config.Backend = models.Backend{Type: "s3", Bucket: "acme-state", Region: "eu-west-1"}
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "aws", Environments: []string{"dev", "prod"}}
+
+	keys := make(map[string]string)
+	for _, entity := range []string{"c1", "c2", "web"} {
+		targets, err := environmentTargets(req, config, "out", nil, entity, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, target := range targets {
+			key := target.Data["Backend"].(models.Backend).Key
+			if other, ok := keys[key]; ok {
+				t.Errorf("%s/%s shares state key %s with %s", entity, target.Environment, key, other)
+			}
+			keys[key] = entity + "/" + target.Environment
+		}
+	}
+
+	for key, want := range map[string]string{
+		"acme/web/c1/dev/terraform.tfstate":  "c1/dev",
+		"acme/web/c2/prod/terraform.tfstate": "c2/prod",
+		"acme/web/prod/terraform.tfstate":    "web/prod",
+	} {
+		if keys[key] != want {
+			t.Errorf("key %s belongs to %q, want %s", key, keys[key], want)
+		}
+	}
+
+	// A configured key is kept as it is
+	config.Backend.Key = "shared/terraform.tfstate"
+	targets, err := environmentTargets(req, config, "out", nil, "c1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := targets[0].Data["Backend"].(models.Backend).Key; got != config.Backend.Key {
+		t.Errorf("configured key = %s, want %s", got, config.Backend.Key)
+	}
+}
+
+func TestGenerateEnvironmentFiles(t *testing.T) {
+	chdirBackendRoot(t)
+
+	config := testConfig()
+	customers := []string{"c1", "c2"}
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Customers: customers, Environments: []string{"dev", "prod"}}
+	out := t.TempDir()
+
+	var results []models.FileResult
+	for _, customer := range customers {
+		data := prepareTemplateData(req, config, &models.Provider{Name: "azurerm"}, customer, nil)
+		customerResults, err := generateEnvironmentFiles(req, config, filepath.Join(out, customer), data, customer, true, fileOptions(req, config))
+		if err != nil {
+			t.Fatalf("generateEnvironmentFiles(%s) error: %v", customer, err)
+		}
+		results = append(results, customerResults...)
+	}
+
+	if want := len(customers) * 2 * len(req.Environments); len(results) != want {
+		t.Fatalf("got %d files, want %d", len(results), want)
+	}
+
+	// Results come back customer by customer, environment by environment, backend before vars
+	i := 0
+	wantSKU := map[string]string{"dev": "basic", "prod": "premium"}
+	for _, customer := range customers {
+		for _, env := range req.Environments {
+			backendPath := filepath.Join(out, customer, "backend", customer+"_"+env+".tfvars")
+			varsPath := filepath.Join(out, customer, "vars", customer+"_"+env+".tfvars")
+			if results[i].Path != backendPath || results[i+1].Path != varsPath {
+				t.Fatalf("results %d-%d = %s, %s; want %s, %s", i, i+1, results[i].Path, results[i+1].Path, backendPath, varsPath)
+			}
+			i += 2
+
+			vars, err := os.ReadFile(varsPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := `sku = "` + wantSKU[env] + `"`; !strings.Contains(string(vars), want) {
+				t.Errorf("%s does not contain %q:\n%s", varsPath, want, vars)
+			}
+			if _, err := os.Stat(backendPath); err != nil {
+				t.Errorf("backend file missing: %v", err)
+			}
+		}
+	}
+}
+
+func TestGenerateEnvironmentFilesSharedRoot(t *testing.T) {
+	chdirBackendRoot(t)
+
+	for _, backendType := range []string{"azurerm", models.BackendLocal} {
+		t.Run(backendType, func(t *testing.T) {
+			config := testConfig()
+			config.Layout = models.LayoutEnvironments
+			config.Backend.Type = backendType
+			req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Customers: []string{"c1"}, Environments: []string{"dev", "prod"}}
+			data := prepareTemplateData(req, config, &models.Provider{Name: "azurerm"}, "c1", nil)
+			out := t.TempDir()
+
+			results, err := generateEnvironmentFiles(req, config, out, data, "c1", true, fileOptions(req, config))
+			if err != nil {
+				t.Fatalf("generateEnvironmentFiles() error: %v", err)
+			}
+
+			var want []string
+			for _, env := range req.Environments {
+				if backendType != models.BackendLocal {
+					want = append(want, filepath.Join(out, "envs", env, "backend.tfvars"))
+				}
+				want = append(want, filepath.Join(out, "envs", env, "vars.tfvars"), filepath.Join(out, "envs", env, environmentScriptFile))
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Path)
+			}
+			if !slices.Equal(got, want) {
+				t.Fatalf("generateEnvironmentFiles() wrote %v, want %v", got, want)
+			}
+
+			// Each environment runs the shared root two directories up with its own files and .terraform directory
+			script := filepath.Join(out, "envs", "prod", environmentScriptFile)
+			info, err := os.Stat(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0755 {
+				t.Errorf("%s mode = %v, want executable", script, info.Mode().Perm())
+			}
+			content, err := os.ReadFile(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			init := `exec terraform init -backend-config="$here/"'backend.tfvars' "$@"`
+			if backendType == models.BackendLocal {
+				init = `exec terraform init -backend-config="path=$here/terraform.tfstate" "$@"`
+			}
+			for _, line := range []string{
+				"# Generated for acme/c1 prod",
+				`export TF_DATA_DIR="$here/.terraform"`,
+				`cd "$here/../.."`,
+				init,
+				`exec terraform "$command" -var-file="$here/"'vars.tfvars' "$@"`,
+			} {
+				if !strings.Contains(string(content), line) {
+					t.Errorf("%s does not contain %q:\n%s", script, line, content)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderFilesReportsEveryWrittenFile(t *testing.T) {
+	dir := t.TempDir()
+	var files []templateFile
+	for i, body := range []string{"ok {{ .Name }}\n", "{{ .Missing }}", "ok\n", "{{ fail }", "ok\n"} {
+		template := filepath.Join(dir, fmt.Sprintf("file%d.tmpl", i))
+		if err := os.WriteFile(template, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, templateFile{Template: template, Dest: filepath.Join(dir, "out", fmt.Sprintf("file%d.tf", i))})
+	}
+
+	data := map[string]interface{}{"Name": "web"}
+	results, err := renderFiles(files, data, utils.GenerateOptions{Overwrite: true, Strict: true})
+	if err == nil || !strings.Contains(err.Error(), "file1.tf") || !strings.Contains(err.Error(), "file3.tf") {
+		t.Fatalf("renderFiles() error = %v, want both failed files", err)
+	}
+	var written []string
+	for _, result := range results {
+		written = append(written, filepath.Base(result.Path))
+	}
+	if strings.Join(written, ",") != "file0.tf,file2.tf,file4.tf" {
+		t.Errorf("renderFiles() results = %v, want every written file in order", written)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "file4.tf")); err != nil {
+		t.Errorf("file after the failures was not written: %v", err)
+	}
+}
+
+// BenchmarkRenderFiles renders a set of large templates one at a time and on the worker pool.
+func BenchmarkRenderFiles(b *testing.B) {
+	dir := b.TempDir()
+	// Each template renders every variable many times, like a large product main.tf
+	body := "{{ range $i, $_ := .Rows }}{{ range $name, $var := $.Variables }}{{ $name }}_{{ $i }} = {{ formatVariableValue $var }}\n{{ end }}{{ end }}"
+	var files []templateFile
+	for i := 0; i < 8; i++ {
+		template := filepath.Join(dir, fmt.Sprintf("large%d.tf.tmpl", i))
+		if err := os.WriteFile(template, []byte(body), 0o644); err != nil {
+			b.Fatal(err)
+		}
+		files = append(files, templateFile{Template: template, Dest: filepath.Join(dir, "out", fmt.Sprintf("large%d.tf", i))})
+	}
+
+	variables := make(map[string]models.Variable)
+	for i := 0; i < 50; i++ {
+		variables[fmt.Sprintf("var_%d", i)] = models.Variable{Type: "map(string)", Value: map[string]interface{}{"env": "prod", "team": "platform"}}
+	}
+	data := map[string]interface{}{"Rows": make([]struct{}, 50), "Variables": variables}
+	opts := utils.GenerateOptions{Overwrite: true}
+
+	for _, workers := range []int{1, renderWorkers} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			defer func(previous int) { renderWorkers = previous }(renderWorkers)
+			renderWorkers = workers
+			for i := 0; i < b.N; i++ {
+				if _, err := renderFiles(files, data, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCustomerLimit(t *testing.T) {
+	chdirGeneratorRoot(t, strings.Replace(replayTestConfig, `"modules": []`, `"modules": [], "max_customers": 2`, 1))
+
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Modules: []string{}, Customers: []string{"c1", "c2", "c3"}}
+	results, err := GenerateTerraform(req)
+	if !errors.Is(err, ErrInvalidRequest) || !strings.Contains(err.Error(), "at most 2") {
+		t.Fatalf("GenerateTerraform() error = %v, want %v with the limit", err, ErrInvalidRequest)
+	}
+	if _, statErr := os.Stat("output"); len(results) != 0 || !os.IsNotExist(statErr) {
+		t.Fatalf("GenerateTerraform() over the limit wrote %v", results)
+	}
+
+	// Two customers for each of two organisations are four customer directories
+	req = &models.GenerateRequest{OrganisationName: "acme", Organisations: []string{"globex"}, ProductName: "web", Provider: "azure", Modules: []string{}, Customers: []string{"c1", "c2"}}
+	if _, err := GenerateOrganisations(req); !errors.Is(err, ErrInvalidRequest) || !strings.Contains(err.Error(), "4 customers") {
+		t.Fatalf("GenerateOrganisations() error = %v, want %v for 4 customers", err, ErrInvalidRequest)
+	}
+	if _, err := os.Stat("output"); !os.IsNotExist(err) {
+		t.Fatal("GenerateOrganisations() over the limit wrote files")
+	}
+
+	req.Organisations = nil
+	if _, err := GenerateTerraform(req); err != nil {
+		t.Fatalf("GenerateTerraform() at the limit error: %v", err)
+	}
+}
+
+func TestRequireValues(t *testing.T) {
+	chdirGeneratorRoot(t, strings.Replace(replayTestConfig, `"variables": {`, `"variables": {"owner": {"type": "string"}, `, 1))
+
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Modules: []string{}, RequireValues: true}
+	results, err := GenerateTerraform(req)
+	if !errors.Is(err, ErrInvalidRequest) || !strings.Contains(err.Error(), "owner") {
+		t.Fatalf("GenerateTerraform() error = %v, want %v naming owner", err, ErrInvalidRequest)
+	}
+	if _, statErr := os.Stat("output"); len(results) != 0 || !os.IsNotExist(statErr) {
+		t.Fatalf("GenerateTerraform() with a missing value wrote %v", results)
+	}
+
+	req.VariableDefaults = map[string]string{"owner": "platform"}
+	if _, err := GenerateTerraform(req); err != nil {
+		t.Fatalf("GenerateTerraform() with the value supplied error: %v", err)
+	}
+}
+
+func TestRequestNames(t *testing.T) {
+	chdirGeneratorRoot(t, replayTestConfig)
+
+	for _, req := range []models.GenerateRequest{
+		{OrganisationName: "acme/retail", ProductName: "web"},
+		{OrganisationName: "acme", ProductName: ".."},
+		{OrganisationName: "acme", ProductName: "web", Customers: []string{"c1", "c1/../c2"}},
+	} {
+		req.Provider, req.Modules = "azure", []string{}
+		if _, err := GenerateTerraform(&req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("GenerateTerraform(%s/%s, %v) error = %v, want %v", req.OrganisationName, req.ProductName, req.Customers, err, ErrInvalidRequest)
+		}
+	}
+
+	// Every organisation fails the same way, so the request fails as a whole while still listing each organisation
+	req := &models.GenerateRequest{OrganisationName: "acme", Organisations: []string{"globex"}, ProductName: "web\\app", Provider: "azure", Modules: []string{}}
+	results, err := GenerateOrganisations(req)
+	if !errors.Is(err, ErrInvalidRequest) || len(results) != 2 || results[0].Error == "" || results[1].Error == "" {
+		t.Fatalf("GenerateOrganisations() = %+v, %v; want both organisations failed with %v", results, err, ErrInvalidRequest)
+	}
+	if _, err := os.Stat("output"); !os.IsNotExist(err) {
+		t.Fatal("GenerateOrganisations() with an invalid product wrote files")
+	}
+}
+
+func TestCheckCustomerTemplates(t *testing.T) {
+	config := &models.Config{Customers: map[string]models.CustomerSettings{
+		"gov":   {Template: "azure/main.govcloud.tf.tmpl"},
+		"china": {TemplateDir: "azure-china"},
+	}}
+
+	tests := []struct {
+		format    string
+		customers []string
+		wantErr   bool
+	}{
+		{format: "", customers: []string{"gov", "china"}},
+		{format: models.OutputFormatHCL, customers: []string{"gov"}},
+		{format: models.OutputFormatJSON, customers: []string{"c1"}},
+		{format: models.OutputFormatJSON, customers: []string{"c1", " gov "}, wantErr: true},
+		{format: models.OutputFormatCDKTF, customers: []string{"china"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := checkCustomerTemplates(&models.GenerateRequest{OutputFormat: tt.format, Customers: tt.customers}, config)
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidRequest)) {
+			t.Errorf("checkCustomerTemplates(%q, %v) error = %v, want %v %t", tt.format, tt.customers, err, ErrInvalidRequest, tt.wantErr)
+		}
+	}
+}
+
+func TestMainBackendBlockIsPartial(t *testing.T) {
+	chdirGeneratorRoot(t, strings.Replace(replayTestConfig, `"backend": {"type": "local"}`,
+		`"backend": {"type": "azurerm", "resource_group_name": "rg-state", "storage_account_name": "stacc", "container_name": "tfstate", "key": "web.tfstate", "access_key": "backend-secret"}`, 1))
+
+	for format, want := range map[string]string{
+		models.OutputFormatHCL:  "backend \"azurerm\" {}\n",
+		models.OutputFormatJSON: "\"azurerm\": {}",
+	} {
+		req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web-" + format, Provider: "azure", Modules: []string{}, OutputFormat: format}
+		results, err := GenerateTerraform(req)
+		if err != nil {
+			t.Fatalf("%s: GenerateTerraform() error: %v", format, err)
+		}
+		checked := 0
+		for _, result := range results {
+			if base := filepath.Base(result.Path); base != "main.tf" && base != "main.tf.json" {
+				continue
+			}
+			checked++
+			content, err := os.ReadFile(result.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(content), want) || strings.Contains(string(content), "backend-secret") {
+				t.Errorf("%s: %s has no empty backend block:\n%s", format, result.Path, content)
+			}
+		}
+		if checked != 1 {
+			t.Errorf("%s: checked %d main files, want 1", format, checked)
+		}
+	}
+}
+
+func TestCloudBlockQuotesSettings(t *testing.T) {
+	chdirBackendRoot(t)
+
+	tricky := "acme\" \\ ${var.org} %{if true}x%{endif}\n"
+	tests := map[string]*models.Cloud{
+		"named workspace": {Hostname: "tfe.example.com", Organization: tricky, Workspaces: models.CloudWorkspaces{Name: tricky, Project: tricky}},
+		"tagged":          {Organization: "acme", Workspaces: models.CloudWorkspaces{Tags: []string{"app", tricky}}},
+	}
+	for name, cloud := range tests {
+		t.Run(name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "terraform.tf")
+			if _, err := utils.GenerateFileFromTemplate(filepath.Join(templatesDir, "generic", "terraform.tf.tmpl"), dest, map[string]interface{}{"Cloud": cloud}, utils.GenerateOptions{Overwrite: true}); err != nil {
+				t.Fatal(err)
+			}
+			content, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, diags := hclsyntax.ParseConfig(content, dest, hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("terraform.tf is not valid HCL: %s\n%s", diags.Error(), content)
+			}
+
+			// Every setting must read back as the configured text
+			cloudBlock := file.Body.(*hclsyntax.Body).Blocks[0].Body.Blocks[0].Body
+			workspaces := cloudBlock.Blocks[0].Body
+			want := map[string]cty.Value{"organization": cty.StringVal(cloud.Organization)}
+			if cloud.Hostname != "" {
+				want["hostname"] = cty.StringVal(cloud.Hostname)
+			}
+			got := map[string]cty.Value{}
+			for _, body := range []*hclsyntax.Body{cloudBlock, workspaces} {
+				for key, attribute := range body.Attributes {
+					value, diags := attribute.Expr.Value(nil)
+					if diags.HasErrors() {
+						t.Fatalf("%s: %s", key, diags.Error())
+					}
+					got[key] = value
+				}
+			}
+			if cloud.Workspaces.Name != "" {
+				want["name"] = cty.StringVal(cloud.Workspaces.Name)
+				want["project"] = cty.StringVal(cloud.Workspaces.Project)
+			} else {
+				want["tags"] = cty.TupleVal([]cty.Value{cty.StringVal("app"), cty.StringVal(tricky)})
+			}
+			if len(got) != len(want) {
+				t.Fatalf("settings = %v, want %v", got, want)
+			}
+			for key, value := range want {
+				if !got[key].RawEquals(value) {
+					t.Errorf("%s = %#v, want %#v", key, got[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplatePartials(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"base.tf.tmpl", filepath.Join("products", "web", "main.tf.tmpl"), filepath.Join("products", "web", "notes.txt"), filepath.Join("products", "w*", "x.tmpl")} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := templatePartials(dir, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "base.tf.tmpl"), filepath.Join(dir, "products", "web", "main.tf.tmpl")}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("templatePartials(web) = %v, want %v", got, want)
+	}
+
+	// Glob metacharacters are taken literally and match no other product
+	if got, err := templatePartials(dir, "w?b"); err != nil || len(got) != 1 {
+		t.Errorf("templatePartials(w?b) = %v, %v, want only the base", got, err)
+	}
+	if got, err := templatePartials(dir, "w*"); err != nil || len(got) != 2 || filepath.Base(got[1]) != "x.tmpl" {
+		t.Errorf("templatePartials(w*) = %v, %v, want the base and its own partial", got, err)
+	}
+	for _, product := range []string{"..", "../products/web", ""} {
+		if _, err := templatePartials(dir, product); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("templatePartials(%q) error = %v, want %v", product, err, ErrInvalidRequest)
+		}
+	}
+}
+
+func TestProviderFilePlacement(t *testing.T) {
+	chdirGeneratorRoot(t, replayTestConfig)
+
+	// The terraform settings and the backend block share one file, and switching files removes the old one
+	tests := []struct {
+		providerFile string
+		format       string
+		providers    string // File holding required_providers
+		backend      string // File holding the backend block
+		absent       []string
+	}{
+		{"", models.OutputFormatHCL, "providers.tf", "main.tf", nil},
+		{models.ProviderFileTerraform, models.OutputFormatHCL, "terraform.tf", "terraform.tf", []string{"providers.tf"}},
+		{models.ProviderFileMain, models.OutputFormatHCL, "main.tf", "main.tf", []string{"providers.tf", "terraform.tf"}},
+		{"", models.OutputFormatJSON, "providers.tf.json", "main.tf.json", nil},
+		{models.ProviderFileTerraform, models.OutputFormatJSON, "terraform.tf.json", "terraform.tf.json", []string{"providers.tf.json"}},
+		{models.ProviderFileMain, models.OutputFormatJSON, "main.tf.json", "main.tf.json", []string{"providers.tf.json", "terraform.tf.json"}},
+	}
+	for _, tt := range tests {
+		config := strings.Replace(replayTestConfig, `"modules": []`, `"modules": [], "provider_file": "`+tt.providerFile+`"`, 1)
+		if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ReloadConfig(); err != nil {
+			t.Fatal(err)
+		}
+
+		req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Modules: []string{}, OutputFormat: tt.format}
+		results, err := GenerateTerraform(req)
+		if err != nil {
+			t.Fatalf("%s/%s: GenerateTerraform() error: %v", tt.format, tt.providerFile, err)
+		}
+
+		var dir string
+		for _, result := range results {
+			if filepath.Base(result.Path) == "variables.tf" || filepath.Base(result.Path) == "variables.tf.json" {
+				dir = filepath.Dir(result.Path)
+			}
+		}
+		for _, check := range [][2]string{{tt.providers, "required_providers"}, {tt.backend, "backend"}} {
+			name, want := check[0], check[1]
+			content, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("%s/%s: %v", tt.format, tt.providerFile, err)
+			}
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s/%s: %s has no %s:\n%s", tt.format, tt.providerFile, name, want, content)
+			}
+		}
+		for _, name := range tt.absent {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				t.Errorf("%s/%s: stale %s was left behind", tt.format, tt.providerFile, name)
+			}
+		}
+	}
+}
+
+func TestVariablesKeepFalsyDefaults(t *testing.T) {
+	chdirGeneratorRoot(t, strings.Replace(replayTestConfig, `"variables": {"location": {"type": "string", "default": "eastus"}}`, `"variables": {
+		"location": {"type": "string", "default": "eastus"},
+		"prefix": {"type": "string", "default": ""},
+		"public": {"type": "bool", "default": false},
+		"replicas": {"type": "number", "default": 0},
+		"owner": {"type": "string"}
+	}`, 1))
+
+	// "", false and 0 are defaults like any other, so only owner stays required
+	want := map[string]string{"location": `"eastus"`, "prefix": `""`, "public": "false", "replicas": "0", "owner": ""}
+	for _, format := range []string{models.OutputFormatHCL, models.OutputFormatCDKTF} {
+		req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web-" + format, Provider: "azure", Modules: []string{}, OutputFormat: format}
+		results, err := GenerateTerraform(req)
+		if err != nil {
+			t.Fatalf("GenerateTerraform(%s) error: %v", format, err)
+		}
+		name := map[string]string{models.OutputFormatHCL: "variables.tf", models.OutputFormatCDKTF: "main.ts"}[format]
+		var content []byte
+		for _, result := range results {
+			if filepath.Base(result.Path) == name {
+				if content, err = os.ReadFile(result.Path); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if content == nil {
+			t.Fatalf("%s output has no %s", format, name)
+		}
+		for variable, value := range want {
+			// The declaration runs from the variable's name to the end of its block
+			start := strings.Index(string(content), `"`+variable+`"`)
+			if start < 0 {
+				t.Fatalf("%s does not declare %s:\n%s", name, variable, content)
+			}
+			declaration := string(content[start:])
+			declaration = declaration[:strings.Index(declaration, "}")]
+			hasDefault := strings.Contains(declaration, "default")
+			if value == "" && hasDefault {
+				t.Errorf("%s gives %s a default:\n%s", name, variable, declaration)
+			}
+			if value != "" && !strings.Contains(declaration, "default = "+value) && !strings.Contains(declaration, "default: "+value+",") {
+				t.Errorf("%s declares %s without default %s:\n%s", name, variable, value, declaration)
+			}
+		}
+	}
+}
+
+func TestValidateRequestRegion(t *testing.T) {
+	config := &models.Config{Region: "eastus", Regions: []string{"westeurope", "northeurope"}}
+
+	tests := []struct {
+		region  string
+		regions []string
+		wantErr bool
+	}{
+		{region: ""},
+		{region: "eastus"},
+		{region: "westeurope"},
+		{region: "centralus", wantErr: true},
+		{region: " westeurope", wantErr: true},
+		{region: "centralus", regions: []string{}},
+		{region: "centralus ", regions: []string{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		config := *config
+		if tt.regions != nil {
+			config.Regions = tt.regions
+		}
+		err := validateRequestRegion(&models.GenerateRequest{Region: tt.region}, &config)
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidRequest)) {
+			t.Errorf("validateRequestRegion(%q, %v) error = %v, want %v %t", tt.region, config.Regions, err, ErrInvalidRequest, tt.wantErr)
+		}
+	}
+}
+
+func TestRequestRegionOverridesConfiguredRegion(t *testing.T) {
+	config := &models.Config{Region: "eastus", Backend: models.Backend{Type: "s3", Bucket: "state", Key: "web.tfstate"}}
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web"}
+	if got := prepareTemplateData(req, config, &models.Provider{Name: "aws"}, "", nil)["Region"]; got != "eastus" {
+		t.Errorf("Region without an override = %v, want eastus", got)
+	}
+
+	req.Region = "westus"
+	if got := prepareTemplateData(req, config, &models.Provider{Name: "aws"}, "", nil)["Region"]; got != "westus" {
+		t.Errorf("Region with an override = %v, want westus", got)
+	}
+	backend, err := resolveBackend(req, config, config.Backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.Region != "westus" {
+		t.Errorf("backend region = %q, want the request's westus", backend.Region)
+	}
+}
+
+func TestPrepareConfigProviderSourceHost(t *testing.T) {
+	config := testConfig()
+	config.Providers = []models.Provider{{Name: "azurerm", Source: "hashicorp/azurerm", Version: "~> 3.0"}}
+	config.ProviderSourceHost = "registry.internal"
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure"}
+
+	provider, _, err := prepareConfig(req, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.Source != "registry.internal/hashicorp/azurerm" {
+		t.Errorf("provider source = %s, want it on the mirror", provider.Source)
+	}
+}
+
+func TestEnvironmentTargetsTfvarsFilename(t *testing.T) {
+	config := testConfig()
+	config.TfvarsFilename = "{{.Environment}}.{{.Name}}.tfvars"
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Environments: []string{"dev", "prod"}}
+
+	targets, err := environmentTargets(req, config, "out", nil, "c1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range targets {
+		want := target.Environment + ".c1.tfvars"
+		if target.VarsPath != filepath.Join("out", "vars", want) || target.BackendPath != filepath.Join("out", "backend", want) {
+			t.Errorf("%s paths = %s and %s, want both named %s", target.Environment, target.VarsPath, target.BackendPath, want)
+		}
+	}
+
+	config.TfvarsFilename = "{{.Name}}_{{.Region}}.tfvars"
+	if _, err := environmentTargets(req, config, "out", nil, "c1", true); err == nil {
+		t.Error("environmentTargets() with an unknown key in the pattern succeeded, want an error")
+	}
+}
+
+func TestEnvironmentBackends(t *testing.T) {
+	mixed := `"backend": {"type": "s3", "bucket": "acme-state", "environments": {"nonprod": {"type": "local"}}}`
+	chdirGeneratorRoot(t, strings.Replace(replayTestConfig, `"backend": {"type": "local"}`, mixed, 1))
+
+	// The shared root declares one backend type, so an environment cannot switch to another
+	req := &models.GenerateRequest{OrganisationName: "acme", ProductName: "web", Provider: "azure", Modules: []string{}}
+	results, err := GenerateTerraform(req)
+	if err == nil || !strings.Contains(err.Error(), `backend.environments.nonprod.type: "local" differs from the base "s3" backend`) {
+		t.Fatalf("GenerateTerraform() error = %v, want the local nonprod backend rejected", err)
+	}
+	if _, statErr := os.Stat("output"); len(results) != 0 || !os.IsNotExist(statErr) {
+		t.Fatalf("GenerateTerraform() with mixed backend types wrote %v", results)
+	}
+
+	// Environments of the same type keep their own storage
+	sameType := `"backend": {"type": "s3", "bucket": "acme-state", "environments": {"prod": {"type": "s3", "bucket": "acme-prod-state"}}}`
+	if err := os.WriteFile(configPath, []byte(strings.Replace(replayTestConfig, `"backend": {"type": "local"}`, sameType, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configCache = utils.NewConfigCache(configPath)
+	if results, err = GenerateTerraform(req); err != nil {
+		t.Fatalf("GenerateTerraform() error: %v", err)
+	}
+	buckets := map[string]string{"web_nonprod.tfvars": `bucket = "acme-state"`, "web_prod.tfvars": `bucket = "acme-prod-state"`}
+	for _, result := range results {
+		base := filepath.Base(result.Path)
+		want, ok := buckets[base]
+		if base != "main.tf" && !ok {
+			continue
+		}
+		content, err := os.ReadFile(result.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if base == "main.tf" {
+			want = `backend "s3" {}`
+		}
+		if !strings.Contains(string(content), want) {
+			t.Errorf("%s has no %s:\n%s", result.Path, want, content)
+		}
+		delete(buckets, base)
+	}
+	if len(buckets) != 0 {
+		t.Errorf("no backend files written for %v", buckets)
+	}
+}
+
+func TestResolveEnvironmentFiles(t *testing.T) {
+	azurerm := `"backend": {"type": "azurerm", "resource_group_name": "rg-state", "storage_account_name": "stacc", "container_name": "tfstate", "key": "web.tfstate"}`
+	chdirGeneratorRoot(t, strings.Replace(replayTestConfig, `"backend": {"type": "local"}`, azurerm, 1))
+
+	files, err := ResolveEnvironmentFiles("azure", "acme", "web", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := EnvironmentFiles{BackendConfig: filepath.Join("backend", "web_prod.tfvars"), VarFile: filepath.Join("vars", "web_prod.tfvars")}
+	if files != want {
+		t.Errorf("ResolveEnvironmentFiles() = %+v, want %+v", files, want)
+	}
+
+	// JSON output writes the vars file with a .json ending
+	dir, err := ResolveOutputDir("azure", "acme", "web", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "vars"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vars", "web_prod.tfvars.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if files, err = ResolveEnvironmentFiles("azure", "acme", "web", "prod"); err != nil || files.VarFile != want.VarFile+".json" {
+		t.Errorf("ResolveEnvironmentFiles() with JSON output = %+v, %v; want %s.json", files, err, want.VarFile)
+	}
+
+	// A local backend in the environments layout keeps each environment's state and data directory apart
+	config := strings.Replace(replayTestConfig, `"modules": []`, `"modules": [], "layout": "environments"`, 1)
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configCache = utils.NewConfigCache(configPath)
+	files, err = ResolveEnvironmentFiles("azure", "acme", "web", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = EnvironmentFiles{
+		BackendConfig: "path=" + filepath.Join("envs", "prod", "terraform.tfstate"),
+		VarFile:       filepath.Join("envs", "prod", "vars.tfvars"),
+		DataDir:       filepath.Join("envs", "prod", ".terraform"),
+	}
+	if files != want {
+		t.Errorf("ResolveEnvironmentFiles() in the environments layout = %+v, want %+v", files, want)
+	}
+
+	if _, err := ResolveEnvironmentFiles("azure", "acme", "web", "../prod"); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("ResolveEnvironmentFiles(../prod) error = %v, want %v", err, ErrInvalidRequest)
+	}
+}
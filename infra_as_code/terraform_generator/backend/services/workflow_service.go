@@ -0,0 +1,34 @@
+// backend/services/workflow_service.go
+
+package services
+
+import (
+	"backend/models"
+	"backend/utils"
+	"fmt"
+	"path/filepath"
+)
+
+// workflowFile is the GitHub Actions workflow scaffolded into each product or customer directory.
+var workflowFile = filepath.Join(".github", "workflows", "terraform.yml")
+
+// generateWorkflow writes a GitHub Actions workflow that checks formatting, then validates and plans every
+// generated environment against its backend and vars files.
+func generateWorkflow(req *models.GenerateRequest, config *models.Config, path string, data map[string]interface{}, opts utils.GenerateOptions) ([]models.FileResult, error) {
+	if req.OutputFormat == models.OutputFormatCDKTF {
+		return nil, fmt.Errorf("a GitHub Actions workflow is not supported for the %s output format", models.OutputFormatCDKTF)
+	}
+
+	inputs, err := entityInputs(req, config, path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	workflowData := copyData(data)
+	workflowData["PlanInputs"] = inputs
+
+	files := []templateFile{
+		{Template: filepath.Join(templatesDir, "generic", "terraform-workflow.yml.tmpl"), Dest: filepath.Join(path, workflowFile)},
+	}
+	return renderFiles(files, workflowData, opts)
+}
@@ -0,0 +1,168 @@
+// backend/utils/config_cache.go
+
+package utils
+
+import (
+	"backend/models"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configReloadDelay lets a burst of file events, such as an editor's truncate and write, settle into a single reload.
+const configReloadDelay = 200 * time.Millisecond
+
+// ConfigCache keeps a parsed configuration file in memory and hands out copies of it, so callers may change the
+// configuration they get without affecting later callers.
+type ConfigCache struct {
+	path   string
+	mu     sync.RWMutex
+	config *models.Config // Nil until the file first loads
+}
+
+// NewConfigCache returns a cache for the configuration file at path. Nothing is read until the first Load.
+func NewConfigCache(path string) *ConfigCache {
+	return &ConfigCache{path: path}
+}
+
+// Load returns a copy of the cached configuration, reading the file when it has not loaded yet. A file that fails
+// to load is read again on the next call.
+func (c *ConfigCache) Load() (*models.Config, error) {
+	c.mu.RLock()
+	config := c.config
+	c.mu.RUnlock()
+	if config != nil {
+		return CloneConfig(config), nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.config == nil {
+		loaded, err := LoadConfig(c.path)
+		if err != nil {
+			return nil, err
+		}
+		c.config = loaded
+	}
+	return CloneConfig(c.config), nil
+}
+
+// Reload reads the file again and replaces the cached configuration with it. A file that cannot be loaded or fails
+// validation leaves the previous configuration in place, so a half-finished edit cannot break later requests.
+func (c *ConfigCache) Reload() error {
+	loaded, err := LoadConfig(c.path)
+	if err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	if err := ValidateConfig(CloneConfig(loaded)); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.config = loaded
+	c.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the configuration whenever its file is written, created or renamed into place, and passes the
+// outcome of every reload to report. The directory is watched rather than the file, since editors often replace a
+// file instead of writing to it. The returned function stops watching.
+func (c *ConfigCache) Watch(report func(error)) (func() error, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(c.path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("error watching %s: %w", filepath.Dir(c.path), err)
+	}
+
+	go func() {
+		var pending *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(c.path) || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				if pending != nil {
+					pending.Stop()
+				}
+				pending = time.AfterFunc(configReloadDelay, func() { report(c.Reload()) })
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				report(fmt.Errorf("error watching %s: %w", c.path, err))
+			}
+		}
+	}()
+	return watcher.Close, nil
+}
+
+// CloneConfig returns a deep copy of config sharing no maps, slices or pointers with it.
+func CloneConfig(config *models.Config) *models.Config {
+	return deepCopy(reflect.ValueOf(config)).Interface().(*models.Config)
+}
+
+// CloneTemplateData returns a deep copy of template data, so a render can never change values another render reads.
+func CloneTemplateData(data map[string]interface{}) map[string]interface{} {
+	return deepCopy(reflect.ValueOf(data)).Interface().(map[string]interface{})
+}
+
+// deepCopy copies value and everything it refers to
+func deepCopy(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type().Elem())
+		copied.Elem().Set(deepCopy(value.Elem()))
+		return copied
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(deepCopy(value.Elem()))
+		return copied
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeMapWithSize(value.Type(), value.Len())
+		for iter := value.MapRange(); iter.Next(); {
+			copied.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return copied
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(deepCopy(value.Index(i)))
+		}
+		return copied
+	case reflect.Struct:
+		// Unexported fields keep their value; every exported one is copied in turn
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if copied.Field(i).CanSet() {
+				copied.Field(i).Set(deepCopy(value.Field(i)))
+			}
+		}
+		return copied
+	default:
+		return value
+	}
+}
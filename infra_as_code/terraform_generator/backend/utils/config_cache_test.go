@@ -0,0 +1,78 @@
+// backend/utils/config_cache_test.go
+
+package utils
+
+import (
+	"backend/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terraform-generator.json")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"region": "westeurope", "variables": {"tags": {"type": "map(string)", "default": {"team": "web"}}}}`)
+
+	cache := NewConfigCache(path)
+	first, err := cache.Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	// Callers change their copy, e.g. when applying request overrides
+	first.Region = "eastus"
+	first.Variables["tags"].Default.(map[string]interface{})["team"] = "changed"
+	second, err := cache.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Region != "westeurope" || second.Variables["tags"].Default.(map[string]interface{})["team"] != "web" {
+		t.Fatalf("Load() returned a configuration changed through an earlier copy: %+v", second)
+	}
+
+	// An invalid edit keeps the previous configuration
+	write(`{"region": "northeurope", "layout": "nested"}`)
+	if err := cache.Reload(); err == nil {
+		t.Fatal("Reload() accepted an invalid configuration")
+	}
+	if current, _ := cache.Load(); current.Region != "westeurope" {
+		t.Fatalf("Reload() replaced the configuration with an invalid one: region %s", current.Region)
+	}
+
+	stop, err := cache.Watch(func(error) {})
+	if err != nil {
+		t.Fatalf("Watch() error: %v", err)
+	}
+	defer stop()
+	write(`{"region": "northeurope"}`)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if current, _ := cache.Load(); current.Region == "northeurope" {
+			return
+		}
+	}
+	t.Fatal("Watch() did not reload the changed configuration")
+}
+
+func TestCloneTemplateData(t *testing.T) {
+	provider := &models.Provider{Name: "azurerm", Settings: map[string]interface{}{"features": map[string]interface{}{}}}
+	data := map[string]interface{}{
+		"Provider":  provider,
+		"Variables": map[string]models.Variable{"tags": {Type: "map(string)", Default: map[string]interface{}{"Team": "web"}}},
+		"Name":      "web",
+	}
+
+	cloned := CloneTemplateData(data)
+	cloned["Provider"].(*models.Provider).Settings["features"] = "changed"
+	cloned["Variables"].(map[string]models.Variable)["tags"].Default.(map[string]interface{})["Team"] = "changed"
+	cloned["Name"] = "changed"
+
+	if provider.Settings["features"] == "changed" || data["Variables"].(map[string]models.Variable)["tags"].Default.(map[string]interface{})["Team"] != "web" || data["Name"] != "web" {
+		t.Errorf("changing the clone changed the template data: %+v", data)
+	}
+}
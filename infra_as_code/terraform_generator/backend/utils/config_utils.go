@@ -4,23 +4,897 @@ package utils
 
 import (
 	"backend/models"
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
-// LoadConfig reads the configuration from a JSON file
+// LoadConfig reads the configuration from a JSON file, resolving any values it reads from Vault
 func LoadConfig(path string) (*models.Config, error) {
-	file, err := os.Open(path)
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	if ContainsVaultReferences(content) {
+		if content, err = resolveVaultConfig(content); err != nil {
+			return nil, err
+		}
+	}
 
 	var config models.Config
-	decoder := json.NewDecoder(file)
+	decoder := json.NewDecoder(bytes.NewReader(content))
 	if err := decoder.Decode(&config); err != nil {
 		return nil, err
 	}
 
 	return &config, nil
 }
+
+// versionConstraintPattern matches a single Terraform version constraint such as "~> 1.5" or ">= 1.5.0".
+var versionConstraintPattern = regexp.MustCompile(`^(=|!=|>|>=|<|<=|~>)?\s*v?\d+(\.\d+){0,2}(-[0-9A-Za-z.-]+)?$`)
+
+// ValidateVersionConstraint checks that a comma-separated Terraform version constraint is well formed
+func ValidateVersionConstraint(constraint string) error {
+	if strings.TrimSpace(constraint) == "" {
+		return fmt.Errorf("version constraint is empty")
+	}
+	for _, part := range strings.Split(constraint, ",") {
+		if !versionConstraintPattern.MatchString(strings.TrimSpace(part)) {
+			return fmt.Errorf("invalid version constraint %q", constraint)
+		}
+	}
+	return nil
+}
+
+// ValidateConfig checks the loaded configuration for values that would render invalid Terraform
+func ValidateConfig(config *models.Config) error {
+	var problems []string
+
+	if config.TerraformVersion.Default != "" {
+		if err := ValidateVersionConstraint(config.TerraformVersion.Default); err != nil {
+			problems = append(problems, fmt.Sprintf("terraform_version: %v", err))
+		}
+	}
+	envs := make([]string, 0, len(config.TerraformVersion.Environments))
+	for env := range config.TerraformVersion.Environments {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	for _, env := range envs {
+		if err := ValidateVersionConstraint(config.TerraformVersion.Environments[env]); err != nil {
+			problems = append(problems, fmt.Sprintf("terraform_version.%s: %v", env, err))
+		}
+	}
+
+	if config.Layout != "" && config.Layout != models.LayoutFlat && config.Layout != models.LayoutEnvironments {
+		problems = append(problems, fmt.Sprintf("layout: unsupported value %q", config.Layout))
+	}
+
+	switch config.ProviderFile {
+	case "", models.ProviderFileProviders, models.ProviderFileMain, models.ProviderFileTerraform:
+	default:
+		problems = append(problems, fmt.Sprintf("provider_file: unsupported value %q: expected %s, %s or %s", config.ProviderFile, models.ProviderFileProviders, models.ProviderFileMain, models.ProviderFileTerraform))
+	}
+
+	switch config.BackendSuffix {
+	case "", models.BackendSuffixTfvars, models.BackendSuffixTfbackend, models.BackendSuffixTyped:
+	default:
+		problems = append(problems, fmt.Sprintf("backend_suffix: unsupported value %q: expected %s, %s or %s", config.BackendSuffix, models.BackendSuffixTfvars, models.BackendSuffixTfbackend, models.BackendSuffixTyped))
+	}
+
+	if config.TfvarsFilename != "" {
+		if err := ValidateTfvarsFilename(config.TfvarsFilename); err != nil {
+			problems = append(problems, fmt.Sprintf("tfvars_filename: %v", err))
+		}
+	}
+
+	ordered := make(map[string]bool, len(config.TfvarsOrder))
+	for _, name := range config.TfvarsOrder {
+		if _, ok := config.Variables[name]; !ok {
+			problems = append(problems, fmt.Sprintf("tfvars_order: %q is not a declared variable", name))
+		} else if ordered[name] {
+			problems = append(problems, fmt.Sprintf("tfvars_order: %q is listed more than once", name))
+		}
+		ordered[name] = true
+	}
+
+	if config.RenderTimeout != "" {
+		if timeout, err := time.ParseDuration(config.RenderTimeout); err != nil || timeout <= 0 {
+			problems = append(problems, fmt.Sprintf("render_timeout: %q is not a positive duration such as \"30s\"", config.RenderTimeout))
+		}
+	}
+
+	if config.MaxCustomers < 0 {
+		problems = append(problems, fmt.Sprintf("max_customers: %d must not be negative", config.MaxCustomers))
+	}
+
+	for _, pattern := range config.ProtectedFiles {
+		if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" || strings.ContainsAny(pattern, `/\`) {
+			problems = append(problems, fmt.Sprintf("protected_files: invalid file name pattern %q", pattern))
+		}
+	}
+
+	if config.OutputPath != "" {
+		if err := ValidateOutputPath(config.OutputPath); err != nil {
+			problems = append(problems, fmt.Sprintf("output_path: %v", err))
+		}
+	}
+
+	dests := make(map[string]bool)
+	for i, file := range config.StaticFiles {
+		if file.Source == "" || !filepath.IsLocal(file.Source) {
+			problems = append(problems, fmt.Sprintf("static_files[%d].source: %q must be a path inside the templates directory", i, file.Source))
+		}
+		dest := filepath.Clean(file.Dest)
+		if file.Dest == "" || !filepath.IsLocal(file.Dest) {
+			problems = append(problems, fmt.Sprintf("static_files[%d].dest: %q must be a path inside the output directory", i, file.Dest))
+		} else if dests[dest] {
+			problems = append(problems, fmt.Sprintf("static_files[%d].dest: duplicate destination %q", i, file.Dest))
+		} else if generatedPath(dest) {
+			problems = append(problems, fmt.Sprintf("static_files[%d].dest: %q would overwrite a file the generator writes", i, file.Dest))
+		}
+		dests[dest] = true
+	}
+
+	problems = append(problems, cloudProblems(config)...)
+	problems = append(problems, backendEnvironmentProblems(config.Backend)...)
+
+	customerNames := make([]string, 0, len(config.Customers))
+	for customer := range config.Customers {
+		customerNames = append(customerNames, customer)
+	}
+	sort.Strings(customerNames)
+	for _, customer := range customerNames {
+		settings := config.Customers[customer]
+		if settings.Template != "" && settings.TemplateDir != "" {
+			problems = append(problems, fmt.Sprintf("customers.%s: template and template_dir are mutually exclusive", customer))
+		}
+		if settings.Template != "" && !filepath.IsLocal(settings.Template) {
+			problems = append(problems, fmt.Sprintf("customers.%s.template: %q must be a path inside the templates directory", customer, settings.Template))
+		}
+		if settings.TemplateDir != "" && !filepath.IsLocal(settings.TemplateDir) {
+			problems = append(problems, fmt.Sprintf("customers.%s.template_dir: %q must be a path inside the templates directory", customer, settings.TemplateDir))
+		}
+	}
+
+	customers := make([]string, 0, len(config.CustomerPatches))
+	for customer := range config.CustomerPatches {
+		customers = append(customers, customer)
+	}
+	sort.Strings(customers)
+	for _, customer := range customers {
+		if err := ValidatePatch(config.CustomerPatches[customer]); err != nil {
+			problems = append(problems, fmt.Sprintf("customer_patches.%s: %v", customer, err))
+		}
+	}
+
+	regionsByAlias := make(map[string]string)
+	for _, region := range config.Regions {
+		alias := RegionAlias(region)
+		switch {
+		case strings.TrimSpace(region) == "":
+			problems = append(problems, "regions: empty region name")
+		case regionsByAlias[alias] == region:
+			problems = append(problems, fmt.Sprintf("regions: duplicate region %q", region))
+		case regionsByAlias[alias] != "":
+			problems = append(problems, fmt.Sprintf("regions: %q and %q both use the provider alias %q", regionsByAlias[alias], region, alias))
+		case !identifierPattern.MatchString(alias):
+			problems = append(problems, fmt.Sprintf("regions: %q cannot be used as a provider alias", region))
+		}
+		if regionsByAlias[alias] == "" {
+			regionsByAlias[alias] = region
+		}
+	}
+
+	for name, variable := range config.Variables {
+		problems = append(problems, expressionProblems("variables."+name, variable, true)...)
+		problems = append(problems, heredocProblems("variables."+name, variable)...)
+		problems = append(problems, typeProblems("variables."+name, variable)...)
+	}
+
+	labels := make(map[string]bool)
+	for _, module := range config.Modules {
+		label := module.BlockLabel()
+		if !identifierPattern.MatchString(label) {
+			problems = append(problems, fmt.Sprintf("modules: invalid label %q", label))
+		}
+		if labels[label] {
+			problems = append(problems, fmt.Sprintf("modules: duplicate label %q", label))
+		}
+		labels[label] = true
+
+		aliases := make(map[string]bool)
+		for _, alias := range module.ConfigurationAliases {
+			if !identifierPattern.MatchString(alias) {
+				problems = append(problems, fmt.Sprintf("modules.%s.configuration_aliases: invalid alias %q", label, alias))
+			} else if aliases[alias] {
+				problems = append(problems, fmt.Sprintf("modules.%s.configuration_aliases: duplicate alias %q", label, alias))
+			}
+			aliases[alias] = true
+		}
+
+		for name, variable := range module.Variables {
+			problems = append(problems, expressionProblems("modules."+label+".variables."+name, variable.Variable, false)...)
+			problems = append(problems, heredocProblems("modules."+label+".variables."+name, variable.Variable)...)
+			problems = append(problems, typeProblems("modules."+label+".variables."+name, variable.Variable)...)
+		}
+
+		problems = append(problems, countProblems("modules."+label, module.CountVariable(), module.Count, config.Variables)...)
+
+		if module.Lifecycle != nil {
+			for _, attribute := range module.Lifecycle.IgnoreChanges {
+				if attribute == "all" && len(module.Lifecycle.IgnoreChanges) > 1 {
+					problems = append(problems, fmt.Sprintf("modules.%s.lifecycle.ignore_changes: \"all\" cannot be combined with attribute names", label))
+				} else if attribute != "all" && !attributePathPattern.MatchString(attribute) {
+					problems = append(problems, fmt.Sprintf("modules.%s.lifecycle.ignore_changes: invalid attribute %q", label, attribute))
+				}
+			}
+		}
+	}
+
+	addresses := make(map[string]bool)
+	for _, resource := range config.Resources {
+		address := resource.Type + "." + resource.Name
+		if !identifierPattern.MatchString(resource.Type) || !identifierPattern.MatchString(resource.Name) {
+			problems = append(problems, fmt.Sprintf("resources: invalid address %q", address))
+			continue
+		}
+		if addresses[address] {
+			problems = append(problems, fmt.Sprintf("resources: duplicate resource %q", address))
+		}
+		addresses[address] = true
+		problems = append(problems, argumentProblems("resources."+address, resource.Arguments)...)
+		problems = append(problems, countProblems("resources."+address, resource.CountVariable(), resource.Count, config.Variables)...)
+		if _, ok := resource.Arguments["count"]; ok && len(resource.Count) > 0 {
+			problems = append(problems, fmt.Sprintf("resources.%s: count cannot be set both per environment and under arguments", address))
+		}
+	}
+
+	removed := make(map[string]bool)
+	for i, block := range config.Removed {
+		if !removedAddressPattern.MatchString(block.From) || strings.HasPrefix(block.From, "data.") {
+			problems = append(problems, fmt.Sprintf("removed[%d].from: invalid resource or module address %q", i, block.From))
+		} else if removed[block.From] {
+			problems = append(problems, fmt.Sprintf("removed[%d].from: duplicate address %q", i, block.From))
+		}
+		removed[block.From] = true
+	}
+
+	for _, provider := range config.Providers {
+		problems = append(problems, providerSettingsProblems(provider.Name, provider.Settings)...)
+		problems = append(problems, featureProblems(provider.Name+".features", provider.Features)...)
+		problems = append(problems, assumeRoleProblems(provider.Name, provider)...)
+		problems = append(problems, retryProblems(provider)...)
+		for env, override := range provider.Environments {
+			if override.AssumeRole != nil || len(override.AuthVariables) > 0 || len(override.Settings) > 0 {
+				problems = append(problems, assumeRoleProblems(provider.Name+".environments."+env, provider.ForEnvironment(env))...)
+			}
+			problems = append(problems, providerSettingsProblems(provider.Name+".environments."+env, override.Settings)...)
+		}
+	}
+
+	problems = append(problems, linkedProviderProblems(config)...)
+	problems = append(problems, countedReferenceProblems(config)...)
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// linkedProviderProblems checks each linked provider's name, source and version, and that every module output its
+// settings reference is declared by a configured module.
+func linkedProviderProblems(config *models.Config) []string {
+	outputs := make(map[string]map[string]models.ModuleOutput, len(config.Modules))
+	for _, module := range config.Modules {
+		outputs[module.BlockLabel()] = module.Outputs
+	}
+	names := make(map[string]bool)
+	for _, provider := range config.Providers {
+		names[provider.Name] = true
+	}
+
+	var problems []string
+	for i, provider := range config.LinkedProviders {
+		scope := fmt.Sprintf("linked_providers[%d]", i)
+		if !identifierPattern.MatchString(provider.Name) {
+			problems = append(problems, fmt.Sprintf("%s: invalid provider name %q", scope, provider.Name))
+			continue
+		}
+		if names[provider.Name] {
+			problems = append(problems, fmt.Sprintf("%s: provider %q is already configured", scope, provider.Name))
+		}
+		names[provider.Name] = true
+		scope = "linked_providers." + provider.Name
+
+		if provider.Source == "" {
+			problems = append(problems, fmt.Sprintf("%s: source is required", scope))
+		}
+		if err := ValidateVersionConstraint(provider.Version); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.version: %v", scope, err))
+		}
+		problems = append(problems, argumentProblems(scope+".settings", provider.Settings)...)
+		for _, reference := range ModuleReferences(provider.Settings) {
+			declared, ok := outputs[reference.Label]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s.settings: module %q is not configured", scope, reference.Label))
+			} else if _, ok := declared[reference.Output]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.settings: module %q does not declare output %q", scope, reference.Label, reference.Output))
+			}
+		}
+	}
+	return problems
+}
+
+// generatedFiles are written by the generator into product and customer directories, in HCL, JSON or CDKTF output
+var generatedFiles = []string{
+	"main.tf", "providers.tf", "terraform.tf", "variables.tf", "vars.tfvars", "resources.tf", "removed.tf",
+	"main.tf.json", "providers.tf.json", "terraform.tf.json", "variables.tf.json", "vars.tfvars.json",
+	"resources.tf.json", "removed.tf.json", "cdktf.json", "variables.json", "main.ts",
+	".generator-version",
+}
+
+// generatedDirectories hold the generator's per-environment backend and vars files
+var generatedDirectories = []string{"backend", "vars", "envs"}
+
+// generatedPath reports whether a clean path relative to an output directory is one of the generated files or lies in
+// a generated directory. Names are compared ignoring case, which some file systems do.
+func generatedPath(path string) bool {
+	top, _, nested := strings.Cut(filepath.ToSlash(path), "/")
+	for _, dir := range generatedDirectories {
+		if strings.EqualFold(top, dir) {
+			return true
+		}
+	}
+	for _, file := range generatedFiles {
+		if !nested && strings.EqualFold(top, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// identifierPattern matches a valid HCL attribute name.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// IsIdentifier reports whether name can be used as an HCL block label or attribute name
+func IsIdentifier(name string) bool {
+	return identifierPattern.MatchString(name)
+}
+
+// attributePathPattern matches an attribute reference such as tags or tags["env"] or os_disk[0].caching.
+var attributePathPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*(\.[A-Za-z_][A-Za-z0-9_-]*|\[[0-9]+\]|\["[^"]*"\])*$`)
+
+// removedAddressPattern matches the address a removed block drops: a resource or module, optionally inside modules,
+// without instance keys, e.g. module.network.azurerm_subnet.legacy.
+var removedAddressPattern = regexp.MustCompile(`^(module\.[A-Za-z_][A-Za-z0-9_-]*\.)*(module\.[A-Za-z_][A-Za-z0-9_-]*|[A-Za-z_][A-Za-z0-9_-]*\.[A-Za-z_][A-Za-z0-9_-]*)$`)
+
+// providerSettingsProblems reports provider settings whose names cannot be rendered as attributes
+func providerSettingsProblems(scope string, settings map[string]interface{}) []string {
+	var problems []string
+	for name := range settings {
+		if !identifierPattern.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("providers.%s.settings: invalid attribute name %q", scope, name))
+		}
+	}
+	return problems
+}
+
+// roleARNPattern and samlProviderARNPattern match IAM role and SAML identity provider ARNs in any AWS partition.
+var (
+	roleARNPattern         = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/[\w+=,.@/-]+$`)
+	samlProviderARNPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:saml-provider/[\w.-]+$`)
+)
+
+// assumeRoleSettings are provider attributes that select credentials, which assume_role must not be combined with.
+var assumeRoleSettings = []string{"access_key", "secret_key", "profile", "assume_role", "assume_role_with_web_identity"}
+
+// assumeRoleProblems reports an assume_role block that is malformed or combined with another aws authentication mode.
+// provider has its environment's overrides merged in when scope names an environment.
+func assumeRoleProblems(scope string, provider models.Provider) []string {
+	role := provider.AssumeRole
+	if role == nil {
+		return nil
+	}
+	if provider.Name != "aws" {
+		return []string{fmt.Sprintf("providers.%s.assume_role: only supported for the aws provider", scope)}
+	}
+
+	var problems []string
+	if !roleARNPattern.MatchString(role.RoleARN) {
+		problems = append(problems, fmt.Sprintf("providers.%s.assume_role.role_arn: invalid IAM role ARN %q", scope, role.RoleARN))
+	}
+	if role.SAMLProviderARN != "" {
+		if !samlProviderARNPattern.MatchString(role.SAMLProviderARN) {
+			problems = append(problems, fmt.Sprintf("providers.%s.assume_role.saml_provider_arn: invalid SAML provider ARN %q", scope, role.SAMLProviderARN))
+		}
+		if role.Profile == "" {
+			problems = append(problems, fmt.Sprintf("providers.%s.assume_role: saml_provider_arn requires the profile holding the federated session", scope))
+		}
+	}
+	if provider.AuthVariables["web_identity_token_file"] != "" {
+		problems = append(problems, fmt.Sprintf("providers.%s.assume_role: cannot be combined with web identity authentication (auth_variables.web_identity_token_file)", scope))
+	}
+	for _, name := range assumeRoleSettings {
+		if _, ok := provider.Settings[name]; ok {
+			problems = append(problems, fmt.Sprintf("providers.%s.assume_role: cannot be combined with settings.%s", scope, name))
+		}
+	}
+	return problems
+}
+
+// retrySettings lists each retry setting with the provider attribute it renders and the provider supporting it
+var retrySettings = []struct{ Field, Attribute, Provider string }{
+	{"max_retries", "max_retries", "aws"},
+	{"mode", "retry_mode", "aws"},
+	{"request_timeout", "request_timeout", "google"},
+}
+
+// retryProblems reports retry settings the provider does not support, invalid values and settings rendering the same
+// attributes
+func retryProblems(provider models.Provider) []string {
+	retry := provider.Retry
+	if retry == nil {
+		return nil
+	}
+
+	var problems []string
+	set := map[string]bool{"max_retries": retry.MaxRetries != nil, "mode": retry.Mode != "", "request_timeout": retry.RequestTimeout != ""}
+	for _, setting := range retrySettings {
+		if !set[setting.Field] {
+			continue
+		}
+		if setting.Provider != provider.Name {
+			problems = append(problems, fmt.Sprintf("providers.%s.retry.%s: only supported for the %s provider", provider.Name, setting.Field, setting.Provider))
+		} else if _, ok := provider.Settings[setting.Attribute]; ok {
+			problems = append(problems, fmt.Sprintf("providers.%s.retry.%s: cannot be combined with settings.%s", provider.Name, setting.Field, setting.Attribute))
+		}
+	}
+	if retry.MaxRetries != nil && *retry.MaxRetries < 0 {
+		problems = append(problems, fmt.Sprintf("providers.%s.retry.max_retries: must not be negative", provider.Name))
+	}
+	if retry.Mode != "" && retry.Mode != models.RetryModeStandard && retry.Mode != models.RetryModeAdaptive {
+		problems = append(problems, fmt.Sprintf("providers.%s.retry.mode: unsupported value %q, expected %s or %s", provider.Name, retry.Mode, models.RetryModeStandard, models.RetryModeAdaptive))
+	}
+	if retry.RequestTimeout != "" {
+		if timeout, err := time.ParseDuration(retry.RequestTimeout); err != nil || timeout <= 0 {
+			problems = append(problems, fmt.Sprintf("providers.%s.retry.request_timeout: invalid duration %q", provider.Name, retry.RequestTimeout))
+		}
+	}
+	return problems
+}
+
+// backendEnvironmentProblems reports environment backends of another type than the base backend. Every environment
+// shares the root configuration and its backend block, so only the partial configuration can vary per environment.
+func backendEnvironmentProblems(backend models.Backend) []string {
+	var problems []string
+	base := backendType(backend)
+	for _, env := range sortedKeys(backend.Environments) {
+		if override := backendType(backend.Environments[env]); override != base {
+			problems = append(problems, fmt.Sprintf("backend.environments.%s.type: %q differs from the base %q backend; every environment shares the root configuration's backend block", env, override, base))
+		}
+	}
+	return problems
+}
+
+// backendType returns the type of a backend, azurerm when none is set
+func backendType(backend models.Backend) string {
+	if backend.Type == "" {
+		return "azurerm"
+	}
+	return backend.Type
+}
+
+// cloudProblems reports an incomplete cloud block, or one configured alongside a backend: Terraform accepts only one
+// of the two and the cloud block takes no backend tfvars
+func cloudProblems(config *models.Config) []string {
+	cloud := config.Cloud
+	if cloud == nil {
+		return nil
+	}
+
+	var problems []string
+	if strings.TrimSpace(cloud.Organization) == "" {
+		problems = append(problems, "cloud.organization: required")
+	}
+	if strings.Contains(cloud.Hostname, "/") {
+		problems = append(problems, fmt.Sprintf("cloud.hostname: %q must be a host name without a scheme or path", cloud.Hostname))
+	}
+	workspaces := cloud.Workspaces
+	switch {
+	case workspaces.Name == "" && len(workspaces.Tags) == 0:
+		problems = append(problems, "cloud.workspaces: set either name or tags")
+	case workspaces.Name != "" && len(workspaces.Tags) > 0:
+		problems = append(problems, "cloud.workspaces: name and tags are mutually exclusive")
+	}
+	for _, tag := range workspaces.Tags {
+		if strings.TrimSpace(tag) == "" {
+			problems = append(problems, "cloud.workspaces.tags: empty tag")
+		}
+	}
+	if !reflect.DeepEqual(config.Backend, models.Backend{}) {
+		problems = append(problems, "cloud: cannot be combined with a backend; remove the backend section")
+	}
+	return problems
+}
+
+// countProblems reports negative per-environment counts and a count variable that clashes with a declared variable
+func countProblems(scope, variable string, count map[string]int, variables map[string]models.Variable) []string {
+	if len(count) == 0 {
+		return nil
+	}
+	var problems []string
+	for _, env := range sortedKeys(count) {
+		if count[env] < 0 {
+			problems = append(problems, fmt.Sprintf("%s.count.%s: must not be negative, got %d", scope, env, count[env]))
+		}
+	}
+	if _, ok := variables[variable]; ok {
+		problems = append(problems, fmt.Sprintf("%s.count: the generated variable %q clashes with a declared variable", scope, variable))
+	}
+	return problems
+}
+
+// countedReferenceProblems reports references to an output of a module with a count that select no instance, e.g.
+// module.vnet.id rather than module.vnet[0].id, in module inputs and outputs, resource arguments and linked
+// provider settings. A counted module is a list, so Terraform rejects such a reference at plan time.
+func countedReferenceProblems(config *models.Config) []string {
+	counted := make(map[string]bool)
+	for _, module := range config.Modules {
+		if len(module.Count) > 0 {
+			counted[module.BlockLabel()] = true
+		}
+	}
+	if len(counted) == 0 {
+		return nil
+	}
+
+	var problems []string
+	check := func(scope string, value interface{}) {
+		for _, reference := range ModuleReferences(value) {
+			if counted[reference.Label] && !reference.Indexed {
+				problems = append(problems, fmt.Sprintf("%s: module %q has a count, so its outputs need an index, e.g. module.%s[0].%s", scope, reference.Label, reference.Label, reference.Output))
+			}
+		}
+	}
+	for _, module := range config.Modules {
+		label := module.BlockLabel()
+		for _, name := range sortedKeys(module.Variables) {
+			variable := module.Variables[name]
+			check("modules."+label+".variables."+name, []interface{}{variable.Value, variable.Default})
+		}
+		for _, name := range sortedKeys(module.Outputs) {
+			check("modules."+label+".outputs."+name, module.Outputs[name].Value)
+		}
+	}
+	for _, resource := range config.Resources {
+		check("resources."+resource.Type+"."+resource.Name, resource.Arguments)
+	}
+	for _, provider := range config.LinkedProviders {
+		check("linked_providers."+provider.Name+".settings", provider.Settings)
+	}
+	return problems
+}
+
+// typeProblems reports a default or value that does not match the variable's declared type, which Terraform would
+// only reject at plan time. Per-environment defaults are checked entry by entry; expressions are not checked.
+func typeProblems(scope string, variable models.Variable) []string {
+	if variable.Type == "" || variable.Expression {
+		return nil
+	}
+
+	values := map[string]interface{}{"value": variable.Value, "default": variable.Default}
+	if byEnvironment, ok := variable.Default.(map[string]interface{}); ok && variable.PerEnvironment {
+		delete(values, "default")
+		for env, value := range byEnvironment {
+			values["default."+env] = value
+		}
+	}
+
+	var problems []string
+	for name, value := range values {
+		problems = append(problems, ValueTypeProblems(scope+"."+name, value, variable.Type, variable.Attributes)...)
+	}
+	return problems
+}
+
+// heredocProblems reports a heredoc variable that is not a plain string
+func heredocProblems(scope string, variable models.Variable) []string {
+	switch {
+	case !variable.Heredoc:
+		return nil
+	case variable.Type != "string":
+		return []string{fmt.Sprintf("%s: heredoc requires type string, got %q", scope, variable.Type)}
+	case variable.Expression:
+		return []string{fmt.Sprintf("%s: heredoc cannot be combined with expression", scope)}
+	}
+	return nil
+}
+
+// expressionProblems reports an expression where Terraform requires a constant value, or one that is not a
+// non-empty string. Generic variables only render into variables.tf defaults and tfvars files, and a module variable's
+// default into the module's variables.tf, so only a module variable's value, passed as a module argument, may be an
+// expression.
+func expressionProblems(scope string, variable models.Variable, generic bool) []string {
+	if !variable.Expression {
+		return nil
+	}
+	if variable.LiteralDollar {
+		return []string{fmt.Sprintf("%s: expression cannot be combined with literal_dollar", scope)}
+	}
+	if generic {
+		return []string{fmt.Sprintf("%s: expression is only supported on module variable values; variable defaults and tfvars files must be constant values", scope)}
+	}
+	if variable.Default != nil {
+		return []string{fmt.Sprintf("%s.default: an expression default is not supported; variable defaults must be constant values, so set the expression as the value", scope)}
+	}
+	if expr, ok := variable.Value.(string); !ok || strings.TrimSpace(expr) == "" {
+		return []string{fmt.Sprintf("%s.value: an expression must be a non-empty string", scope)}
+	}
+	return nil
+}
+
+// argumentProblems reports resource argument names that cannot be rendered, descending into nested blocks
+func argumentProblems(scope string, arguments map[string]interface{}) []string {
+	var problems []string
+	for name, value := range arguments {
+		if !identifierPattern.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("%s: invalid argument name %q", scope, name))
+			continue
+		}
+		if blocks, ok := isBlockList(value); ok {
+			for _, block := range blocks {
+				problems = append(problems, argumentProblems(scope+"."+name, block)...)
+			}
+		}
+	}
+	return problems
+}
+
+// requiredBackendFields lists the settings each backend type needs for terraform init to succeed.
+var requiredBackendFields = map[string][]string{
+	"s3":      {"bucket", "key", "region"},
+	"gcs":     {"bucket", "prefix"},
+	"azurerm": {"resource_group_name", "storage_account_name", "container_name"},
+}
+
+// ValidateBackend checks that every setting required by the backend type is present
+func ValidateBackend(backend models.Backend) error {
+	var missing []string
+	for _, field := range requiredBackendFields[backend.Type] {
+		if backend.Value(field) == "" {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("incomplete %s backend configuration: missing %s", backend.Type, strings.Join(missing, ", "))
+	}
+
+	// azurerm and gcs lock state natively; s3 needs a DynamoDB table
+	if backend.RequireLocking && backend.Type == "s3" && backend.Value("dynamodb_table") == "" {
+		return fmt.Errorf("invalid s3 backend configuration: require_locking is set but dynamodb_table is missing")
+	}
+
+	// s3 stores workspace state at <workspace_key_prefix>/<workspace>/<key>, so repeating the prefix in the key is redundant
+	if prefix := strings.Trim(backend.WorkspaceKeyPrefix, "/"); backend.Type == "s3" && prefix != "" {
+		if key := strings.TrimPrefix(backend.Key, "/"); key == prefix || strings.HasPrefix(key, prefix+"/") {
+			return fmt.Errorf("invalid s3 backend configuration: key %q already includes workspace_key_prefix %q", backend.Key, backend.WorkspaceKeyPrefix)
+		}
+	}
+	return nil
+}
+
+// DefaultBackendKey computes the s3 state key when none is configured, giving every product or customer and
+// environment a state object of its own, e.g. acme/web/c1/prod/terraform.tfstate. An entity named like the product
+// is the product itself and adds no segment.
+func DefaultBackendKey(backend models.Backend, organisation, product, entity, environment string) models.Backend {
+	if backend.Type != "s3" || backend.Value("key") != "" {
+		return backend
+	}
+	segments := []string{organisation, product}
+	if entity != "" && entity != product {
+		segments = append(segments, entity)
+	}
+	if environment != "" {
+		segments = append(segments, environment)
+	}
+	backend.Key = strings.Join(append(segments, "terraform.tfstate"), "/")
+	return backend
+}
+
+// featureProblems reports feature block and attribute names that cannot be rendered, descending into nested blocks
+func featureProblems(scope string, features map[string]interface{}) []string {
+	var problems []string
+	for name, value := range features {
+		if !identifierPattern.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("providers.%s: invalid name %q", scope, name))
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			problems = append(problems, featureProblems(scope+"."+name, nested)...)
+		}
+	}
+	return problems
+}
+
+// ValidateTfvarsFilename checks that a tfvars filename pattern renders path-safe names that differ for every name and environment
+func ValidateTfvarsFilename(pattern string) error {
+	seen := make(map[string]string)
+	for _, name := range []string{"product", "customer"} {
+		for _, env := range []string{"nonprod", "prod"} {
+			filename, err := TfvarsFilename(pattern, name, env)
+			if err != nil {
+				return err
+			}
+			combination := name + "/" + env
+			if previous, exists := seen[filename]; exists {
+				return fmt.Errorf("pattern %q renders %q for both %s and %s; it must use .Name and .Environment", pattern, filename, previous, combination)
+			}
+			seen[filename] = combination
+		}
+	}
+	return nil
+}
+
+// ValidateOutputPath checks that an output path pattern renders safe, non-empty directories that differ for a product
+// and a customer
+func ValidateOutputPath(pattern string) error {
+	sample := OutputPathData{Provider: "azurerm", OrganisationName: "organisation", ProductName: "product"}
+	product, err := OutputPath(pattern, sample)
+	if err != nil {
+		return err
+	}
+	sample.CustomerName = "customer"
+	customer, err := OutputPath(pattern, sample)
+	if err != nil {
+		return err
+	}
+	if product == "" {
+		return fmt.Errorf("pattern %q renders an empty path", pattern)
+	}
+	if customer == product {
+		return fmt.Errorf("pattern %q renders %q for both products and customers; it must use .CustomerName", pattern, product)
+	}
+	return nil
+}
+
+// ResolveBackendFromEnv fills backend fields marked as from_env with the values of their environment variables
+func ResolveBackendFromEnv(backend models.Backend) (models.Backend, error) {
+	resolved := backend
+	var problems []string
+
+	fields := make([]string, 0, len(backend.FromEnv))
+	for field := range backend.FromEnv {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		envVar := backend.FromEnv[field]
+		target := resolved.Field(field)
+		if target == nil {
+			problems = append(problems, fmt.Sprintf("unknown backend field %q", field))
+			continue
+		}
+		value, ok := os.LookupEnv(envVar)
+		if !ok || value == "" {
+			problems = append(problems, fmt.Sprintf("%s: environment variable %s is not set", field, envVar))
+			continue
+		}
+		*target = value
+	}
+
+	if len(problems) > 0 {
+		return backend, fmt.Errorf("invalid backend configuration: %s", strings.Join(problems, "; "))
+	}
+	return resolved, nil
+}
+
+// fileReferenceKey marks an object that stands for the decoded contents of a JSON file, e.g. {"$file": "cidrs.json"}
+const fileReferenceKey = "$file"
+
+// ResolveFileReferences replaces {"$file": "<path>"} objects found at any depth in variable defaults and values with
+// the decoded contents of that JSON file. Paths are relative to dir, the configuration's directory, and cannot lead
+// out of it.
+func ResolveFileReferences(config *models.Config, dir string) error {
+	for _, name := range sortedKeys(config.Variables) {
+		variable := config.Variables[name]
+		if err := resolveVariableFiles(&variable, dir, "variables."+name); err != nil {
+			return err
+		}
+		config.Variables[name] = variable
+	}
+	for _, module := range config.Modules {
+		for _, name := range sortedKeys(module.Variables) {
+			variable := module.Variables[name]
+			if err := resolveVariableFiles(&variable.Variable, dir, "modules."+module.BlockLabel()+".variables."+name); err != nil {
+				return err
+			}
+			module.Variables[name] = variable
+		}
+	}
+	return nil
+}
+
+// resolveVariableFiles inlines the file references in a variable's default and value
+func resolveVariableFiles(variable *models.Variable, dir, scope string) error {
+	var err error
+	if variable.Default, err = resolveFileValue(variable.Default, dir); err != nil {
+		return fmt.Errorf("%s.default: %w", scope, err)
+	}
+	if variable.Value, err = resolveFileValue(variable.Value, dir); err != nil {
+		return fmt.Errorf("%s.value: %w", scope, err)
+	}
+	return nil
+}
+
+// resolveFileValue returns value with every file reference replaced by the file's decoded JSON. The loaded contents
+// are inlined as they are, so a file cannot reference further files.
+func resolveFileValue(value interface{}, dir string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if reference, ok := v[fileReferenceKey]; ok && len(v) == 1 {
+			return loadFileReference(reference, dir)
+		}
+		for key, item := range v {
+			resolved, err := resolveFileValue(item, dir)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			resolved, err := resolveFileValue(item, dir)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+// loadFileReference reads and decodes the JSON file a $file reference names, which must lie inside dir
+func loadFileReference(reference interface{}, dir string) (interface{}, error) {
+	path, ok := reference.(string)
+	if !ok || strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("%s must be a non-empty file path", fileReferenceKey)
+	}
+	if !filepath.IsLocal(path) {
+		return nil, fmt.Errorf("%s %q must be a relative path inside the configuration directory", fileReferenceKey, reference)
+	}
+	path = filepath.Join(dir, path)
+
+	// Nor may a symbolic link lead out of the directory; a missing file is reported by the read below
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		root, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return nil, err
+		}
+		if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("%s %q leads outside the configuration directory", fileReferenceKey, reference)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s %q: %w", fileReferenceKey, reference, err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(content, &value); err != nil {
+		return nil, fmt.Errorf("%s %q is not valid JSON: %w", fileReferenceKey, reference, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,419 @@
+// backend/utils/config_utils_test.go
+
+package utils
+
+import (
+	"backend/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountProblems(t *testing.T) {
+	variables := map[string]models.Variable{"vnet_count": {Type: "number"}}
+
+	tests := []struct {
+		name     string
+		variable string
+		count    map[string]int
+		want     []string
+	}{
+		{name: "no count", variable: "vnet_count", count: nil, want: nil},
+		{name: "valid", variable: "aks_count", count: map[string]int{"prod": 3, "default": 0}, want: nil},
+		{
+			name:     "negative entries, by environment",
+			variable: "aks_count",
+			count:    map[string]int{"prod": -1, "dev": -2},
+			want:     []string{"modules.aks.count.dev: must not be negative, got -2", "modules.aks.count.prod: must not be negative, got -1"},
+		},
+		{
+			name:     "clashing variable",
+			variable: "vnet_count",
+			count:    map[string]int{"default": 1},
+			want:     []string{`modules.aks.count: the generated variable "vnet_count" clashes with a declared variable`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := countProblems("modules.aks", tt.variable, tt.count, variables)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("countProblems() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryProblems(t *testing.T) {
+	three, negative := 3, -1
+
+	tests := []struct {
+		name     string
+		provider models.Provider
+		want     []string
+	}{
+		{name: "no retry", provider: models.Provider{Name: "aws"}, want: nil},
+		{name: "aws", provider: models.Provider{Name: "aws", Retry: &models.ProviderRetry{MaxRetries: &three, Mode: models.RetryModeAdaptive}}, want: nil},
+		{name: "google", provider: models.Provider{Name: "google", Retry: &models.ProviderRetry{RequestTimeout: "60s"}}, want: nil},
+		{
+			name:     "unsupported by the provider",
+			provider: models.Provider{Name: "azurerm", Retry: &models.ProviderRetry{MaxRetries: &three, RequestTimeout: "60s"}},
+			want: []string{
+				"providers.azurerm.retry.max_retries: only supported for the aws provider",
+				"providers.azurerm.retry.request_timeout: only supported for the google provider",
+			},
+		},
+		{
+			name:     "clashing setting",
+			provider: models.Provider{Name: "aws", Settings: map[string]interface{}{"retry_mode": "standard"}, Retry: &models.ProviderRetry{Mode: models.RetryModeStandard}},
+			want:     []string{"providers.aws.retry.mode: cannot be combined with settings.retry_mode"},
+		},
+		{
+			name:     "invalid values",
+			provider: models.Provider{Name: "aws", Retry: &models.ProviderRetry{MaxRetries: &negative, Mode: "legacy"}},
+			want: []string{
+				"providers.aws.retry.max_retries: must not be negative",
+				`providers.aws.retry.mode: unsupported value "legacy", expected standard or adaptive`,
+			},
+		},
+		{
+			name:     "timeout without a unit",
+			provider: models.Provider{Name: "google", Retry: &models.ProviderRetry{RequestTimeout: "60"}},
+			want:     []string{`providers.google.retry.request_timeout: invalid duration "60"`},
+		},
+		{
+			name:     "zero timeout",
+			provider: models.Provider{Name: "google", Retry: &models.ProviderRetry{RequestTimeout: "0s"}},
+			want:     []string{`providers.google.retry.request_timeout: invalid duration "0s"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryProblems(tt.provider); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("retryProblems() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfigCountedModuleReferences(t *testing.T) {
+	config := func() *models.Config {
+		return &models.Config{
+
+			Modules: []models.Module{
+				{ModuleName: "eks", Count: map[string]int{"default": 1}, Outputs: map[string]models.ModuleOutput{"endpoint": {Value: "aws_eks_cluster.main.endpoint"}}},
+				{ModuleName: "apps", Variables: map[string]models.ModuleVariable{"host": {Variable: models.Variable{Type: "string", Value: "module.eks[0].endpoint"}}}},
+			},
+			LinkedProviders: []models.LinkedProvider{{Name: "kubernetes", Source: "hashicorp/kubernetes", Version: "~> 2.0", Settings: map[string]interface{}{"host": "module.eks[0].endpoint"}}},
+		}
+	}
+
+	if err := ValidateConfig(config()); err != nil {
+		t.Fatalf("ValidateConfig() with indexed references error: %v", err)
+	}
+
+	unindexed := config()
+	unindexed.Modules[1].Variables["host"] = models.ModuleVariable{Variable: models.Variable{Type: "string", Value: "${module.eks.endpoint}"}}
+	unindexed.LinkedProviders[0].Settings["host"] = "module.eks.endpoint"
+	unindexed.Resources = []models.Resource{{Type: "aws_route53_record", Name: "api", Arguments: map[string]interface{}{"records": []interface{}{"module.eks.endpoint"}}}}
+	err := ValidateConfig(unindexed)
+	if err == nil {
+		t.Fatal("ValidateConfig() accepted references to a counted module without an index")
+	}
+	for _, scope := range []string{"modules.apps.variables.host", "linked_providers.kubernetes.settings", "resources.aws_route53_record.api"} {
+		if want := scope + `: module "eks" has a count, so its outputs need an index, e.g. module.eks[0].endpoint`; !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateConfig() error = %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestValidateConfigStaticFileDestinations(t *testing.T) {
+	tests := []struct {
+		dest    string
+		wantErr string
+	}{
+		{dest: "SECURITY.md"},
+		{dest: "docs/main.tf"},
+		{dest: "backends.md"},
+		{dest: "main.tf", wantErr: "would overwrite a file the generator writes"},
+		{dest: "Providers.TF", wantErr: "would overwrite a file the generator writes"},
+		{dest: "./variables.tf.json", wantErr: "would overwrite a file the generator writes"},
+		{dest: "backend/prod.tfvars", wantErr: "would overwrite a file the generator writes"},
+		{dest: "envs/prod/extra.tfvars", wantErr: "would overwrite a file the generator writes"},
+		{dest: "../LICENSE", wantErr: "must be a path inside the output directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dest, func(t *testing.T) {
+			config := &models.Config{StaticFiles: []models.StaticFile{{Source: "static/file", Dest: tt.dest}}}
+			err := ValidateConfig(config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateConfig() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateConfig() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemovedAddressPattern(t *testing.T) {
+	for address, want := range map[string]bool{
+		"azurerm_resource_group.old":               true,
+		"module.legacy_vnet":                       true,
+		"module.network.azurerm_subnet.old":        true,
+		"module.network.module.peering":            true,
+		"module.legacy-vnet":                       true,
+		"azurerm_resource_group":                   false,
+		"module.network.":                          false,
+		"azurerm_resource_group.old[0]":            false,
+		"module.network[\"a\"].azurerm_subnet.old": false,
+		"azurerm_resource_group.old.extra":         false,
+		"1resource.name":                           false,
+		"":                                         false,
+	} {
+		if got := removedAddressPattern.MatchString(address); got != want {
+			t.Errorf("removedAddressPattern.MatchString(%q) = %t, want %t", address, got, want)
+		}
+	}
+}
+
+func TestValidateConfigRemovedBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		removed []models.RemovedBlock
+		wantErr string
+	}{
+		{name: "resource and module", removed: []models.RemovedBlock{{From: "azurerm_resource_group.old"}, {From: "module.legacy_vnet", Destroy: true}}},
+		{name: "instance key", removed: []models.RemovedBlock{{From: "module.vnet[0]"}}, wantErr: `removed[0].from: invalid resource or module address "module.vnet[0]"`},
+		{name: "data source", removed: []models.RemovedBlock{{From: "data.azurerm_client_config.current"}}, wantErr: "removed[0].from: invalid resource or module address"},
+		{name: "duplicate", removed: []models.RemovedBlock{{From: "module.old"}, {From: "module.old"}}, wantErr: `removed[1].from: duplicate address "module.old"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(&models.Config{Removed: tt.removed})
+			if tt.wantErr == "" {
+				if err != nil && strings.Contains(err.Error(), "removed[") {
+					t.Fatalf("ValidateConfig() error = %v, want the removed blocks accepted", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateConfig() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigTfvarsOrder(t *testing.T) {
+	config := &models.Config{
+		Variables:   map[string]models.Variable{"sku": {Type: "string"}, "tags": {Type: "map(string)"}},
+		TfvarsOrder: []string{"tags", "location", "sku", "tags"},
+	}
+	err := ValidateConfig(config)
+	for _, want := range []string{`tfvars_order: "location" is not a declared variable`, `tfvars_order: "tags" is listed more than once`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateConfig() error = %v, want one containing %q", err, want)
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), `"sku"`) {
+		t.Errorf("ValidateConfig() error = %v, want sku accepted", err)
+	}
+}
+
+func TestValidateTfvarsFilename(t *testing.T) {
+	for pattern, wantErr := range map[string]string{
+		"{{.Environment}}.{{.Name}}.tfvars": "",
+		"{{.Name}}.tfvars":                  "it must use .Name and .Environment",
+		"{{.Environment}}.tfvars":           "it must use .Name and .Environment",
+		"../{{.Name}}_{{.Environment}}":     "unsafe name",
+	} {
+		err := ValidateTfvarsFilename(pattern)
+		if wantErr == "" && err != nil {
+			t.Errorf("ValidateTfvarsFilename(%q) error: %v", pattern, err)
+		}
+		if wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)) {
+			t.Errorf("ValidateTfvarsFilename(%q) error = %v, want one containing %q", pattern, err, wantErr)
+		}
+	}
+}
+
+func TestExpressionProblems(t *testing.T) {
+	conditional := `var.env == "prod" ? 3 : 1`
+
+	tests := []struct {
+		name     string
+		variable models.Variable
+		generic  bool
+		want     string
+	}{
+		{name: "module value", variable: models.Variable{Type: "number", Value: conditional, Expression: true}},
+		{name: "not an expression", variable: models.Variable{Type: "number", Default: 1}, generic: true},
+		{
+			name:     "generic variable",
+			variable: models.Variable{Type: "number", Default: conditional, Expression: true},
+			generic:  true,
+			want:     "variables.count: expression is only supported on module variable values; variable defaults and tfvars files must be constant values",
+		},
+		{
+			name:     "module default",
+			variable: models.Variable{Type: "number", Default: conditional, Value: conditional, Expression: true},
+			want:     "variables.count.default: an expression default is not supported",
+		},
+		{
+			name:     "empty module value",
+			variable: models.Variable{Type: "number", Value: " ", Expression: true},
+			want:     "variables.count.value: an expression must be a non-empty string",
+		},
+		{
+			name:     "literal dollar",
+			variable: models.Variable{Type: "string", Value: "var.name", Expression: true, LiteralDollar: true},
+			want:     "variables.count: expression cannot be combined with literal_dollar",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expressionProblems("variables.count", tt.variable, tt.generic)
+			if tt.want == "" && len(got) != 0 {
+				t.Fatalf("expressionProblems() = %q, want none", got)
+			}
+			if tt.want != "" && (len(got) != 1 || !strings.HasPrefix(got[0], tt.want)) {
+				t.Fatalf("expressionProblems() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfigCustomerTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings models.CustomerSettings
+		wantErr  string
+	}{
+		{name: "template", settings: models.CustomerSettings{Template: "azure/main.govcloud.tf.tmpl"}},
+		{name: "template directory", settings: models.CustomerSettings{TemplateDir: "azure-govcloud"}},
+		{name: "both", settings: models.CustomerSettings{Template: "azure/main.tf.tmpl", TemplateDir: "azure-govcloud"}, wantErr: "customers.gov: template and template_dir are mutually exclusive"},
+		{name: "template outside", settings: models.CustomerSettings{Template: "../main.tf.tmpl"}, wantErr: "customers.gov.template: "},
+		{name: "absolute template directory", settings: models.CustomerSettings{TemplateDir: "/etc"}, wantErr: "customers.gov.template_dir: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(&models.Config{Customers: map[string]models.CustomerSettings{"gov": tt.settings}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateConfig() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateConfig() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateOutputPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr string
+	}{
+		{pattern: ""},
+		{pattern: "{{.Provider}}/{{.OrganisationName}}/{{.ProductName}}/{{.CustomerName}}"},
+		{pattern: "{{.OrganisationName}}//{{.CustomerName}}"},
+		{pattern: "{{.OrganisationName}}/..", wantErr: "unsafe segment"},
+		{pattern: "/{{.OrganisationName}}/{{.CustomerName}}", wantErr: "absolute path"},
+		{pattern: "{{.CustomerName}}", wantErr: "empty path"},
+		{pattern: "{{.OrganisationName}}/{{.ProductName}}", wantErr: "must use .CustomerName"},
+		{pattern: "{{.OrganisationName}", wantErr: "invalid output path pattern"},
+	}
+	for _, tt := range tests {
+		err := ValidateOutputPath(tt.pattern)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("ValidateOutputPath(%q) error: %v", tt.pattern, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ValidateOutputPath(%q) error = %v, want one containing %q", tt.pattern, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveFileReferences(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "configs")
+	for path, content := range map[string]string{
+		filepath.Join(dir, "cidrs.json"):          `["10.0.0.0/16"]`,
+		filepath.Join(dir, "nested", "tags.json"): `{"team": "web", "extra": {"$file": "cidrs.json"}}`,
+		filepath.Join(dir, "broken.json"):         `{"team":`,
+		filepath.Join(root, "secret.json"):        `"outside"`,
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "secret.json"), filepath.Join(dir, "link.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "file in the directory", file: "cidrs.json", want: []interface{}{"10.0.0.0/16"}},
+		{name: "path through a subdirectory", file: "nested/../cidrs.json", want: []interface{}{"10.0.0.0/16"}},
+		{
+			name: "nested reference is kept as written",
+			file: "nested/tags.json",
+			want: map[string]interface{}{"team": "web", "extra": map[string]interface{}{"$file": "cidrs.json"}},
+		},
+		{name: "parent directory", file: "../secret.json", wantErr: "must be a relative path inside"},
+		{name: "absolute path", file: filepath.Join(root, "secret.json"), wantErr: "must be a relative path inside"},
+		{name: "symbolic link out of the directory", file: "link.json", wantErr: "leads outside"},
+		{name: "missing file", file: "missing.json", wantErr: "error reading"},
+		{name: "invalid JSON", file: "broken.json", wantErr: "not valid JSON"},
+		{name: "empty path", file: " ", wantErr: "non-empty file path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &models.Config{
+				Variables: map[string]models.Variable{"cidrs": {Default: map[string]interface{}{"$file": tt.file}}},
+				Modules:   []models.Module{},
+			}
+			err := ResolveFileReferences(config, dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.HasPrefix(err.Error(), "variables.cidrs.default: ") {
+					t.Fatalf("ResolveFileReferences() error = %v, want one for variables.cidrs.default containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := config.Variables["cidrs"].Default; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cidrs default = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	// References nested in module variables resolve too
+	config := &models.Config{Modules: []models.Module{{ModuleName: "vnet", Variables: map[string]models.ModuleVariable{
+		"address_space": {Variable: models.Variable{Value: map[string]interface{}{"list": map[string]interface{}{"$file": "cidrs.json"}}}},
+	}}}}
+	if err := ResolveFileReferences(config, dir); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"list": []interface{}{"10.0.0.0/16"}}
+	if got := config.Modules[0].Variables["address_space"].Value; !reflect.DeepEqual(got, want) {
+		t.Errorf("address_space value = %#v, want %#v", got, want)
+	}
+}
@@ -5,181 +5,244 @@ package utils
 import (
 	"backend/models"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"text/template"
+	"time"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
 
-// CreateDirectories ensures that the specified directories exist
-func CreateDirectories(paths []string) error {
+// CreateDirectories ensures that the specified directories exist and reports each as created, or skipped when it
+// already existed. A dry run only reports them.
+func CreateDirectories(paths []string, opts GenerateOptions) ([]models.FileResult, error) {
+	var results []models.FileResult
 	for _, path := range paths {
-		if err := os.MkdirAll(path, os.ModePerm); err != nil {
-			return err
+		result := models.FileResult{Path: path, Action: models.FileCreated, Directory: true}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			result.Action = models.FileSkipped
 		}
+		if !opts.DryRun {
+			if err := os.MkdirAll(path, os.ModePerm); err != nil {
+				return results, err
+			}
+		}
+		results = append(results, result)
 	}
-	return nil
+	return results, nil
 }
 
-// WriteFile writes content to a specified path
-func WriteFile(path string, content []byte) error {
-	return os.WriteFile(path, content, 0644)
+// IsSafePathSegment reports whether name can be used as a single directory or file name
+func IsSafePathSegment(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`) && filepath.IsLocal(name)
 }
 
-// ToJSON converts a value to a JSON string
-func ToJSON(value interface{}) (string, error) {
-	jsonBytes, err := json.Marshal(value)
+// CheckDirectory returns an error naming the directory and its absolute path when dir does not exist
+func CheckDirectory(dir, description string) error {
+	absolute, err := filepath.Abs(dir)
 	if err != nil {
-		return "", err
+		absolute = dir
 	}
-	return string(jsonBytes), nil
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s directory %q not found (resolved to %s); run the generator from the directory that contains it", description, dir, absolute)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot access %s directory %s: %w", description, absolute, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s path %s is not a directory", description, absolute)
+	}
+	return nil
 }
 
-// formatValue dynamically formats values based on their types.
-func formatValue(value interface{}, varType string) string {
-	switch varType {
-	case "string":
-		// Quote the value if it's a string and not a variable reference
-		if strVal, ok := value.(string); ok {
-			if strings.HasPrefix(strVal, "var.") {
-				return strVal
-			}
-			return fmt.Sprintf("\"%s\"", strVal)
-		}
-		return "null"
+// DefaultTfvarsFilename is the per-environment tfvars file name used when none is configured.
+const DefaultTfvarsFilename = "{{.Name}}_{{.Environment}}.tfvars"
+
+// TfvarsFilename renders the tfvars filename pattern for a product or customer name and an environment
+func TfvarsFilename(pattern, name, env string) (string, error) {
+	if pattern == "" {
+		pattern = DefaultTfvarsFilename
+	}
+	tmpl, err := template.New("tfvars_filename").Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid tfvars filename pattern: %w", err)
+	}
+	var filename bytes.Buffer
+	if err := tmpl.Execute(&filename, map[string]string{"Name": name, "Environment": env}); err != nil {
+		return "", fmt.Errorf("invalid tfvars filename pattern: %w", err)
+	}
+	if !IsSafePathSegment(filename.String()) {
+		return "", fmt.Errorf("tfvars filename pattern %q produces unsafe name %q", pattern, filename.String())
+	}
+	return filename.String(), nil
+}
 
-	case "bool", "number":
-		// Render booleans and numbers as-is
-		return fmt.Sprintf("%v", value)
+// DefaultOutputPath is the output layout used when none is configured: the customer's directory, or the product's
+// when there is no customer, inside the organisation's.
+const DefaultOutputPath = "{{.OrganisationName}}/{{if .CustomerName}}{{.CustomerName}}{{else}}{{.ProductName}}{{end}}"
 
-	case "map(string)":
-		// Render map values
-		if mapVal, ok := value.(map[string]interface{}); ok {
-			if len(mapVal) == 0 {
-				return "null"
-			}
-			var entries []string
-			for key, val := range mapVal {
-				entries = append(entries, fmt.Sprintf("\"%s\" = \"%v\"", key, val))
-			}
-			return fmt.Sprintf("{ %s }", strings.Join(entries, ", "))
+// OutputPathData holds the values an output path pattern can use
+type OutputPathData struct {
+	Provider         string // Terraform provider name, e.g. azurerm
+	OrganisationName string
+	ProductName      string
+	CustomerName     string // Empty for product directories
+}
+
+// OutputPath renders the output path pattern to a path relative to the output root. Empty segments are dropped, so a
+// pattern may end with {{.CustomerName}}; every other segment must be path safe, and the path cannot be absolute.
+func OutputPath(pattern string, data OutputPathData) (string, error) {
+	if pattern == "" {
+		pattern = DefaultOutputPath
+	}
+	tmpl, err := template.New("output_path").Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid output path pattern: %w", err)
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("invalid output path pattern: %w", err)
+	}
+
+	if strings.HasPrefix(rendered.String(), "/") {
+		return "", fmt.Errorf("output path pattern %q renders the absolute path %q; it is relative to the output directory", pattern, rendered.String())
+	}
+	var segments []string
+	for _, segment := range strings.Split(rendered.String(), "/") {
+		if segment == "" {
+			continue
 		}
-		return "null"
-
-	case "list(string)", "set(string)":
-		// Render lists or sets
-		if listVal, ok := value.([]interface{}); ok {
-			var items []string
-			for _, item := range listVal {
-				items = append(items, fmt.Sprintf("\"%v\"", item))
-			}
-			if varType == "set(string)" {
-				return fmt.Sprintf("toset([%s])", strings.Join(items, ", "))
-			}
-			return fmt.Sprintf("[%s]", strings.Join(items, ", "))
+		if !IsSafePathSegment(segment) {
+			return "", fmt.Errorf("output path pattern %q produces unsafe segment %q", pattern, segment)
 		}
-		return "[]"
-
-	case "object", "tuple":
-		// Handle objects or tuples
-		if mapVal, ok := value.(map[string]interface{}); ok {
-			var entries []string
-			for key, val := range mapVal {
-				entries = append(entries, fmt.Sprintf("\"%s\" = %v", key, val))
-			}
-			return fmt.Sprintf("{ %s }", strings.Join(entries, ", "))
+		segments = append(segments, segment)
+	}
+	return filepath.Join(segments...), nil
+}
+
+// WriteFile writes content to a specified path
+func WriteFile(path string, content []byte) error {
+	return os.WriteFile(path, content, 0644)
+}
+
+// GenerateOptions controls how generated files are written
+type GenerateOptions struct {
+	Overwrite bool          // Replace files that already exist
+	Strict    bool          // Fail when a template references a missing key
+	Partials  string        // Directory of shared *.tmpl snippets parsed into every template; empty disables
+	Protected []string      // File name patterns that are never rewritten once they exist, even with Overwrite
+	Timeout   time.Duration // Longest a single template may take to render; 0 means no limit. See executeTemplate
+	DryRun    bool          // Report and hash files as they would be written without touching the file system
+}
+
+// DefaultProtectedFiles are always protected: Terraform override files hold hand-maintained customisations.
+var DefaultProtectedFiles = []string{"*.override.tf"}
+
+// IsProtected reports whether the base name of path matches one of the protected patterns
+func IsProtected(path string, patterns []string) bool {
+	name := filepath.Base(path)
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
 		}
-		return "{}"
+	}
+	return false
+}
 
-	default:
-		// Default fallback for unknown types
-		return fmt.Sprintf("%v", value)
+// CopyFileWithResult copies the file at source to destinationPath, creating its directory, and reports the result
+// like WriteFileWithResult
+func CopyFileWithResult(source, destinationPath string, opts GenerateOptions) (models.FileResult, error) {
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return models.FileResult{}, err
+	}
+	if err := ensureDir(destinationPath, opts); err != nil {
+		return models.FileResult{}, err
 	}
+	return WriteFileWithResult(destinationPath, content, opts)
 }
 
-// FormatDefault formats the default value of a variable
-func FormatDefault(varDef models.Variable) string {
-	switch varDef.Type {
-	case "bool", "number":
-		return fmt.Sprintf("%v", varDef.Default)
-	case "string":
-		// Check if default is an expression
-		if expr, ok := varDef.Default.(string); ok && strings.HasPrefix(expr, "var.") {
-			return expr // Expression
-		}
-		return fmt.Sprintf("\"%v\"", varDef.Default)
-	case "list(string)", "set(string)":
-		list, ok := varDef.Default.([]interface{})
-		if !ok {
-			return "[]"
-		}
-		var items []string
-		for _, item := range list {
-			items = append(items, fmt.Sprintf("\"%v\"", item))
-		}
-		if varDef.Type == "set(string)" {
-			return fmt.Sprintf("toset([%s])", strings.Join(items, ", "))
-		}
-		return fmt.Sprintf("[%s]", strings.Join(items, ", "))
-	case "map(string)":
-		var entries []string
-		switch v := varDef.Default.(type) {
-		case map[string]interface{}:
-			for key, val := range v {
-				entries = append(entries, fmt.Sprintf("\"%s\" = \"%v\"", key, val))
-			}
-		case map[string]string:
-			for key, val := range v {
-				entries = append(entries, fmt.Sprintf("\"%s\" = \"%s\"", key, val))
+// ensureDir creates the directory path is written to, unless this is a dry run
+func ensureDir(path string, opts GenerateOptions) error {
+	if opts.DryRun {
+		return nil
+	}
+	return os.MkdirAll(filepath.Dir(path), os.ModePerm)
+}
+
+// WriteFileWithResult writes content to path and reports whether the file was created, overwritten, skipped or
+// left alone because it is protected. A dry run reports the same without writing.
+func WriteFileWithResult(path string, content []byte, opts GenerateOptions) (models.FileResult, error) {
+	result := models.FileResult{Path: path, Action: models.FileCreated, Bytes: len(content)}
+
+	if _, err := os.Stat(path); err == nil {
+		protected := IsProtected(path, opts.Protected)
+		if protected || !opts.Overwrite {
+			result.Action = models.FileSkipped
+			if protected {
+				result.Action = models.FileProtected
 			}
-		}
-		return fmt.Sprintf("{ %s }", strings.Join(entries, ", "))
-	case "object({ provision_vm_agent = bool, enable_automatic_upgrades = bool })",
-		"object({ publisher = string, offer = string, sku = string, version = string })",
-		"object({ name = string, caching = string, create_option = string, managed_disk_type = string })":
-		// Assume default is a map[string]interface{}
-		objMap, ok := varDef.Default.(map[string]interface{})
-		if !ok {
-			return "{}"
-		}
-		var items []string
-		for key, val := range objMap {
-			switch v := val.(type) {
-			case string:
-				items = append(items, fmt.Sprintf("\"%s\" = \"%v\"", key, v))
-			default:
-				items = append(items, fmt.Sprintf("\"%s\" = %v", key, v))
+			result.Bytes = 0
+			existing, err := os.ReadFile(path)
+			if err != nil {
+				return result, err
 			}
+			result.SHA256 = checksum(existing)
+			return result, nil
 		}
-		return fmt.Sprintf("{ %s }", strings.Join(items, ", "))
-	case "tuple":
-		tuple, ok := varDef.Default.([]interface{})
-		if !ok {
-			return "[]"
-		}
-		var items []string
-		for _, item := range tuple {
-			switch v := item.(type) {
-			case string:
-				items = append(items, fmt.Sprintf("\"%v\"", v))
-			default:
-				items = append(items, fmt.Sprintf("%v", v))
-			}
+		result.Action = models.FileOverwritten
+	} else if !os.IsNotExist(err) {
+		return result, err
+	}
+
+	if !opts.DryRun {
+		if err := WriteFile(path, content); err != nil {
+			return result, err
 		}
-		return fmt.Sprintf("[%s]", strings.Join(items, ", "))
-	default:
-		return fmt.Sprintf("%v", varDef.Default)
 	}
+	result.SHA256 = checksum(content)
+	return result, nil
+}
+
+// checksum returns the hex SHA-256 of content
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
 }
 
-// GenerateFileFromTemplate generates a file from a template
-func GenerateFileFromTemplate(templatePath, destinationPath string, data interface{}) error {
-	funcMap := template.FuncMap{
+// ToJSON converts a value to a JSON string
+func ToJSON(value interface{}) (string, error) {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// GenerateFileFromTemplate generates a file from a template and reports what was written
+func GenerateFileFromTemplate(templatePath, destinationPath string, data interface{}, opts GenerateOptions) (models.FileResult, error) {
+	return GenerateFileFromTemplateSet([]string{templatePath}, destinationPath, data, opts)
+}
+
+// ShellQuote quotes value as a single POSIX shell word
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// templateFuncs returns the functions available to every template
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
 		"title": cases.Title(language.Und).String,
 		"add":   func(a, b int) int { return a + b },
 		"toJSON": func(value interface{}) string {
@@ -213,67 +276,166 @@ func GenerateFileFromTemplate(templatePath, destinationPath string, data interfa
 			}
 			return b
 		},
-		"formatValue": func(value interface{}, varType string) string {
-			switch varType {
-			case "bool", "number":
-				return fmt.Sprintf("%v", value)
-			case "string":
-				expr, ok := value.(string)
-				if ok && strings.HasPrefix(expr, "var.") {
-					return expr
-				}
-				return fmt.Sprintf("\"%v\"", value)
-			case "list(string)", "set(string)":
-				list, ok := value.([]interface{})
-				if !ok {
-					return "[]"
-				}
-				var items []string
-				for _, item := range list {
-					items = append(items, fmt.Sprintf("\"%v\"", item))
-				}
-				if varType == "set(string)" {
-					return fmt.Sprintf("toset([%s])", strings.Join(items, ", "))
-				}
-				return fmt.Sprintf("[%s]", strings.Join(items, ", "))
-			case "map(string)":
-				var entries []string
-				switch v := value.(type) {
-				case map[string]interface{}:
-					for key, val := range v {
-						entries = append(entries, fmt.Sprintf("\"%s\" = \"%v\"", key, val))
-					}
-				case map[string]string:
-					for key, val := range v {
-						entries = append(entries, fmt.Sprintf("\"%s\" = \"%s\"", key, val))
-					}
-				}
-				return fmt.Sprintf("{ %s }", strings.Join(entries, ", "))
-			default:
-				return fmt.Sprintf("%v", value)
+		"formatValue":         formatValue,
+		"hclValue":            func(value interface{}) string { return formatValue(value, "") },
+		"literal":             literalString,
+		"quote":               quoteString,
+		"formatVariableValue": FormatVariableValue,
+		"nestedValue":         FormatNestedValue,
+		"escapeLiteral": func(varDef models.Variable, hcl string) string {
+			if varDef.LiteralDollar {
+				return escapeTemplate(hcl)
 			}
+			return hcl
 		},
-		"formatDefault": FormatDefault, // Existing functions
-		"formatType":    formatType,    // Existing functions
+		"tfvarsString":   tfvarsString,
+		"lifecycle":      RenderLifecycle,
+		"features":       RenderFeatures,
+		"comment":        Comment,
+		"groupVariables": GroupVariables,
+		"orderVariables": OrderVariables,
+		"shellQuote":     ShellQuote,
+		"join":           strings.Join,
+		"resource":       RenderResource,
+		"linkedProvider": RenderLinkedProvider,
+		"heredoc":        Heredoc,
+		"tagsMap":        TagsMap,
+		"tagsList":       TagsList,
+		"varRef":         VarRef,
+		"localRef":       LocalRef,
+		"moduleRef":      ModuleRef,
+		"formatDefault":  FormatDefault, // Existing functions
+		"formatType":     formatType,    // Existing functions
 	}
+}
 
-	// Parse the template with the function map
-	tmpl, err := template.New(filepath.Base(templatePath)).Funcs(funcMap).ParseFiles(templatePath)
+// GenerateFileFromTemplateSet parses templatePaths together and executes the first of them, so later files can
+// {{define}} the blocks it uses. A later file with the same base name as an earlier one replaces it.
+func GenerateFileFromTemplateSet(templatePaths []string, destinationPath string, data interface{}, opts GenerateOptions) (models.FileResult, error) {
+	if len(templatePaths) == 0 {
+		return models.FileResult{}, fmt.Errorf("no template given for %s", destinationPath)
+	}
+
+	output, err := renderTemplateSet(templatePaths, data, opts)
 	if err != nil {
-		return err
+		return models.FileResult{}, err
+	}
+	return writeRendered(destinationPath, output, opts)
+}
+
+// GenerateFileFromTemplateSections renders each template set in turn into one file, separated by a blank line, and
+// reports what was written. Sections that render only whitespace are left out.
+func GenerateFileFromTemplateSections(sections [][]string, destinationPath string, data interface{}, opts GenerateOptions) (models.FileResult, error) {
+	var rendered []string
+	for _, templatePaths := range sections {
+		if len(templatePaths) == 0 {
+			return models.FileResult{}, fmt.Errorf("no template given for a section of %s", destinationPath)
+		}
+		output, err := renderTemplateSet(templatePaths, data, opts)
+		if err != nil {
+			return models.FileResult{}, err
+		}
+		if section := strings.Trim(string(output), "\n"); strings.TrimSpace(section) != "" {
+			rendered = append(rendered, section)
+		}
+	}
+	return writeRendered(destinationPath, []byte(strings.Join(rendered, "\n\n")+"\n"), opts)
+}
+
+// writeRendered writes rendered template output to destinationPath, creating its directory
+func writeRendered(destinationPath string, output []byte, opts GenerateOptions) (models.FileResult, error) {
+	if err := ensureDir(destinationPath, opts); err != nil {
+		return models.FileResult{}, err
 	}
+	return WriteFileWithResult(destinationPath, output, opts)
+}
+
+// renderTemplateSet parses the templates, after the shared partials, and executes the first of them.
+func renderTemplateSet(templatePaths []string, data interface{}, opts GenerateOptions) ([]byte, error) {
+	entry := filepath.Base(templatePaths[0])
 
-	// Ensure the destination directory exists
-	destDir := filepath.Dir(destinationPath)
-	if err := os.MkdirAll(destDir, os.ModePerm); err != nil {
-		return err
+	// Shared partials are parsed first so the templates themselves can override what they define
+	tmpl := template.New(entry).Funcs(templateFuncs())
+	if opts.Partials != "" {
+		partials, err := filepath.Glob(filepath.Join(opts.Partials, "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		if len(partials) > 0 {
+			if tmpl, err = tmpl.ParseFiles(partials...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Parse the templates with the function map
+	tmpl, err := tmpl.ParseFiles(templatePaths...)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Strict {
+		tmpl.Option("missingkey=error")
 	}
 
 	// Execute the template
-	var outputBuffer bytes.Buffer
-	if err := tmpl.Execute(&outputBuffer, data); err != nil {
-		return err
+	output, err := executeTemplate(tmpl, entry, data, opts.Timeout)
+	if errors.Is(err, ErrRenderTimeout) {
+		return nil, fmt.Errorf("template %s: %w", templatePaths[0], err)
+	}
+	return output, err
+}
+
+// ErrRenderTimeout is returned when a template takes longer than GenerateOptions.Timeout to render.
+var ErrRenderTimeout = errors.New("rendering timed out")
+
+// abandonedRenders counts renders that timed out and are still running, see AbandonedRenders
+var abandonedRenders atomic.Int64
+
+// AbandonedRenders returns the number of renders that timed out but have not finished yet.
+func AbandonedRenders() int64 {
+	return abandonedRenders.Load()
+}
+
+// executeTemplate executes the named template, giving up once timeout has passed when it is positive. A template
+// cannot be interrupted, so one that overruns is stopped at its next write and otherwise left to finish: a template
+// that loops without writing keeps its goroutine, and the CPU it uses, until the loop ends. Such renders are logged
+// and counted by AbandonedRenders until they finish.
+func executeTemplate(tmpl *template.Template, name string, data interface{}, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		var output bytes.Buffer
+		err := tmpl.ExecuteTemplate(&output, name, data)
+		return output.Bytes(), err
 	}
 
-	return WriteFile(destinationPath, outputBuffer.Bytes())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	output := &contextWriter{ctx: ctx}
+	done := make(chan error, 1)
+	go func() { done <- tmpl.ExecuteTemplate(output, name, data) }()
+
+	select {
+	case err := <-done:
+		return output.buffer.Bytes(), err
+	case <-ctx.Done():
+		log.Printf("warning: template %s timed out after %s and is left running; %d abandoned renders", name, timeout, abandonedRenders.Add(1))
+		go func() {
+			<-done
+			abandonedRenders.Add(-1)
+			log.Printf("abandoned render of template %s finished", name)
+		}()
+		return nil, fmt.Errorf("%w after %s", ErrRenderTimeout, timeout)
+	}
+}
+
+// contextWriter buffers output until its context is done, then fails every write
+type contextWriter struct {
+	ctx    context.Context
+	buffer bytes.Buffer
+}
+
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.buffer.Write(p)
 }
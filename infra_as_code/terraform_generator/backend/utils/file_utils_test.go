@@ -0,0 +1,177 @@
+// backend/utils/file_utils_test.go
+
+package utils
+
+import (
+	"backend/models"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateFileFromTemplateTimeout(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "endless.tmpl")
+	if err := os.WriteFile(templatePath, []byte("{{ range .Items }}{{ . }}{{ end }}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A channel ranges until it is closed, which the producer only does once the test is over
+	items := make(chan int)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(items)
+		for i := 0; ; i++ {
+			select {
+			case items <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	dest := filepath.Join(dir, "out.txt")
+	_, err := GenerateFileFromTemplate(templatePath, dest, map[string]interface{}{"Items": items}, GenerateOptions{Overwrite: true, Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, ErrRenderTimeout) {
+		t.Fatalf("GenerateFileFromTemplate() error = %v, want %v", err, ErrRenderTimeout)
+	}
+	if !strings.Contains(err.Error(), templatePath) {
+		t.Fatalf("GenerateFileFromTemplate() error = %q, want it to name %s", err, templatePath)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("GenerateFileFromTemplate() wrote %s after timing out", dest)
+	}
+
+	// The abandoned render fails at its next write and is no longer counted once it has finished
+	for deadline := time.Now().Add(time.Second); AbandonedRenders() != 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("AbandonedRenders() = %d after the render stopped, want 0", AbandonedRenders())
+		}
+	}
+
+	result, err := GenerateFileFromTemplate(templatePath, dest, map[string]interface{}{"Items": []int{1, 2}}, GenerateOptions{Overwrite: true, Timeout: time.Second})
+	if err != nil || result.Bytes != 2 {
+		t.Fatalf("GenerateFileFromTemplate() = %+v, %v, want 2 bytes written", result, err)
+	}
+}
+
+func TestCreateDirectories(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "backend")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "vars", "nested")
+
+	results, err := CreateDirectories([]string{existing, created}, GenerateOptions{})
+	if err != nil {
+		t.Fatalf("CreateDirectories() error: %v", err)
+	}
+	want := []models.FileResult{
+		{Path: existing, Action: models.FileSkipped, Directory: true},
+		{Path: created, Action: models.FileCreated, Directory: true},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Fatalf("CreateDirectories() = %+v, want %+v", results, want)
+	}
+	if info, err := os.Stat(created); err != nil || !info.IsDir() {
+		t.Fatalf("%s was not created: %v", created, err)
+	}
+
+	// A second run finds every directory in place
+	results, err = CreateDirectories([]string{created}, GenerateOptions{})
+	if err != nil || len(results) != 1 || results[0].Action != models.FileSkipped {
+		t.Fatalf("CreateDirectories() again = %+v, %v; want skipped", results, err)
+	}
+}
+
+func TestGenerateFileFromTemplateSections(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"providers.tmpl": "provider {}\n\n", "cloud.tmpl": "{{ if .Cloud }}cloud {}{{ end }}\n", "main.tmpl": "\nmodule {}"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var sections [][]string
+	for _, name := range []string{"providers.tmpl", "cloud.tmpl", "main.tmpl"} {
+		sections = append(sections, []string{filepath.Join(dir, name)})
+	}
+
+	dest := filepath.Join(dir, "main.tf")
+	if _, err := GenerateFileFromTemplateSections(sections, dest, map[string]interface{}{"Cloud": false}, GenerateOptions{Overwrite: true}); err != nil {
+		t.Fatalf("GenerateFileFromTemplateSections() error: %v", err)
+	}
+	content, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The empty cloud section is dropped rather than leaving a blank gap
+	if want := "provider {}\n\nmodule {}\n"; string(content) != want {
+		t.Fatalf("GenerateFileFromTemplateSections() wrote %q, want %q", content, want)
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	data := OutputPathData{Provider: "azurerm", OrganisationName: "acme", ProductName: "web"}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		customer string
+		want     string
+		wantErr  string
+	}{
+		{name: "default product", pattern: "", want: filepath.Join("acme", "web")},
+		{name: "default customer", pattern: "", customer: "c1", want: filepath.Join("acme", "c1")},
+		{name: "missing customer is dropped", pattern: "{{.OrganisationName}}/{{.ProductName}}/{{.CustomerName}}", want: filepath.Join("acme", "web")},
+		{name: "empty segment is dropped", pattern: "{{.Provider}}//{{.OrganisationName}}", want: filepath.Join("azurerm", "acme")},
+		{name: "parent directory", pattern: "{{.OrganisationName}}/../{{.ProductName}}", wantErr: `unsafe segment ".."`},
+		{name: "parent directory from a name", pattern: "{{.OrganisationName}}/{{.CustomerName}}", customer: "..", wantErr: `unsafe segment ".."`},
+		{name: "absolute pattern", pattern: "/srv/{{.OrganisationName}}", wantErr: "absolute path"},
+		{name: "unknown field", pattern: "{{.Customer}}", wantErr: "invalid output path pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := data
+			data.CustomerName = tt.customer
+			got, err := OutputPath(tt.pattern, data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("OutputPath(%q) error = %v, want one containing %q", tt.pattern, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("OutputPath(%q) = %q, %v; want %q", tt.pattern, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTfvarsFilename(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{pattern: "", want: "c1_prod.tfvars"},
+		{pattern: "{{.Environment}}.{{.Name}}.tfvars", want: "prod.c1.tfvars"},
+		{pattern: "{{.Name}}-{{.Environment | printf \"%.2s\"}}.tfvars", want: "c1-pr.tfvars"},
+		{pattern: "{{.Environment}}/{{.Name}}.tfvars", wantErr: true},
+		{pattern: "{{.Name}}_{{.Region}}.tfvars", wantErr: true},
+		{pattern: "{{.Name", wantErr: true},
+		{pattern: "{{if false}}x{{end}}", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := TfvarsFilename(tt.pattern, "c1", "prod")
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("TfvarsFilename(%q) = %q, %v; want %q, error %t", tt.pattern, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
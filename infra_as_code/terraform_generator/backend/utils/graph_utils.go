@@ -0,0 +1,96 @@
+// backend/utils/graph_utils.go
+
+package utils
+
+import (
+	"backend/models"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// BuildModuleGraph links each module call to the modules it lists in depends_on and the modules whose outputs its
+// input values and defaults reference, e.g. module.vnet.id. Nodes keep the order of modules; a referenced module
+// that is not among them is added as missing.
+func BuildModuleGraph(modules []models.Module) models.ModuleGraph {
+	graph := models.ModuleGraph{Nodes: []models.ModuleGraphNode{}, Edges: []models.ModuleGraphEdge{}}
+	known := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		graph.Nodes = append(graph.Nodes, models.ModuleGraphNode{Label: module.BlockLabel(), Module: module.ModuleName, Source: module.Source})
+		known[module.BlockLabel()] = true
+	}
+
+	for _, module := range modules {
+		from := module.BlockLabel()
+		edges := make(map[string]*models.ModuleGraphEdge)
+		var targets []string
+		edge := func(to string) *models.ModuleGraphEdge {
+			if edges[to] == nil {
+				edges[to] = &models.ModuleGraphEdge{From: from, To: to}
+				targets = append(targets, to)
+			}
+			return edges[to]
+		}
+
+		for _, dependency := range module.DependsOn {
+			edge(dependency).DependsOn = true
+		}
+		for _, name := range sortedKeys(module.Variables) {
+			variable := module.Variables[name]
+			for _, reference := range append(ModuleReferences(variable.Value), ModuleReferences(variable.Default)...) {
+				if reference.Label == from {
+					continue
+				}
+				target := edge(reference.Label)
+				if !slices.Contains(target.Outputs, reference.Output) {
+					target.Outputs = append(target.Outputs, reference.Output)
+				}
+			}
+		}
+
+		for _, to := range targets {
+			slices.Sort(edges[to].Outputs)
+			graph.Edges = append(graph.Edges, *edges[to])
+			if !known[to] {
+				graph.Nodes = append(graph.Nodes, models.ModuleGraphNode{Label: to, Missing: true})
+				known[to] = true
+			}
+		}
+	}
+	return graph
+}
+
+// dotEscaper escapes text for a DOT quoted string, keeping newlines as line breaks in labels.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// dotString renders s as a DOT quoted string
+func dotString(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+// ModuleGraphDOT renders a module graph in Graphviz DOT. Edges are labelled with the outputs they carry; edges from
+// depends_on alone are dashed and missing modules are drawn in red.
+func ModuleGraphDOT(graph models.ModuleGraph) string {
+	var b strings.Builder
+	b.WriteString("digraph modules {\n")
+	b.WriteString("  rankdir = \"LR\";\n")
+	b.WriteString("  node [shape = \"box\"];\n")
+	for _, node := range graph.Nodes {
+		if node.Missing {
+			fmt.Fprintf(&b, "  %s [label = %s, color = \"red\", style = \"dashed\"];\n", dotString(node.Label), dotString(node.Label+"\n(not generated)"))
+			continue
+		}
+		fmt.Fprintf(&b, "  %s [label = %s];\n", dotString(node.Label), dotString(node.Label+"\n"+node.Source))
+	}
+	for _, edge := range graph.Edges {
+		var attributes []string
+		if len(edge.Outputs) > 0 {
+			attributes = append(attributes, "label = "+dotString(strings.Join(edge.Outputs, ", ")))
+		} else {
+			attributes = append(attributes, `style = "dashed"`)
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", dotString(edge.From), dotString(edge.To), strings.Join(attributes, ", "))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,44 @@
+// backend/utils/graph_utils_test.go
+
+package utils
+
+import (
+	"backend/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildModuleGraph(t *testing.T) {
+	modules := []models.Module{
+		{ModuleName: "resource_group", Source: "./modules/resource_group"},
+		{ModuleName: "vnet", Label: "network", Source: "./modules/vnet", DependsOn: []string{"resource_group"}, Variables: map[string]models.ModuleVariable{
+			"resource_group_name": {Variable: models.Variable{Value: "module.resource_group.name"}},
+			"location":            {Variable: models.Variable{Value: "${module.resource_group.location}"}},
+			"dns_servers":         {Variable: models.Variable{Default: []interface{}{"module.dns.ip"}}},
+		}},
+	}
+
+	graph := BuildModuleGraph(modules)
+	wantEdges := []models.ModuleGraphEdge{
+		{From: "network", To: "resource_group", DependsOn: true, Outputs: []string{"location", "name"}},
+		{From: "network", To: "dns", Outputs: []string{"ip"}},
+	}
+	if !reflect.DeepEqual(graph.Edges, wantEdges) {
+		t.Fatalf("BuildModuleGraph() edges = %+v, want %+v", graph.Edges, wantEdges)
+	}
+	if len(graph.Nodes) != 3 || graph.Nodes[1].Label != "network" || !graph.Nodes[2].Missing {
+		t.Fatalf("BuildModuleGraph() nodes = %+v, want the two modules and a missing dns", graph.Nodes)
+	}
+
+	dot := ModuleGraphDOT(graph)
+	for _, want := range []string{
+		`"network" [label = "network\n./modules/vnet"];`,
+		`"network" -> "resource_group" [label = "location, name"];`,
+		`"dns" [label = "dns\n(not generated)", color = "red", style = "dashed"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("ModuleGraphDOT() missing %s in:\n%s", want, dot)
+		}
+	}
+}
@@ -0,0 +1,649 @@
+// backend/utils/hcl_utils.go
+
+package utils
+
+import (
+	"backend/models"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// referencePattern matches variable references such as var.location or var.settings.name that are emitted unquoted.
+var referencePattern = regexp.MustCompile(`^var\.[A-Za-z_][A-Za-z0-9_-]*(\.[A-Za-z_][A-Za-z0-9_-]*|\[[0-9]+\])*$`)
+
+// traversalPattern matches any attribute traversal such as azurerm_resource_group.main.name.
+var traversalPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*(\.[A-Za-z_][A-Za-z0-9_-]*|\[[0-9]+\])+$`)
+
+// moduleOutputPattern finds module output references such as module.eks.endpoint or module.eks[0].endpoint, on their
+// own or inside an interpolation.
+var moduleOutputPattern = regexp.MustCompile(`\bmodule\.([A-Za-z_][A-Za-z0-9_-]*)(\[[0-9]+\])?\.([A-Za-z_][A-Za-z0-9_-]*)`)
+
+// isReference reports whether value is a variable reference rather than a literal string
+func isReference(value string) bool {
+	return referencePattern.MatchString(value)
+}
+
+// namedReference joins a prefix such as "var" with names that must each be a valid HCL identifier
+func namedReference(prefix string, names ...string) (string, error) {
+	for _, name := range names {
+		if !identifierPattern.MatchString(name) {
+			return "", fmt.Errorf("invalid %s reference %q: not a valid identifier", prefix, name)
+		}
+	}
+	return prefix + "." + strings.Join(names, "."), nil
+}
+
+// VarRef returns the reference var.<name>
+func VarRef(name string) (string, error) {
+	return namedReference("var", name)
+}
+
+// LocalRef returns the reference local.<name>
+func LocalRef(name string) (string, error) {
+	return namedReference("local", name)
+}
+
+// ModuleRef returns the reference module.<label>, followed by any output and attribute names, e.g. module.vnet.id
+func ModuleRef(label string, attributes ...string) (string, error) {
+	return namedReference("module", append([]string{label}, attributes...)...)
+}
+
+// quoteString renders s as an HCL quoted string. Interpolation sequences are passed through
+// so values such as "${var.name}-suffix" keep working.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// literalString renders s as an HCL quoted string with interpolation and template directives escaped, for settings
+// such as the cloud block's that Terraform reads as plain text
+func literalString(s string) string {
+	return quoteString(escapeTemplate(s))
+}
+
+// FormatConstantValue renders a variable's value as the constant its tfvars entry holds, for passing the value as a
+// module argument instead
+func FormatConstantValue(varDef models.Variable) string {
+	formatted := hclLiteral(coerceValue(varDef.Value, varDef.Type))
+	if varDef.LiteralDollar {
+		return escapeTemplate(formatted)
+	}
+	return formatted
+}
+
+// escapeTemplate escapes the "${" and "%{" template sequences so Terraform keeps them as text
+func escapeTemplate(s string) string {
+	return strings.ReplaceAll(EscapeInterpolation(s), "%{", "%%{")
+}
+
+// tfvarsString renders a scalar tfvars value as a quoted string, kept as text for a literal_dollar variable
+func tfvarsString(varDef models.Variable, value interface{}) string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		text = v
+	case float64:
+		text = formatNumber(v)
+	default:
+		text = fmt.Sprintf("%v", v)
+	}
+	if varDef.LiteralDollar {
+		return literalString(text)
+	}
+	return quoteString(text)
+}
+
+// formatNumber renders a decoded JSON number without exponent notation for ordinary integers
+func formatNumber(n float64) string {
+	if n == math.Trunc(n) && math.Abs(n) < 1e15 {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of m in sorted order so rendered maps are deterministic
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hclLiteral renders any decoded JSON value as an HCL literal, recursing into lists and maps
+func hclLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return quoteString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "null"
+		}
+		return formatNumber(v)
+	case float32:
+		return hclLiteral(float64(v))
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, hclLiteral(item))
+		}
+		return fmt.Sprintf("[%s]", strings.Join(items, ", "))
+	case []string:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, quoteString(item))
+		}
+		return fmt.Sprintf("[%s]", strings.Join(items, ", "))
+	case map[string]interface{}:
+		entries := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			entries = append(entries, fmt.Sprintf("%s = %s", quoteString(key), hclLiteral(v[key])))
+		}
+		return fmt.Sprintf("{ %s }", strings.Join(entries, ", "))
+	case map[string]string:
+		entries := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			entries = append(entries, fmt.Sprintf("%s = %s", quoteString(key), quoteString(v[key])))
+		}
+		return fmt.Sprintf("{ %s }", strings.Join(entries, ", "))
+	default:
+		// Maps and slices of other element types, e.g. map[string]map[string]string, are rendered recursively
+		// rather than in Go's map[...] form
+		if generic, ok := genericValue(v); ok {
+			return hclLiteral(generic)
+		}
+		return quoteString(fmt.Sprintf("%v", v))
+	}
+}
+
+// genericValue converts a map with string keys or a slice of any element type to map[string]interface{} or
+// []interface{}, reporting false for anything else
+func genericValue(value interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		generic := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			generic[iter.Key().String()] = iter.Value().Interface()
+		}
+		return generic, true
+	case reflect.Slice, reflect.Array:
+		generic := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			generic = append(generic, v.Index(i).Interface())
+		}
+		return generic, true
+	default:
+		return nil, false
+	}
+}
+
+// FormatNestedValue renders value as an HCL literal with one map entry per line, indenting each level below indent
+// so nested maps such as map(map(string)) stay readable in tfvars files. Lists and scalars render inline.
+func FormatNestedValue(value interface{}, indent string) string {
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		generic, _ := genericValue(value)
+		if entries, ok = generic.(map[string]interface{}); !ok {
+			return hclLiteral(value)
+		}
+	}
+	if len(entries) == 0 {
+		return "{}"
+	}
+
+	lines := make([]string, 0, len(entries))
+	for _, key := range sortedKeys(entries) {
+		name := key
+		if !identifierPattern.MatchString(key) {
+			name = quoteString(key)
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s = %s", indent, name, FormatNestedValue(entries[key], indent+"  ")))
+	}
+	return fmt.Sprintf("{\n%s\n%s}", strings.Join(lines, "\n"), indent)
+}
+
+// stringLiteral renders a scalar as a quoted string and anything nested as an HCL literal
+func stringLiteral(value interface{}) string {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}, map[string]string:
+		return hclLiteral(value)
+	case nil:
+		return "null"
+	default:
+		if generic, ok := genericValue(value); ok {
+			return hclLiteral(generic)
+		}
+		return quoteString(fmt.Sprintf("%v", value))
+	}
+}
+
+// scalarLiteral renders a bool or number, passing references through and quoting anything else
+func scalarLiteral(value interface{}) string {
+	if expr, ok := value.(string); ok && isReference(expr) {
+		return expr
+	}
+	return hclLiteral(value)
+}
+
+// formatStringList renders a list of strings, wrapping it in toset() for sets
+func formatStringList(value interface{}, varType string) string {
+	list, ok := value.([]interface{})
+	if !ok {
+		return "[]"
+	}
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		items = append(items, stringLiteral(item))
+	}
+	if varType == "set(string)" {
+		return fmt.Sprintf("toset([%s])", strings.Join(items, ", "))
+	}
+	return fmt.Sprintf("[%s]", strings.Join(items, ", "))
+}
+
+// formatStringMap renders a map of strings with its keys in sorted order
+func formatStringMap(value interface{}) string {
+	var entries []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			entries = append(entries, fmt.Sprintf("%s = %s", quoteString(key), stringLiteral(v[key])))
+		}
+	case map[string]string:
+		for _, key := range sortedKeys(v) {
+			entries = append(entries, fmt.Sprintf("%s = %s", quoteString(key), quoteString(v[key])))
+		}
+	}
+	if len(entries) == 0 {
+		return "{}"
+	}
+	return fmt.Sprintf("{ %s }", strings.Join(entries, ", "))
+}
+
+// TagsMap renders tags as an HCL map for a tags argument, e.g. { "Team" = "web" }
+func TagsMap(tags interface{}) (string, error) {
+	values, err := tagValues(tags)
+	if err != nil {
+		return "", err
+	}
+	return formatStringMap(values), nil
+}
+
+// TagsList renders tags as a list of tag objects, as an autoscaling group expects, e.g.
+// [{ key = "Team", value = "web", propagate_at_launch = true }]. Tags propagate at launch unless false is passed.
+func TagsList(tags interface{}, propagateAtLaunch ...bool) (string, error) {
+	values, err := tagValues(tags)
+	if err != nil {
+		return "", err
+	}
+	propagate := len(propagateAtLaunch) == 0 || propagateAtLaunch[0]
+	items := make([]string, 0, len(values))
+	for _, key := range sortedKeys(values) {
+		items = append(items, fmt.Sprintf("{ key = %s, value = %s, propagate_at_launch = %t }", quoteString(key), quoteString(values[key]), propagate))
+	}
+	return fmt.Sprintf("[%s]", strings.Join(items, ", ")), nil
+}
+
+// tagValues converts a tag map from the template data, such as default tags or a map variable, to strings
+func tagValues(tags interface{}) (map[string]string, error) {
+	switch v := tags.(type) {
+	case nil:
+		return map[string]string{}, nil
+	case map[string]string:
+		return v, nil
+	case map[string]interface{}:
+		values := make(map[string]string, len(v))
+		for key, value := range v {
+			switch value := value.(type) {
+			case string:
+				values[key] = value
+			case bool, int:
+				values[key] = fmt.Sprintf("%v", value)
+			case float64:
+				values[key] = formatNumber(value)
+			default:
+				return nil, fmt.Errorf("tag %q: value %v is not a string", key, value)
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("tags must be a map of strings, got %T", tags)
+	}
+}
+
+// formatValue formats a module input value for use in a module block
+func formatValue(value interface{}, varType string) string {
+	switch varType {
+	case "bool", "number":
+		return scalarLiteral(value)
+	case "string":
+		expr, ok := value.(string)
+		if ok && isReference(expr) {
+			return expr
+		}
+		return stringLiteral(value)
+	case "list(string)", "set(string)":
+		return formatStringList(coerceValue(value, varType), varType)
+	case "map(string)":
+		return formatStringMap(value)
+	default:
+		// Untyped inputs may reference other resources or modules directly
+		if expr, ok := value.(string); ok && traversalPattern.MatchString(expr) {
+			return expr
+		}
+		return hclLiteral(value)
+	}
+}
+
+// FormatVariableValue formats a variable's value, escaping interpolation when the variable is marked literal
+func FormatVariableValue(varDef models.Variable) string {
+	if expr, ok := varDef.Value.(string); ok && varDef.Expression {
+		return expr
+	}
+	if text, ok := varDef.Value.(string); ok && varDef.Heredoc {
+		return Heredoc(text, "  ", varDef.LiteralDollar)
+	}
+	formatted := formatValue(varDef.Value, varDef.Type)
+	if varDef.LiteralDollar {
+		return escapeTemplate(formatted)
+	}
+	return formatted
+}
+
+// RenderFeatures renders the azurerm features block, which the provider requires even when empty
+func RenderFeatures(features map[string]interface{}) string {
+	return renderBlock("features", features, "  ")
+}
+
+// renderBlock renders a block whose map values become nested blocks and whose other values become attributes
+func renderBlock(name string, body map[string]interface{}, indent string) string {
+	if len(body) == 0 {
+		return fmt.Sprintf("%s {}", name)
+	}
+
+	var lines []string
+	for _, key := range sortedKeys(body) {
+		if nested, ok := body[key].(map[string]interface{}); ok {
+			lines = append(lines, indent+"  "+renderBlock(key, nested, indent+"  "))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s = %s", indent, key, hclLiteral(body[key])))
+	}
+	return fmt.Sprintf("%s {\n%s\n%s}", name, strings.Join(lines, "\n"), indent)
+}
+
+// isBlockList reports whether value is a list of objects, which resource arguments render as repeated nested blocks
+func isBlockList(value interface{}) ([]map[string]interface{}, bool) {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, false
+	}
+	blocks := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		block, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, true
+}
+
+// appendArguments writes arguments into body: attributes in sorted order, then a nested block for each object of a
+// list of objects. Blocks are separated from whatever this call wrote before them by a blank line.
+func appendArguments(body *hclwrite.Body, arguments map[string]interface{}) {
+	written := false
+	var blockKeys []string
+	for _, key := range sortedKeys(arguments) {
+		if _, ok := isBlockList(arguments[key]); ok {
+			blockKeys = append(blockKeys, key)
+			continue
+		}
+		body.SetAttributeRaw(key, expressionTokens(formatValue(arguments[key], "")))
+		written = true
+	}
+	for _, key := range blockKeys {
+		nested, _ := isBlockList(arguments[key])
+		for _, fields := range nested {
+			if written {
+				body.AppendNewline()
+			}
+			appendArguments(body.AppendNewBlock(key, nil).Body(), fields)
+			written = true
+		}
+	}
+}
+
+// expressionTokens returns the tokens of an expression rendered by formatValue. An expression that does not parse,
+// which formatValue never produces, is passed through as it is.
+func expressionTokens(expression string) hclwrite.Tokens {
+	file, diags := hclwrite.ParseConfig([]byte("value = "+expression+"\n"), "", hcl.InitialPos)
+	if !diags.HasErrors() {
+		if attribute := file.Body().GetAttribute("value"); attribute != nil {
+			return attribute.Expr().BuildTokens(nil)
+		}
+	}
+	return hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: []byte(expression)}}
+}
+
+// renderLabeledBlock renders a top-level block built by fill, formatted like terraform fmt, or as name and labels
+// followed by {} when fill writes nothing.
+func renderLabeledBlock(name string, labels []string, fill func(*hclwrite.Body)) string {
+	file := hclwrite.NewEmptyFile()
+	body := file.Body().AppendNewBlock(name, labels).Body()
+	fill(body)
+	if len(body.Attributes()) == 0 && len(body.Blocks()) == 0 {
+		quoted := make([]string, len(labels))
+		for i, label := range labels {
+			quoted[i] = quoteString(label)
+		}
+		return fmt.Sprintf("%s %s {}", name, strings.Join(quoted, " "))
+	}
+	return strings.TrimSuffix(string(hclwrite.Format(file.Bytes())), "\n")
+}
+
+// RenderResource renders a configured resource as a resource block
+func RenderResource(resource models.Resource) string {
+	return renderLabeledBlock("resource", []string{resource.Type, resource.Name}, func(body *hclwrite.Body) {
+		if len(resource.Count) > 0 {
+			// Meta-arguments come first, separated from the arguments
+			body.SetAttributeTraversal("count", hcl.Traversal{hcl.TraverseRoot{Name: "var"}, hcl.TraverseAttr{Name: resource.CountVariable()}})
+			if len(resource.Arguments) > 0 {
+				body.AppendNewline()
+			}
+		}
+		appendArguments(body, resource.Arguments)
+	})
+}
+
+// RenderLinkedProvider renders a linked provider's configuration block. Settings render like resource arguments, so
+// a list of objects becomes nested blocks and a reference such as module.eks.endpoint stays unquoted.
+func RenderLinkedProvider(provider models.LinkedProvider) string {
+	return renderLabeledBlock("provider", []string{provider.Name}, func(body *hclwrite.Body) {
+		appendArguments(body, provider.Settings)
+	})
+}
+
+// ModuleReference is a module output referenced from the configuration
+type ModuleReference struct {
+	Label   string // Module block label
+	Output  string
+	Indexed bool // Whether an instance is selected, as in module.eks[0].endpoint
+}
+
+// ModuleReferences lists the module outputs referenced anywhere in value, in the order they appear
+func ModuleReferences(value interface{}) []ModuleReference {
+	var references []ModuleReference
+	switch v := value.(type) {
+	case string:
+		for _, match := range moduleOutputPattern.FindAllStringSubmatch(v, -1) {
+			references = append(references, ModuleReference{Label: match[1], Output: match[3], Indexed: match[2] != ""})
+		}
+	case []interface{}:
+		for _, item := range v {
+			references = append(references, ModuleReferences(item)...)
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			references = append(references, ModuleReferences(v[key])...)
+		}
+	}
+	return references
+}
+
+// RenderLifecycle renders a lifecycle block indented for use inside a resource, or an empty string when nothing is set
+func RenderLifecycle(lifecycle *models.Lifecycle) string {
+	if lifecycle == nil {
+		return ""
+	}
+
+	var lines []string
+	if lifecycle.PreventDestroy {
+		lines = append(lines, "    prevent_destroy = true")
+	}
+	if lifecycle.CreateBeforeDestroy {
+		lines = append(lines, "    create_before_destroy = true")
+	}
+	if len(lifecycle.IgnoreChanges) == 1 && lifecycle.IgnoreChanges[0] == "all" {
+		lines = append(lines, "    ignore_changes = all")
+	} else if len(lifecycle.IgnoreChanges) > 0 {
+		// Attribute references are rendered unquoted
+		lines = append(lines, fmt.Sprintf("    ignore_changes = [%s]", strings.Join(lifecycle.IgnoreChanges, ", ")))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\n  lifecycle {\n%s\n  }", strings.Join(lines, "\n"))
+}
+
+// Comment renders text as HCL line comments, prefixing every line with "# "
+func Comment(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("# "+strings.TrimRight(line, "\r"), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// EscapeInterpolation escapes "${" as "$${" so Terraform renders it literally
+func EscapeInterpolation(value string) string {
+	return strings.ReplaceAll(value, "${", "$${")
+}
+
+// Heredoc renders text as a <<-EOT heredoc for an attribute at indent, indenting its lines one level further. Literal
+// heredocs escape "${" and "%{" so placeholders and directives in embedded YAML or JSON documents are kept as text.
+// Terraform ends the string with a newline; the marker changes if a line of text already matches it.
+func Heredoc(text, indent string, literal bool) string {
+	if literal {
+		text = escapeTemplate(text)
+	}
+	lines := strings.Split(strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), "\n")
+	marker := "EOT"
+	for slices.ContainsFunc(lines, func(line string) bool { return strings.TrimSpace(line) == marker }) {
+		marker += "_"
+	}
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + "  " + line
+		}
+	}
+	return fmt.Sprintf("<<-%s\n%s\n%s%s", marker, strings.Join(lines, "\n"), indent, marker)
+}
+
+// FormatDefault formats the default value of a variable
+func FormatDefault(varDef models.Variable) string {
+	if text, ok := varDef.Default.(string); ok && varDef.Heredoc {
+		return Heredoc(text, "  ", varDef.LiteralDollar)
+	}
+	formatted := formatDefaultValue(varDef)
+	if varDef.LiteralDollar {
+		return escapeTemplate(formatted)
+	}
+	return formatted
+}
+
+// formatDefaultValue renders a variable default as an HCL expression
+func formatDefaultValue(varDef models.Variable) string {
+	switch varDef.Type {
+	case "bool", "number":
+		return scalarLiteral(varDef.Default)
+	case "string":
+		// Check if default is an expression
+		if expr, ok := varDef.Default.(string); ok && isReference(expr) {
+			return expr // Expression
+		}
+		return stringLiteral(varDef.Default)
+	case "list(string)", "set(string)":
+		return formatStringList(coerceValue(varDef.Default, varDef.Type), varDef.Type)
+	case "map(string)":
+		return formatStringMap(varDef.Default)
+	case "object({ provision_vm_agent = bool, enable_automatic_upgrades = bool })",
+		"object({ publisher = string, offer = string, sku = string, version = string })",
+		"object({ name = string, caching = string, create_option = string, managed_disk_type = string })":
+		// Assume default is a map[string]interface{}
+		objMap, ok := varDef.Default.(map[string]interface{})
+		if !ok {
+			return "{}"
+		}
+		return hclLiteral(objMap)
+	case "tuple":
+		tuple, ok := varDef.Default.([]interface{})
+		if !ok {
+			return "[]"
+		}
+		return hclLiteral(tuple)
+	default:
+		if expr, ok := varDef.Default.(string); ok && traversalPattern.MatchString(expr) {
+			return expr
+		}
+		return hclLiteral(varDef.Default)
+	}
+}
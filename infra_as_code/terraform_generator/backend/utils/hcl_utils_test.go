@@ -0,0 +1,454 @@
+// backend/utils/hcl_utils_test.go
+
+package utils
+
+import (
+	"backend/models"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+var fuzzSeeds = []struct {
+	value   string
+	varType string
+}{
+	{`"eastus"`, "string"},
+	{`"var.location"`, "string"},
+	{`true`, "bool"},
+	{`3`, "number"},
+	{`1e300`, "number"},
+	{`["a", "b"]`, "list(string)"},
+	{`["a", {"b": 1}]`, "set(string)"},
+	{`{"env": "dev", "team": "platform"}`, "map(string)"},
+	{`{"quote\"key": "new\nline"}`, "map(string)"},
+	{`{"provision_vm_agent": true, "enable_automatic_upgrades": false}`, "object({ provision_vm_agent = bool, enable_automatic_upgrades = bool })"},
+	{`["x", 1, null]`, "tuple"},
+	{`null`, "any"},
+	{`"back\\slash"`, "any"},
+	{`"azurerm_resource_group.main.name"`, "any"},
+	{`{"nested": {"list": [1, 2.5, "x"]}}`, "any"},
+	{`{"west": {"cidr": "10.0.0.0/16"}, "east-1": {"name": "e"}}`, "map(map(string))"},
+	{`{"outer": {"inner": "v"}}`, "map(string)"},
+}
+
+// checkExpression fails the test if out is not a valid HCL expression
+func checkExpression(t *testing.T, input, varType, out string) {
+	t.Helper()
+	if _, diags := hclsyntax.ParseExpression([]byte(out), "fuzz.tf", hcl.InitialPos); diags.HasErrors() {
+		t.Fatalf("input %s (type %q) rendered invalid HCL %q: %s", input, varType, out, diags.Error())
+	}
+}
+
+// decodeFuzzInput decodes a fuzz input, skipping invalid JSON and deliberate interpolation
+func decodeFuzzInput(t *testing.T, input string) interface{} {
+	if strings.Contains(input, "${") || strings.Contains(input, "%{") {
+		t.Skip("interpolation sequences are passed through by design")
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(input), &value); err != nil {
+		t.Skip("not valid JSON")
+	}
+	return value
+}
+
+func FuzzFormatDefault(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed.value, seed.varType)
+	}
+	f.Fuzz(func(t *testing.T, input, varType string) {
+		value := decodeFuzzInput(t, input)
+		out := FormatDefault(models.Variable{Type: varType, Default: value})
+		checkExpression(t, input, varType, out)
+	})
+}
+
+func FuzzFormatValue(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed.value, seed.varType)
+	}
+	f.Fuzz(func(t *testing.T, input, varType string) {
+		value := decodeFuzzInput(t, input)
+		out := FormatVariableValue(models.Variable{Type: varType, Value: value})
+		checkExpression(t, input, varType, out)
+	})
+}
+
+func FuzzTfvars(f *testing.F) {
+	for _, seed := range []string{"eastus", `say "hi"`, `C:\temp`, "new\nline", "${var.env}", "%{ if true }x%{ endif }"} {
+		f.Add(seed, true)
+		f.Add(seed, false)
+	}
+	tmpl := filepath.Join("..", "templates", "generic", "vars.tfvars.tmpl")
+	f.Fuzz(func(t *testing.T, input string, literalDollar bool) {
+		if !literalDollar && (strings.Contains(input, "${") || strings.Contains(input, "%{")) {
+			t.Skip("interpolation sequences are passed through by design")
+		}
+		variables := map[string]models.Variable{
+			"name": {Type: "string", Value: input, LiteralDollar: literalDollar},
+			"tags": {Type: "map(string)", Value: map[string]interface{}{"team": input}, LiteralDollar: literalDollar},
+		}
+		out, err := renderTemplateSet([]string{tmpl}, map[string]interface{}{"Variables": variables, "TfvarsOrder": []string(nil), "TfvarsComments": false}, GenerateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, diags := hclsyntax.ParseConfig(out, "vars.tfvars", hcl.InitialPos)
+		if diags.HasErrors() {
+			t.Fatalf("input %q rendered invalid tfvars %q: %s", input, out, diags.Error())
+		}
+		// A tfvars file is evaluated without variables, so every value must come back exactly as given
+		attributes, _ := file.Body.JustAttributes()
+		name, diags := attributes["name"].Expr.Value(nil)
+		if diags.HasErrors() {
+			t.Fatalf("input %q rendered a tfvars value that needs evaluation %q: %s", input, out, diags.Error())
+		}
+		if utf8.ValidString(input) && name.AsString() != input {
+			t.Errorf("input %q rendered as %q in\n%s", input, name.AsString(), out)
+		}
+	})
+}
+
+func TestExpressionsOnlyRenderAsModuleArguments(t *testing.T) {
+	conditional := `var.env == "prod" ? 3 : 1`
+
+	// A module argument is evaluated by Terraform, so the expression passes through verbatim
+	if got := FormatVariableValue(models.Variable{Type: "number", Value: conditional, Expression: true}); got != conditional {
+		t.Errorf("FormatVariableValue() = %s, want %s", got, conditional)
+	}
+	// A default must be constant, so a string stays a quoted literal whatever the flag says
+	if got := FormatDefault(models.Variable{Type: "string", Default: conditional, Expression: true}); got != `"var.env == \"prod\" ? 3 : 1"` {
+		t.Errorf("FormatDefault() = %s, want a quoted string", got)
+	}
+}
+
+func TestRenderFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		features map[string]interface{}
+		want     string
+	}{
+		{name: "empty", features: nil, want: "features {}"},
+		{
+			name: "nested",
+			features: map[string]interface{}{
+				"resource_group": map[string]interface{}{"prevent_deletion_if_contains_resources": false},
+				"key_vault": map[string]interface{}{
+					"purge_soft_delete_on_destroy":    true,
+					"recover_soft_deleted_key_vaults": true,
+				},
+			},
+			want: "features {\n" +
+				"    key_vault {\n" +
+				"      purge_soft_delete_on_destroy = true\n" +
+				"      recover_soft_deleted_key_vaults = true\n" +
+				"    }\n" +
+				"    resource_group {\n" +
+				"      prevent_deletion_if_contains_resources = false\n" +
+				"    }\n" +
+				"  }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RenderFeatures(tt.features)
+			if got != tt.want {
+				t.Fatalf("RenderFeatures() =\n%s\nwant\n%s", got, tt.want)
+			}
+			provider := "provider \"azurerm\" {\n  " + got + "\n}\n"
+			if _, diags := hclsyntax.ParseConfig([]byte(provider), "providers.tf", hcl.InitialPos); diags.HasErrors() {
+				t.Fatalf("rendered features are not valid HCL: %s", diags.Error())
+			}
+		})
+	}
+}
+
+func TestFormatNestedValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{name: "scalar", value: "v", want: `"v"`},
+		{name: "empty", value: map[string]interface{}{}, want: "{}"},
+		{
+			name:  "map of maps",
+			value: map[string]interface{}{"outer": map[string]interface{}{"inner": "v", "list": []interface{}{"a", 1.0}}},
+			want:  "{\n  outer = {\n    inner = \"v\"\n    list = [\"a\", 1]\n  }\n}",
+		},
+		{
+			name:  "typed map",
+			value: map[string]map[string]string{"west": {"cidr": "10.0.0.0/16"}, "has space": {}},
+			want:  "{\n  \"has space\" = {}\n  west = {\n    cidr = \"10.0.0.0/16\"\n  }\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatNestedValue(tt.value, "")
+			if got != tt.want {
+				t.Fatalf("FormatNestedValue() =\n%s\nwant\n%s", got, tt.want)
+			}
+			checkExpression(t, tt.name, "", got)
+		})
+	}
+}
+
+func TestHCLLiteralTypedNestedValues(t *testing.T) {
+	got := hclLiteral(map[string][]string{"zones": {"1", "2"}})
+	if want := `{ "zones" = ["1", "2"] }`; got != want {
+		t.Fatalf("hclLiteral() = %s, want %s", got, want)
+	}
+	if strings.Contains(stringLiteral(map[string]map[string]int{"a": {"b": 1}}), "map[") {
+		t.Fatal("stringLiteral() rendered a nested map in Go syntax")
+	}
+}
+
+func TestRenderLinkedProvider(t *testing.T) {
+	provider := models.LinkedProvider{
+		Name: "kubernetes",
+		Settings: map[string]interface{}{
+			"host":                   "module.eks.endpoint",
+			"cluster_ca_certificate": "${base64decode(module.eks.ca_data)}",
+			"exec":                   []interface{}{map[string]interface{}{"command": "aws", "args": []interface{}{"eks", "get-token"}}},
+		},
+	}
+	want := "provider \"kubernetes\" {\n" +
+		"  cluster_ca_certificate = \"${base64decode(module.eks.ca_data)}\"\n" +
+		"  host                   = module.eks.endpoint\n" +
+		"\n" +
+		"  exec {\n" +
+		"    args    = [\"eks\", \"get-token\"]\n" +
+		"    command = \"aws\"\n" +
+		"  }\n" +
+		"}"
+
+	got := RenderLinkedProvider(provider)
+	if got != want {
+		t.Fatalf("RenderLinkedProvider() =\n%s\nwant\n%s", got, want)
+	}
+	if _, diags := hclsyntax.ParseConfig([]byte(got), "providers.tf", hcl.InitialPos); diags.HasErrors() {
+		t.Fatalf("rendered provider is not valid HCL: %s", diags.Error())
+	}
+
+	references := ModuleReferences(provider.Settings)
+	wantReferences := []ModuleReference{{Label: "eks", Output: "ca_data"}, {Label: "eks", Output: "endpoint"}}
+	if len(references) != len(wantReferences) || references[0] != wantReferences[0] || references[1] != wantReferences[1] {
+		t.Fatalf("ModuleReferences() = %v, want %v", references, wantReferences)
+	}
+	if references := ModuleReferences("${module.eks[0].endpoint}"); len(references) != 1 || references[0] != (ModuleReference{Label: "eks", Output: "endpoint", Indexed: true}) {
+		t.Fatalf("ModuleReferences() of an indexed reference = %v", references)
+	}
+}
+
+func TestRenderResourceCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource models.Resource
+		want     string
+	}{
+		{
+			name:     "count only",
+			resource: models.Resource{Type: "azurerm_public_ip", Name: "egress", Count: map[string]int{"default": 1}},
+			want:     "resource \"azurerm_public_ip\" \"egress\" {\n  count = var.azurerm_public_ip_egress_count\n}",
+		},
+		{
+			name:     "count before the arguments",
+			resource: models.Resource{Type: "azurerm_public_ip", Name: "egress", Count: map[string]int{"prod": 2}, Arguments: map[string]interface{}{"sku": "Standard"}},
+			want:     "resource \"azurerm_public_ip\" \"egress\" {\n  count = var.azurerm_public_ip_egress_count\n\n  sku = \"Standard\"\n}",
+		},
+		{
+			name:     "no count",
+			resource: models.Resource{Type: "azurerm_public_ip", Name: "egress"},
+			want:     "resource \"azurerm_public_ip\" \"egress\" {}",
+		},
+		{
+			name: "count before nested blocks",
+			resource: models.Resource{Type: "aws_instance", Name: "web", Count: map[string]int{"default": 1}, Arguments: map[string]interface{}{
+				"ebs_block_device": []interface{}{map[string]interface{}{"device_name": "/dev/sdb"}},
+			}},
+			want: "resource \"aws_instance\" \"web\" {\n" +
+				"  count = var.aws_instance_web_count\n" +
+				"\n" +
+				"  ebs_block_device {\n" +
+				"    device_name = \"/dev/sdb\"\n" +
+				"  }\n" +
+				"}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RenderResource(tt.resource)
+			if got != tt.want {
+				t.Fatalf("RenderResource() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if _, diags := hclsyntax.ParseConfig([]byte(got), "resources.tf", hcl.InitialPos); diags.HasErrors() {
+				t.Fatalf("rendered resource is not valid HCL: %s", diags.Error())
+			}
+		})
+	}
+}
+
+func TestRenderResourceNestedBlocks(t *testing.T) {
+	var arguments map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"name": "web-${var.env}",
+		"subnet_id": "azurerm_subnet.main.id",
+		"tags": {"env": "prod", "owner": "platform"},
+		"zones": ["1", "2"],
+		"security_rule": [
+			{"name": "ssh", "port": 22, "source": {"addresses": ["10.0.0.0/8"]}},
+			{"name": "https", "port": 443, "log": [{"enabled": true}, {"enabled": false, "retention": 7}]}
+		],
+		"metadata": [{}]
+	}`), &arguments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "resource \"azurerm_network_security_group\" \"main\" {\n" +
+		"  name      = \"web-${var.env}\"\n" +
+		"  subnet_id = azurerm_subnet.main.id\n" +
+		"  tags      = { \"env\" = \"prod\", \"owner\" = \"platform\" }\n" +
+		"  zones     = [\"1\", \"2\"]\n" +
+		"\n" +
+		"  metadata {\n" +
+		"  }\n" +
+		"\n" +
+		"  security_rule {\n" +
+		"    name   = \"ssh\"\n" +
+		"    port   = 22\n" +
+		"    source = { \"addresses\" = [\"10.0.0.0/8\"] }\n" +
+		"  }\n" +
+		"\n" +
+		"  security_rule {\n" +
+		"    name = \"https\"\n" +
+		"    port = 443\n" +
+		"\n" +
+		"    log {\n" +
+		"      enabled = true\n" +
+		"    }\n" +
+		"\n" +
+		"    log {\n" +
+		"      enabled   = false\n" +
+		"      retention = 7\n" +
+		"    }\n" +
+		"  }\n" +
+		"}"
+
+	got := RenderResource(models.Resource{Type: "azurerm_network_security_group", Name: "main", Arguments: arguments})
+	if got != want {
+		t.Fatalf("RenderResource() =\n%s\nwant\n%s", got, want)
+	}
+	if _, diags := hclsyntax.ParseConfig([]byte(got), "resources.tf", hcl.InitialPos); diags.HasErrors() {
+		t.Fatalf("rendered resource is not valid HCL: %s", diags.Error())
+	}
+}
+
+func TestHeredoc(t *testing.T) {
+	policy := "{\n  \"Resource\": \"arn:aws:s3:::${bucket}/*\"\n\n}\n"
+	want := "<<-EOT\n" +
+		"    {\n" +
+		"      \"Resource\": \"arn:aws:s3:::$${bucket}/*\"\n" +
+		"\n" +
+		"    }\n" +
+		"  EOT"
+	if got := Heredoc(policy, "  ", true); got != want {
+		t.Fatalf("Heredoc() =\n%s\nwant\n%s", got, want)
+	}
+	if got := Heredoc("%{ if x }on%{ endif } ${y}", "", true); got != "<<-EOT\n  %%{ if x }on%%{ endif } $${y}\nEOT" {
+		t.Fatalf("Heredoc() = %q, want template directives escaped in a literal heredoc", got)
+	}
+	if got := Heredoc("name: ${env}", "", false); got != "<<-EOT\n  name: ${env}\nEOT" {
+		t.Fatalf("Heredoc() escaped an interpolated document: %q", got)
+	}
+	if got := Heredoc("a\nEOT\nb", "", false); !strings.HasPrefix(got, "<<-EOT_\n") || !strings.HasSuffix(got, "\nEOT_") {
+		t.Fatalf("Heredoc() = %q, want a marker not found in the text", got)
+	}
+}
+
+func TestTagsFormats(t *testing.T) {
+	tags := map[string]interface{}{"Team": "web", "CostCentre": float64(1234)}
+	got, err := TagsMap(tags)
+	if want := `{ "CostCentre" = "1234", "Team" = "web" }`; err != nil || got != want {
+		t.Fatalf("TagsMap() = %s, %v, want %s", got, err, want)
+	}
+	got, err = TagsList(map[string]string{"Team": "web", "Environment": "prod"})
+	if want := `[{ key = "Environment", value = "prod", propagate_at_launch = true }, { key = "Team", value = "web", propagate_at_launch = true }]`; err != nil || got != want {
+		t.Fatalf("TagsList() = %s, %v, want %s", got, err, want)
+	}
+	if got, _ := TagsList(map[string]string{"Team": "web"}, false); !strings.Contains(got, "propagate_at_launch = false") {
+		t.Fatalf("TagsList(false) = %s, want tags that do not propagate", got)
+	}
+	if got, _ := TagsList(nil); got != "[]" {
+		t.Fatalf("TagsList(nil) = %s, want []", got)
+	}
+	if _, err := TagsMap(map[string]interface{}{"Owners": []interface{}{"a"}}); err == nil {
+		t.Fatal("TagsMap() accepted a list tag value")
+	}
+}
+
+func TestNamedReferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     func() (string, error)
+		want    string
+		wantErr bool
+	}{
+		{name: "variable", ref: func() (string, error) { return VarRef("location") }, want: "var.location"},
+		{name: "local", ref: func() (string, error) { return LocalRef("common_tags") }, want: "local.common_tags"},
+		{name: "module", ref: func() (string, error) { return ModuleRef("vnet") }, want: "module.vnet"},
+		{name: "module output", ref: func() (string, error) { return ModuleRef("vnet", "subnet_ids") }, want: "module.vnet.subnet_ids"},
+		{name: "module attribute", ref: func() (string, error) { return ModuleRef("aks-cluster", "identity", "principal_id") }, want: "module.aks-cluster.identity.principal_id"},
+		{name: "empty variable", ref: func() (string, error) { return VarRef("") }, wantErr: true},
+		{name: "expression in a local", ref: func() (string, error) { return LocalRef("tags[0]") }, wantErr: true},
+		{name: "leading digit", ref: func() (string, error) { return VarRef("1st") }, wantErr: true},
+		{name: "dotted module label", ref: func() (string, error) { return ModuleRef("vnet.id") }, wantErr: true},
+		{name: "invalid output", ref: func() (string, error) { return ModuleRef("vnet", "subnet ids") }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.ref()
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("got %q, %v; want %q, error %t", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNamedReferenceTemplateFunctions(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "main.tf.tmpl")
+	content := `location = {{ varRef "location" }}
+tags     = {{ localRef "common_tags" }}
+subnet   = {{ moduleRef "vnet" "subnet_ids" }}
+`
+	if err := os.WriteFile(templatePath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, "main.tf")
+	if _, err := GenerateFileFromTemplate(templatePath, dest, nil, GenerateOptions{Overwrite: true}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "location = var.location\ntags     = local.common_tags\nsubnet   = module.vnet.subnet_ids\n"; string(got) != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+
+	// An invalid name fails the render rather than writing a broken reference
+	if err := os.WriteFile(templatePath, []byte(`{{ varRef "bad name" }}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GenerateFileFromTemplate(templatePath, dest, nil, GenerateOptions{Overwrite: true}); err == nil || !strings.Contains(err.Error(), "invalid var reference") {
+		t.Errorf("GenerateFileFromTemplate() error = %v, want the invalid var reference", err)
+	}
+}
@@ -0,0 +1,164 @@
+// backend/utils/import_utils.go
+
+package utils
+
+import (
+	"backend/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// variablesSchema selects the variable blocks of a Terraform file; every other block is ignored
+var variablesSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+}
+
+// variableSchema lists the variable arguments and blocks that have a place in the configuration
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "type"}, {Name: "description"}, {Name: "default"}, {Name: "sensitive"}, {Name: "nullable"}},
+	Blocks:     []hcl.BlockHeaderSchema{{Type: "validation"}},
+}
+
+// validationSchema lists the arguments of a variable's validation block
+var validationSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "condition", Required: true}, {Name: "error_message", Required: true}},
+}
+
+// ParseVariables reads the variable blocks of a Terraform file into configuration variables. Types are kept as written,
+// defaults are converted to JSON values and missing types are inferred from the default.
+func ParseVariables(src []byte, filename string) (map[string]models.Variable, error) {
+	file, diags := hclparse.NewParser().ParseHCL(src, filename)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	content, _, diags := file.Body.PartialContent(variablesSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	variables := make(map[string]models.Variable)
+	for _, block := range content.Blocks {
+		name := block.Labels[0]
+		if _, exists := variables[name]; exists {
+			return nil, fmt.Errorf("%s: duplicate variable %q", block.DefRange, name)
+		}
+		variable, err := parseVariable(block, src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: variable %q: %w", block.DefRange, name, err)
+		}
+		variables[name] = inferVariableType("imported variable", name, variable)
+	}
+	return variables, nil
+}
+
+// parseVariable converts a single variable block
+func parseVariable(block *hcl.Block, src []byte) (models.Variable, error) {
+	content, _, diags := block.Body.PartialContent(variableSchema)
+	if diags.HasErrors() {
+		return models.Variable{}, diags
+	}
+
+	var variable models.Variable
+	if attr, ok := content.Attributes["type"]; ok {
+		variable.Type = typeExpression(attr.Expr, src)
+	}
+	if attr, ok := content.Attributes["description"]; ok {
+		if err := decodeValue(attr.Expr, &variable.Description); err != nil {
+			return variable, fmt.Errorf("description: %w", err)
+		}
+	}
+	if attr, ok := content.Attributes["sensitive"]; ok {
+		if err := decodeValue(attr.Expr, &variable.Sensitive); err != nil {
+			return variable, fmt.Errorf("sensitive: %w", err)
+		}
+	}
+	if attr, ok := content.Attributes["nullable"]; ok {
+		if err := decodeValue(attr.Expr, &variable.Nullable); err != nil {
+			return variable, fmt.Errorf("nullable: %w", err)
+		}
+	}
+	if attr, ok := content.Attributes["default"]; ok {
+		// A default Terraform could not evaluate without context is kept as an expression
+		if err := decodeValue(attr.Expr, &variable.Default); err != nil {
+			variable.Default = sourceText(attr.Expr, src)
+			variable.Expression = true
+		}
+	}
+
+	for i, validation := range content.Blocks {
+		if i > 0 {
+			log.Printf("warning: only the first validation block of variable %s is imported", block.Labels[0])
+			break
+		}
+		rule, diags := validation.Body.Content(validationSchema)
+		if diags.HasErrors() {
+			return variable, diags
+		}
+		variable.Validation = &models.Validation{Condition: sourceText(rule.Attributes["condition"].Expr, src)}
+		if err := decodeValue(rule.Attributes["error_message"].Expr, &variable.Validation.ErrorMessage); err != nil {
+			variable.Validation.ErrorMessage = sourceText(rule.Attributes["error_message"].Expr, src)
+		}
+	}
+	return variable, nil
+}
+
+// decodeValue evaluates a constant expression and decodes its JSON form into target
+func decodeValue(expr hcl.Expression, target interface{}) error {
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return diags
+	}
+	if value.IsNull() {
+		return nil
+	}
+	encoded, err := ctyjson.Marshal(value, value.Type())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, target)
+}
+
+// typeExpression renders a type constraint on one line the way the configuration writes types, e.g.
+// "object({ name = string, size = optional(number, 1) })"
+func typeExpression(expr hcl.Expression, src []byte) string {
+	switch e := expr.(type) {
+	case *hclsyntax.ScopeTraversalExpr:
+		return e.Traversal.RootName()
+	case *hclsyntax.FunctionCallExpr:
+		args := make([]string, 0, len(e.Args))
+		for _, arg := range e.Args {
+			args = append(args, typeExpression(arg, src))
+		}
+		return e.Name + "(" + strings.Join(args, ", ") + ")"
+	case *hclsyntax.ObjectConsExpr:
+		attributes := make([]string, 0, len(e.Items))
+		for _, item := range e.Items {
+			key := hcl.ExprAsKeyword(item.KeyExpr)
+			if key == "" {
+				key = sourceText(item.KeyExpr, src)
+			}
+			attributes = append(attributes, key+" = "+typeExpression(item.ValueExpr, src))
+		}
+		return "{ " + strings.Join(attributes, ", ") + " }"
+	case *hclsyntax.TupleConsExpr:
+		elements := make([]string, 0, len(e.Exprs))
+		for _, element := range e.Exprs {
+			elements = append(elements, typeExpression(element, src))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	default:
+		return sourceText(expr, src)
+	}
+}
+
+// sourceText returns the expression as written, with line breaks and indentation collapsed
+func sourceText(expr hcl.Expression, src []byte) string {
+	return strings.Join(strings.Fields(string(expr.Range().SliceBytes(src))), " ")
+}
@@ -0,0 +1,67 @@
+// backend/utils/import_utils_test.go
+
+package utils
+
+import (
+	"backend/models"
+	"reflect"
+	"testing"
+)
+
+func TestParseVariables(t *testing.T) {
+	src := []byte(`
+variable "location" {
+  type        = string
+  description = "Azure region"
+  default     = "australiaeast"
+}
+
+variable "vm" {
+  type = object({
+    name = string
+    size = optional(number, 2)
+  })
+  sensitive = true
+  validation {
+    condition     = length(var.vm.name) > 0
+    error_message = "Name must not be empty."
+  }
+}
+
+variable "replicas" {
+  default = 3
+}
+
+variable "prefix" {
+  type    = string
+  default = "${var.location}-app"
+}
+
+output "ignored" {
+  value = var.location
+}
+`)
+
+	want := map[string]models.Variable{
+		"location": {Type: "string", Description: "Azure region", Default: "australiaeast"},
+		"vm": {
+			Type:       "object({ name = string, size = optional(number, 2) })",
+			Sensitive:  true,
+			Validation: &models.Validation{Condition: "length(var.vm.name) > 0", ErrorMessage: "Name must not be empty."},
+		},
+		"replicas": {Type: "number", Default: float64(3)},
+		"prefix":   {Type: "string", Default: `"${var.location}-app"`, Expression: true},
+	}
+
+	got, err := ParseVariables(src, "variables.tf")
+	if err != nil {
+		t.Fatalf("ParseVariables() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseVariables() = %+v, want %+v", got, want)
+	}
+
+	if _, err := ParseVariables([]byte("variable \"a\" {}\nvariable \"a\" {}\n"), "variables.tf"); err == nil {
+		t.Fatal("ParseVariables() accepted a duplicate variable")
+	}
+}
@@ -0,0 +1,161 @@
+// backend/utils/log_utils.go
+
+package utils
+
+import (
+	"backend/models"
+	"encoding/json"
+	"log"
+)
+
+// RedactedValue replaces sensitive values wherever they are logged
+const RedactedValue = "***"
+
+// redactVariable replaces the default and value of a sensitive variable with RedactedValue
+func redactVariable(variable models.Variable) models.Variable {
+	if !variable.Sensitive {
+		return variable
+	}
+	if variable.Default != nil {
+		variable.Default = RedactedValue
+	}
+	if variable.Value != nil {
+		variable.Value = RedactedValue
+	}
+	return variable
+}
+
+// RedactVariables returns a copy of variables with the values of sensitive variables redacted
+func RedactVariables(variables map[string]models.Variable) map[string]models.Variable {
+	redacted := make(map[string]models.Variable, len(variables))
+	for name, variable := range variables {
+		redacted[name] = redactVariable(variable)
+	}
+	return redacted
+}
+
+// redactModules returns a copy of modules with the values of their sensitive variables redacted
+func redactModules(modules []models.Module) []models.Module {
+	redacted := make([]models.Module, len(modules))
+	for i, module := range modules {
+		variables := make(map[string]models.ModuleVariable, len(module.Variables))
+		for name, variable := range module.Variables {
+			variable.Variable = redactVariable(variable.Variable)
+			variables[name] = variable
+		}
+		module.Variables = variables
+		redacted[i] = module
+	}
+	return redacted
+}
+
+// redactBackend returns a copy of backend, and of its per-environment backends, without the storage access key
+func redactBackend(backend models.Backend) models.Backend {
+	if backend.AccessKey != "" {
+		backend.AccessKey = RedactedValue
+	}
+	if len(backend.Environments) > 0 {
+		environments := make(map[string]models.Backend, len(backend.Environments))
+		for env, override := range backend.Environments {
+			environments[env] = redactBackend(override)
+		}
+		backend.Environments = environments
+	}
+	return backend
+}
+
+// redactSettings returns a copy of settings with every value replaced by RedactedValue. Provider settings and
+// request extras cannot be marked sensitive one by one, so only their names are logged.
+func redactSettings(settings map[string]interface{}) map[string]interface{} {
+	if settings == nil {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(settings))
+	for name := range settings {
+		redacted[name] = RedactedValue
+	}
+	return redacted
+}
+
+// redactAuthVariables returns a copy of auth variables with every value replaced by RedactedValue
+func redactAuthVariables(variables map[string]string) map[string]string {
+	if variables == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(variables))
+	for name := range variables {
+		redacted[name] = RedactedValue
+	}
+	return redacted
+}
+
+// redactProvider returns a copy of provider, and of its per-environment overrides, without setting and auth variable
+// values
+func redactProvider(provider models.Provider) models.Provider {
+	provider.Settings = redactSettings(provider.Settings)
+	provider.AuthVariables = redactAuthVariables(provider.AuthVariables)
+	if len(provider.Environments) > 0 {
+		environments := make(map[string]models.ProviderOverride, len(provider.Environments))
+		for env, override := range provider.Environments {
+			override.Settings = redactSettings(override.Settings)
+			override.AuthVariables = redactAuthVariables(override.AuthVariables)
+			environments[env] = override
+		}
+		provider.Environments = environments
+	}
+	return provider
+}
+
+// RedactTemplateData returns a copy of template data that is safe to log: sensitive variable values, the backend
+// access key, provider settings and auth variables, and the request's extra values are replaced with RedactedValue.
+// The data passed in is left untouched.
+func RedactTemplateData(data map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if extra, ok := value.(map[string]interface{}); ok && key == "Extra" {
+			redacted[key] = redactSettings(extra)
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]models.Variable:
+			redacted[key] = RedactVariables(v)
+		case map[string]map[string]models.Variable:
+			byModule := make(map[string]map[string]models.Variable, len(v))
+			for label, variables := range v {
+				byModule[label] = RedactVariables(variables)
+			}
+			redacted[key] = byModule
+		case []models.Module:
+			redacted[key] = redactModules(v)
+		case models.Backend:
+			redacted[key] = redactBackend(v)
+		case *models.Provider:
+			if v == nil {
+				redacted[key] = v
+				continue
+			}
+			provider := redactProvider(*v)
+			redacted[key] = &provider
+		case []models.LinkedProvider:
+			linked := make([]models.LinkedProvider, len(v))
+			for i, provider := range v {
+				provider.Settings = redactSettings(provider.Settings)
+				linked[i] = provider
+			}
+			redacted[key] = linked
+		default:
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
+
+// LogTemplateData logs the template data rendered for entity as JSON, with sensitive values redacted
+func LogTemplateData(entity string, data map[string]interface{}) {
+	encoded, err := json.Marshal(RedactTemplateData(data))
+	if err != nil {
+		log.Printf("could not log template data for %s: %v", entity, err)
+		return
+	}
+	log.Printf("template data for %s: %s", entity, encoded)
+}
@@ -0,0 +1,69 @@
+// backend/utils/log_utils_test.go
+
+package utils
+
+import (
+	"backend/models"
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLogTemplateDataRedactsSensitiveValues(t *testing.T) {
+	var out bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	variables := map[string]models.Variable{
+		"client_secret": {Type: "string", Default: "default-secret", Value: "value-secret", Sensitive: true},
+		"location":      {Type: "string", Default: "eastus"},
+	}
+	modules := []models.Module{{
+		ModuleName: "vnet",
+		Variables: map[string]models.ModuleVariable{
+			"shared_key": {Variable: models.Variable{Type: "string", Value: "module-secret", Sensitive: true}},
+		},
+	}}
+	data := map[string]interface{}{
+		"Variables":       variables,
+		"Modules":         modules,
+		"ModuleVariables": map[string]map[string]models.Variable{"vnet": {"shared_key": modules[0].Variables["shared_key"].Variable}},
+		"Backend":         models.Backend{Type: "azurerm", AccessKey: "backend-secret"},
+		"Provider": &models.Provider{
+			Name:          "azurerm",
+			AuthVariables: map[string]string{"client_secret": "auth-secret"},
+			Settings:      map[string]interface{}{"client_certificate_password": "settings-secret"},
+			Environments: map[string]models.ProviderOverride{"prod": {
+				AuthVariables: map[string]string{"client_secret": "prod-auth-secret"},
+				Settings:      map[string]interface{}{"client_certificate_password": "prod-settings-secret"},
+			}},
+		},
+		"LinkedProviders": []models.LinkedProvider{{Name: "kubernetes", Settings: map[string]interface{}{"token": "linked-secret"}}},
+		"Extra":           map[string]interface{}{"api_key": "extra-secret", "nested": map[string]interface{}{"key": "nested-secret"}},
+	}
+
+	LogTemplateData("acme/web", data)
+
+	logged := out.String()
+	for _, secret := range []string{
+		"default-secret", "value-secret", "module-secret", "backend-secret", "auth-secret", "settings-secret",
+		"prod-auth-secret", "prod-settings-secret", "linked-secret", "extra-secret", "nested-secret",
+	} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("log output contains sensitive value %q:\n%s", secret, logged)
+		}
+	}
+	if !strings.Contains(logged, RedactedValue) || !strings.Contains(logged, "eastus") || !strings.Contains(logged, `"api_key":"***"`) {
+		t.Errorf("log output does not show redacted and plain values:\n%s", logged)
+	}
+
+	// The data being rendered keeps its real values
+	provider := data["Provider"].(*models.Provider)
+	if variables["client_secret"].Default != "default-secret" || modules[0].Variables["shared_key"].Value != "module-secret" ||
+		provider.AuthVariables["client_secret"] != "auth-secret" || provider.Environments["prod"].Settings["client_certificate_password"] != "prod-settings-secret" ||
+		data["Extra"].(map[string]interface{})["api_key"] != "extra-secret" {
+		t.Error("redaction modified the template data")
+	}
+}
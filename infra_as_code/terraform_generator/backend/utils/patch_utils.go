@@ -0,0 +1,278 @@
+// backend/utils/patch_utils.go
+
+package utils
+
+import (
+	"backend/models"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens; the empty pointer is the root
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must be empty or start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses token as an index into an array of length n; end allows n itself, where add appends
+func arrayIndex(token string, n int, end bool) (int, error) {
+	if end && token == "-" {
+		return n, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index > n || (index == n && !end) {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+// childValue returns the member or element token refers to in container
+func childValue(container interface{}, token string) (interface{}, error) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		value, ok := c[token]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", token)
+		}
+		return value, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		return c[index], nil
+	default:
+		return nil, fmt.Errorf("cannot reference %q in a scalar value", token)
+	}
+}
+
+// getValue returns the value tokens point to in doc
+func getValue(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		child, err := childValue(doc, token)
+		if err != nil {
+			return nil, err
+		}
+		doc = child
+	}
+	return doc, nil
+}
+
+// updateParent applies update to the container holding the last token and returns doc with the updated container.
+// Arrays may be reallocated by an update, so every container on the way down is rebuilt.
+func updateParent(doc interface{}, tokens []string, update func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return update(doc, tokens[0])
+	}
+	child, err := childValue(doc, tokens[0])
+	if err != nil {
+		return nil, err
+	}
+	updated, err := updateParent(child, tokens[1:], update)
+	if err != nil {
+		return nil, err
+	}
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		c[tokens[0]] = updated
+	case []interface{}:
+		index, _ := arrayIndex(tokens[0], len(c), false)
+		c[index] = updated
+	}
+	return doc, nil
+}
+
+// addValue adds value at tokens, replacing an existing member or inserting into an array
+func addValue(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = value
+			return p, nil
+		case []interface{}:
+			index, err := arrayIndex(key, len(p), true)
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:index], append([]interface{}{value}, p[index:]...)...), nil
+		default:
+			return nil, fmt.Errorf("cannot add %q to a scalar value", key)
+		}
+	})
+}
+
+// removeValue removes the member or element at tokens, which must exist
+func removeValue(doc interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("cannot remove the whole document")
+	}
+	return updateParent(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[key]; !ok {
+				return nil, fmt.Errorf("member %q not found", key)
+			}
+			delete(p, key)
+			return p, nil
+		case []interface{}:
+			index, err := arrayIndex(key, len(p), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:index], p[index+1:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot remove %q from a scalar value", key)
+		}
+	})
+}
+
+// replaceValue overwrites the existing member or element at tokens
+func replaceValue(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+		if _, err := childValue(parent, key); err != nil {
+			return nil, err
+		}
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = value
+			return p, nil
+		default:
+			items := p.([]interface{})
+			index, _ := arrayIndex(key, len(items), false)
+			items[index] = value
+			return items, nil
+		}
+	})
+}
+
+// cloneValue deep copies a decoded JSON value so a copied value never aliases its source
+func cloneValue(value interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var clone interface{}
+	err = json.Unmarshal(encoded, &clone)
+	return clone, err
+}
+
+// ValidatePatch checks that every operation is a known JSON Patch operation with the members it needs
+func ValidatePatch(operations []models.PatchOperation) error {
+	for i, operation := range operations {
+		if _, err := parsePointer(operation.Path); err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+		switch operation.Op {
+		case "add", "replace", "test":
+			if operation.Value == nil {
+				return fmt.Errorf("operation %d: %s requires a value", i, operation.Op)
+			}
+		case "move", "copy":
+			if _, err := parsePointer(operation.From); err != nil {
+				return fmt.Errorf("operation %d: from: %w", i, err)
+			}
+			if operation.Op == "move" && operation.Path != operation.From && strings.HasPrefix(operation.Path+"/", operation.From+"/") {
+				return fmt.Errorf("operation %d: cannot move %q into itself", i, operation.From)
+			}
+		case "remove":
+		default:
+			return fmt.Errorf("operation %d: unsupported op %q", i, operation.Op)
+		}
+	}
+	return nil
+}
+
+// ApplyPatch applies JSON Patch (RFC 6902) operations to a decoded JSON document in order, stopping at the first
+// operation that fails. doc may be modified in place.
+func ApplyPatch(doc interface{}, operations []models.PatchOperation) (interface{}, error) {
+	if err := ValidatePatch(operations); err != nil {
+		return nil, err
+	}
+	for i, operation := range operations {
+		path, _ := parsePointer(operation.Path)
+		from, _ := parsePointer(operation.From)
+
+		var value interface{}
+		if operation.Value != nil {
+			if err := json.Unmarshal(operation.Value, &value); err != nil {
+				return nil, fmt.Errorf("operation %d (%s %s): invalid value: %w", i, operation.Op, operation.Path, err)
+			}
+		}
+
+		var err error
+		switch operation.Op {
+		case "add":
+			doc, err = addValue(doc, path, value)
+		case "remove":
+			doc, err = removeValue(doc, path)
+		case "replace":
+			doc, err = replaceValue(doc, path, value)
+		case "move", "copy":
+			var source interface{}
+			if source, err = getValue(doc, from); err == nil {
+				if source, err = cloneValue(source); err == nil {
+					if operation.Op == "move" {
+						doc, err = removeValue(doc, from)
+					}
+					if err == nil {
+						doc, err = addValue(doc, path, source)
+					}
+				}
+			}
+		case "test":
+			var current interface{}
+			if current, err = getValue(doc, path); err == nil && !reflect.DeepEqual(current, value) {
+				err = fmt.Errorf("value does not match")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// ApplyConfigPatch returns a copy of config with the JSON Patch operations applied to its JSON form; config is unchanged
+func ApplyConfigPatch(config *models.Config, operations []models.PatchOperation) (*models.Config, error) {
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return nil, err
+	}
+	if doc, err = ApplyPatch(doc, operations); err != nil {
+		return nil, err
+	}
+	if encoded, err = json.Marshal(doc); err != nil {
+		return nil, err
+	}
+
+	var patched models.Config
+	if err := json.Unmarshal(encoded, &patched); err != nil {
+		return nil, fmt.Errorf("patched configuration is invalid: %w", err)
+	}
+	return &patched, nil
+}
@@ -0,0 +1,247 @@
+// backend/utils/patch_utils_test.go
+
+package utils
+
+import (
+	"backend/models"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	for pointer, want := range map[string][]string{
+		"":         nil,
+		"/":        {""},
+		"/a/0":     {"a", "0"},
+		"/a~1b":    {"a/b"},
+		"/m~0n":    {"m~n"},
+		"/~01":     {"~1"},
+		"/a/-":     {"a", "-"},
+		"/a//b":    {"a", "", "b"},
+		"/tags/~1": {"tags", "/"},
+	} {
+		got, err := parsePointer(pointer)
+		if err != nil {
+			t.Errorf("parsePointer(%q) error: %v", pointer, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parsePointer(%q) = %q, want %q", pointer, got, want)
+		}
+	}
+
+	if _, err := parsePointer("a/b"); err == nil {
+		t.Error("parsePointer accepted a pointer without a leading /")
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	const doc = `{"a": {"b": 1, "c/d": 2, "e~f": 3}, "list": ["x", "y", "z"]}`
+
+	tests := []struct {
+		name       string
+		operations string
+		want       string // Expected document; empty when the patch fails
+		wantErr    string
+	}{
+		{
+			name:       "add member",
+			operations: `[{"op": "add", "path": "/a/g", "value": 4}]`,
+			want:       `{"a": {"b": 1, "c/d": 2, "e~f": 3, "g": 4}, "list": ["x", "y", "z"]}`,
+		},
+		{
+			name:       "add replaces an existing member",
+			operations: `[{"op": "add", "path": "/a/b", "value": null}]`,
+			want:       `{"a": {"b": null, "c/d": 2, "e~f": 3}, "list": ["x", "y", "z"]}`,
+		},
+		{
+			name:       "add inserts into an array",
+			operations: `[{"op": "add", "path": "/list/1", "value": "w"}]`,
+			want:       `{"a": {"b": 1, "c/d": 2, "e~f": 3}, "list": ["x", "w", "y", "z"]}`,
+		},
+		{
+			name:       "add appends with -",
+			operations: `[{"op": "add", "path": "/list/-", "value": "end"}]`,
+			want:       `{"a": {"b": 1, "c/d": 2, "e~f": 3}, "list": ["x", "y", "z", "end"]}`,
+		},
+		{
+			name:       "add at the array length appends",
+			operations: `[{"op": "add", "path": "/list/3", "value": "end"}]`,
+			want:       `{"a": {"b": 1, "c/d": 2, "e~f": 3}, "list": ["x", "y", "z", "end"]}`,
+		},
+		{
+			name:       "add past the array length",
+			operations: `[{"op": "add", "path": "/list/4", "value": "end"}]`,
+			wantErr:    "out of range",
+		},
+		{
+			name:       "add to a missing parent",
+			operations: `[{"op": "add", "path": "/missing/b", "value": 1}]`,
+			wantErr:    `member "missing" not found`,
+		},
+		{
+			name:       "remove escaped members",
+			operations: `[{"op": "remove", "path": "/a/c~1d"}, {"op": "remove", "path": "/a/e~0f"}]`,
+			want:       `{"a": {"b": 1}, "list": ["x", "y", "z"]}`,
+		},
+		{
+			name:       "remove array element",
+			operations: `[{"op": "remove", "path": "/list/0"}]`,
+			want:       `{"a": {"b": 1, "c/d": 2, "e~f": 3}, "list": ["y", "z"]}`,
+		},
+		{
+			name:       "remove out of range",
+			operations: `[{"op": "remove", "path": "/list/3"}]`,
+			wantErr:    "out of range",
+		},
+		{
+			name:       "remove with -",
+			operations: `[{"op": "remove", "path": "/list/-"}]`,
+			wantErr:    "invalid array index",
+		},
+		{
+			name:       "remove with a leading zero index",
+			operations: `[{"op": "remove", "path": "/list/01"}]`,
+			wantErr:    "invalid array index",
+		},
+		{
+			name:       "remove a missing member",
+			operations: `[{"op": "remove", "path": "/a/missing"}]`,
+			wantErr:    `member "missing" not found`,
+		},
+		{
+			name:       "replace member and element",
+			operations: `[{"op": "replace", "path": "/a/b", "value": {"n": true}}, {"op": "replace", "path": "/list/2", "value": "Z"}]`,
+			want:       `{"a": {"b": {"n": true}, "c/d": 2, "e~f": 3}, "list": ["x", "y", "Z"]}`,
+		},
+		{
+			name:       "replace a missing member",
+			operations: `[{"op": "replace", "path": "/a/missing", "value": 1}]`,
+			wantErr:    `member "missing" not found`,
+		},
+		{
+			name:       "replace the whole document",
+			operations: `[{"op": "replace", "path": "", "value": {"only": 1}}]`,
+			want:       `{"only": 1}`,
+		},
+		{
+			name:       "move member",
+			operations: `[{"op": "move", "from": "/a/b", "path": "/moved"}]`,
+			want:       `{"a": {"c/d": 2, "e~f": 3}, "list": ["x", "y", "z"], "moved": 1}`,
+		},
+		{
+			name:       "move array element",
+			operations: `[{"op": "move", "from": "/list/0", "path": "/list/-"}]`,
+			want:       `{"a": {"b": 1, "c/d": 2, "e~f": 3}, "list": ["y", "z", "x"]}`,
+		},
+		{
+			name:       "move into itself",
+			operations: `[{"op": "move", "from": "/a", "path": "/a/b"}]`,
+			wantErr:    "into itself",
+		},
+		{
+			name:       "copy does not alias its source",
+			operations: `[{"op": "copy", "from": "/a", "path": "/copied"}, {"op": "replace", "path": "/copied/b", "value": 9}]`,
+			want:       `{"a": {"b": 1, "c/d": 2, "e~f": 3}, "copied": {"b": 9, "c/d": 2, "e~f": 3}, "list": ["x", "y", "z"]}`,
+		},
+		{
+			name:       "copy from a missing member",
+			operations: `[{"op": "copy", "from": "/missing", "path": "/copied"}]`,
+			wantErr:    `member "missing" not found`,
+		},
+		{
+			name:       "test passes",
+			operations: `[{"op": "test", "path": "/a/c~1d", "value": 2}, {"op": "test", "path": "/list", "value": ["x", "y", "z"]}]`,
+			want:       doc,
+		},
+		{
+			name:       "test fails",
+			operations: `[{"op": "remove", "path": "/list/0"}, {"op": "test", "path": "/a/b", "value": "1"}]`,
+			wantErr:    "operation 1 (test /a/b): value does not match",
+		},
+		{
+			name:       "test of a missing member",
+			operations: `[{"op": "test", "path": "/list/5", "value": "x"}]`,
+			wantErr:    "out of range",
+		},
+		{
+			name:       "unsupported op",
+			operations: `[{"op": "merge", "path": "/a", "value": 1}]`,
+			wantErr:    `unsupported op "merge"`,
+		},
+		{
+			name:       "add without a value",
+			operations: `[{"op": "add", "path": "/a/g"}]`,
+			wantErr:    "add requires a value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var operations []models.PatchOperation
+			if err := json.Unmarshal([]byte(tt.operations), &operations); err != nil {
+				t.Fatal(err)
+			}
+			var document interface{}
+			if err := json.Unmarshal([]byte(doc), &document); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ApplyPatch(document, operations)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("patched document = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyConfigPatch(t *testing.T) {
+	config := &models.Config{
+		Variables: map[string]models.Variable{"location": {Type: "string", Default: "eastus"}},
+		Modules:   []models.Module{{ModuleName: "vnet"}, {ModuleName: "aks"}},
+	}
+	var operations []models.PatchOperation
+	if err := json.Unmarshal([]byte(`[
+		{"op": "replace", "path": "/variables/location/default", "value": "northeurope"},
+		{"op": "remove", "path": "/modules/0"}
+	]`), &operations); err != nil {
+		t.Fatal(err)
+	}
+
+	patched, err := ApplyConfigPatch(config, operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := patched.Variables["location"].Default; got != "northeurope" {
+		t.Errorf("patched location default = %v, want northeurope", got)
+	}
+	if len(patched.Modules) != 1 || patched.Modules[0].ModuleName != "aks" {
+		t.Errorf("patched modules = %+v, want only aks", patched.Modules)
+	}
+	if config.Variables["location"].Default != "eastus" || len(config.Modules) != 2 {
+		t.Error("ApplyConfigPatch modified the original configuration")
+	}
+
+	// A patch producing a value of the wrong type cannot become a configuration
+	if err := json.Unmarshal([]byte(`[{"op": "replace", "path": "/modules", "value": "none"}]`), &operations); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ApplyConfigPatch(config, operations); err == nil || !strings.Contains(err.Error(), "patched configuration is invalid") {
+		t.Errorf("error = %v, want an invalid configuration", err)
+	}
+}
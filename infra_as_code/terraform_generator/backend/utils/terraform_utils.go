@@ -4,21 +4,44 @@ package utils
 
 import (
 	"backend/models"
+	"encoding/json"
 	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// FilterProviderData filters provider details based on the specified provider name.
-func FilterProviderData(providers []models.Provider, providerName string) *models.Provider {
-	aliases := map[string]string{
-		"azure":   "azurerm",
-		"aws":     "aws",
-		"gcp":     "google",
-		"azurerm": "azurerm",
-		"google":  "google",
+// providerAliases maps accepted provider inputs to their Terraform provider names.
+var providerAliases = map[string]string{
+	"azure":   "azurerm",
+	"aws":     "aws",
+	"gcp":     "google",
+	"azurerm": "azurerm",
+	"google":  "google",
+}
+
+// NormalizeProviderName resolves a provider input to its Terraform provider name.
+func NormalizeProviderName(providerName string) string {
+	return providerAliases[strings.ToLower(providerName)]
+}
+
+// ProviderInputs returns the sorted provider inputs that resolve to a Terraform provider name
+func ProviderInputs(providerName string) []string {
+	var inputs []string
+	for input, name := range providerAliases {
+		if strings.EqualFold(name, providerName) {
+			inputs = append(inputs, input)
+		}
 	}
+	sort.Strings(inputs)
+	return inputs
+}
 
-	normalizedProvider := aliases[strings.ToLower(providerName)]
+// FilterProviderData filters provider details based on the specified provider name.
+func FilterProviderData(providers []models.Provider, providerName string) *models.Provider {
+	normalizedProvider := NormalizeProviderName(providerName)
 
 	for _, provider := range providers {
 		if strings.EqualFold(provider.Name, normalizedProvider) {
@@ -28,26 +51,604 @@ func FilterProviderData(providers []models.Provider, providerName string) *model
 	return nil
 }
 
+// defaultRegistryHost is the host Terraform assumes for provider sources without one.
+const defaultRegistryHost = "registry.terraform.io"
+
+// ResolveProviderSource points a provider source at the given registry host.
+// Sources that already name a host other than the public registry are left untouched.
+func ResolveProviderSource(source, host string) string {
+	if host == "" || source == "" {
+		return source
+	}
+
+	parts := strings.Split(source, "/")
+	switch {
+	case len(parts) == 2:
+		return strings.Join([]string{host, parts[0], parts[1]}, "/")
+	case len(parts) == 3 && strings.EqualFold(parts[0], defaultRegistryHost):
+		return strings.Join([]string{host, parts[1], parts[2]}, "/")
+	default:
+		return source
+	}
+}
+
+// VariableGroup is a set of variable names rendered together under a category header
+type VariableGroup struct {
+	Category string // Empty for the variables without a category
+	Names    []string
+}
+
+// GroupVariables groups variable names by category, ordered by category then name, with uncategorised variables last
+func GroupVariables(variables map[string]models.Variable) []VariableGroup {
+	byCategory := make(map[string][]string)
+	for _, name := range sortedKeys(variables) {
+		category := variables[name].Category
+		byCategory[category] = append(byCategory[category], name)
+	}
+
+	groups := make([]VariableGroup, 0, len(byCategory))
+	for _, category := range sortedKeys(byCategory) {
+		if category != "" {
+			groups = append(groups, VariableGroup{Category: category, Names: byCategory[category]})
+		}
+	}
+	if names, ok := byCategory[""]; ok {
+		groups = append(groups, VariableGroup{Names: names})
+	}
+	return groups
+}
+
+// OrderVariables returns the variable names in tfvars order: the names listed in order that are present, in that
+// order, followed by the remaining names sorted. The result does not depend on how the variables were merged.
+func OrderVariables(variables map[string]models.Variable, order []string) []string {
+	names := make([]string, 0, len(variables))
+	listed := make(map[string]bool, len(order))
+	for _, name := range order {
+		if _, ok := variables[name]; ok && !listed[name] {
+			names = append(names, name)
+		}
+		listed[name] = true
+	}
+	for _, name := range sortedKeys(variables) {
+		if !listed[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ProviderAlias is an aliased provider configuration pinned to one region
+type ProviderAlias struct {
+	Alias  string // The region with dashes replaced by underscores, e.g. us_east_1
+	Region string
+}
+
+// RegionAlias returns the provider alias used for region
+func RegionAlias(region string) string {
+	return strings.ReplaceAll(region, "-", "_")
+}
+
+// ProviderAliases returns an aliased provider configuration per region, in the order given
+func ProviderAliases(regions []string) []ProviderAlias {
+	aliases := make([]ProviderAlias, 0, len(regions))
+	for _, region := range regions {
+		aliases = append(aliases, ProviderAlias{Alias: RegionAlias(region), Region: region})
+	}
+	return aliases
+}
+
+// FilterResourcesByProvider returns the resources that apply to the given provider, in configuration order
+func FilterResourcesByProvider(resources []models.Resource, providerName string) []models.Resource {
+	normalizedProvider := NormalizeProviderName(providerName)
+
+	var filtered []models.Resource
+	for _, resource := range resources {
+		if len(resource.Providers) == 0 {
+			filtered = append(filtered, resource)
+			continue
+		}
+		for _, p := range resource.Providers {
+			if NormalizeProviderName(p) == normalizedProvider {
+				filtered = append(filtered, resource)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// FilterLinkedProviders returns the linked providers rendered alongside the given provider, in configuration order
+func FilterLinkedProviders(linked []models.LinkedProvider, providerName string) []models.LinkedProvider {
+	normalizedProvider := NormalizeProviderName(providerName)
+
+	var filtered []models.LinkedProvider
+	for _, provider := range linked {
+		if len(provider.Providers) == 0 {
+			filtered = append(filtered, provider)
+			continue
+		}
+		for _, p := range provider.Providers {
+			if NormalizeProviderName(p) == normalizedProvider {
+				filtered = append(filtered, provider)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// FilterVariablesByProvider returns the variables that apply to the given provider.
+// A variable without a Providers list applies to every provider.
+func FilterVariablesByProvider(variables map[string]models.Variable, providerName string) map[string]models.Variable {
+	normalizedProvider := NormalizeProviderName(providerName)
+
+	filtered := make(map[string]models.Variable)
+	for name, variable := range variables {
+		if len(variable.Providers) == 0 {
+			filtered[name] = variable
+			continue
+		}
+		for _, p := range variable.Providers {
+			if strings.EqualFold(p, providerName) || (normalizedProvider != "" && NormalizeProviderName(p) == normalizedProvider) {
+				filtered[name] = variable
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// ResolveEnvironmentVariables selects the default for env on variables marked PerEnvironment.
+// Their default is a map keyed by environment; the "default" entry is used when env is not listed.
+// The selected default also becomes the variable's value unless one is set explicitly.
+func ResolveEnvironmentVariables(variables map[string]models.Variable, env string) map[string]models.Variable {
+	resolved := make(map[string]models.Variable, len(variables))
+	for name, variable := range variables {
+		if byEnvironment, ok := variable.Default.(map[string]interface{}); ok && variable.PerEnvironment {
+			selected, found := byEnvironment[env]
+			if !found {
+				selected = byEnvironment["default"]
+			}
+			variable.Default = selected
+			if variable.Value == nil {
+				variable.Value = selected
+			}
+		}
+		resolved[name] = variable
+	}
+	return resolved
+}
+
+// CountVariables returns the per-environment number variables holding the counts of the modules and resources that
+// scale by environment, keyed by their CountVariable names. Their defaults are the count maps, so each environment's
+// vars file gets the number for that environment.
+func CountVariables(modules []models.Module, resources []models.Resource) map[string]models.Variable {
+	variables := make(map[string]models.Variable)
+	add := func(name, description string, count map[string]int) {
+		byEnvironment := make(map[string]interface{}, len(count))
+		for env, instances := range count {
+			byEnvironment[env] = float64(instances)
+		}
+		variables[name] = models.Variable{Type: "number", Description: description, Default: byEnvironment, PerEnvironment: true}
+	}
+	for _, module := range modules {
+		if len(module.Count) > 0 {
+			add(module.CountVariable(), fmt.Sprintf("Number of %s module instances", module.BlockLabel()), module.Count)
+		}
+	}
+	for _, resource := range resources {
+		if len(resource.Count) > 0 {
+			add(resource.CountVariable(), fmt.Sprintf("Number of %s.%s resources", resource.Type, resource.Name), resource.Count)
+		}
+	}
+	return variables
+}
+
+// UncoveredCounts reports the modules and resources whose count has neither an entry for one of envs nor a "default".
+func UncoveredCounts(modules []models.Module, resources []models.Resource, envs []string) []string {
+	var problems []string
+	check := func(address string, count map[string]int) {
+		if _, ok := count["default"]; ok || len(count) == 0 {
+			return
+		}
+		var missing []string
+		for _, env := range envs {
+			if _, ok := count[env]; !ok {
+				missing = append(missing, env)
+			}
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: count has no entry for %s and no default", address, strings.Join(missing, ", ")))
+		}
+	}
+	for _, module := range modules {
+		check("module."+module.BlockLabel(), module.Count)
+	}
+	for _, resource := range resources {
+		check(resource.Type+"."+resource.Name, resource.Count)
+	}
+	return problems
+}
+
+// MissingRequiredVariables returns the sorted names of variables with neither a default nor a value
+func MissingRequiredVariables(variables map[string]models.Variable) []string {
+	var missing []string
+	for _, name := range sortedKeys(variables) {
+		if variables[name].Default == nil && variables[name].Value == nil {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// environmentTag is the default tag set to the environment being generated.
+const environmentTag = "Environment"
+
+// MergeTags combines the configured default tags, the environment tag and the request tags, later sources winning.
+func MergeTags(configTags, requestTags map[string]string, env string) map[string]string {
+	merged := make(map[string]string, len(configTags)+len(requestTags)+1)
+	for key, value := range configTags {
+		merged[key] = value
+	}
+	if env != "" {
+		merged[environmentTag] = env
+	}
+	for key, value := range requestTags {
+		merged[key] = value
+	}
+	return merged
+}
+
+// InferType returns the Terraform type for a decoded JSON value, or an empty string when it cannot be inferred.
+func InferType(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case float64, int:
+		return "number"
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.(string); !ok {
+				return "any"
+			}
+		}
+		return "list(string)"
+	case map[string]interface{}:
+		for _, item := range v {
+			if _, ok := item.(string); !ok {
+				return "any"
+			}
+		}
+		return "map(string)"
+	default:
+		return ""
+	}
+}
+
+// splitTopLevel splits s at the commas that are not nested in brackets, e.g. the attributes of an object type
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+// typeKind splits a type constraint into its kind and the text between its parentheses, e.g. "map" and "list(string)"
+func typeKind(varType string) (string, string) {
+	varType = strings.TrimSpace(varType)
+	open := strings.Index(varType, "(")
+	if open < 0 || !strings.HasSuffix(varType, ")") {
+		return varType, ""
+	}
+	return strings.TrimSpace(varType[:open]), strings.TrimSpace(varType[open+1 : len(varType)-1])
+}
+
+// objectAttributeTypes returns the attribute types of an object type, from its constraint such as
+// "object({ name = string })" or, for a bare "object", from the variable's attributes
+func objectAttributeTypes(argument string, attributes map[string]interface{}) map[string]string {
+	types := make(map[string]string)
+	if argument == "" {
+		for name, attrType := range attributes {
+			if text, ok := attrType.(string); ok {
+				types[name] = text
+			}
+		}
+		return types
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(argument, "{"), "}")
+	for _, attribute := range splitTopLevel(body) {
+		if name, attrType, ok := strings.Cut(attribute, "="); ok {
+			types[strings.TrimSpace(name)] = strings.TrimSpace(attrType)
+		}
+	}
+	return types
+}
+
+// tupleElementTypes returns the element types of a tuple type, from its constraint such as "tuple([string, number])"
+// or, for a bare "tuple", from the variable's tuple_elements attribute
+func tupleElementTypes(argument string, attributes map[string]interface{}) []string {
+	if argument == "" {
+		var types []string
+		elements, _ := attributes["tuple_elements"].([]interface{})
+		for _, element := range elements {
+			types = append(types, fmt.Sprintf("%v", element))
+		}
+		return types
+	}
+	return splitTopLevel(strings.TrimSuffix(strings.TrimPrefix(argument, "["), "]"))
+}
+
+// ValueTypeProblems reports where a decoded JSON value cannot be converted to the Terraform type varType. It follows
+// Terraform's conversions: numbers and bools convert to strings, and numeric or "true"/"false" strings convert back.
+// attributes describes a bare object or tuple type. Null values, var references and unknown types are accepted.
+func ValueTypeProblems(path string, value interface{}, varType string, attributes map[string]interface{}) []string {
+	if expr, ok := value.(string); value == nil || (ok && isReference(expr)) {
+		return nil
+	}
+
+	kind, argument := typeKind(varType)
+	switch kind {
+	case "string":
+		switch value.(type) {
+		case string, float64, bool:
+			return nil
+		}
+	case "number":
+		switch v := value.(type) {
+		case float64:
+			return nil
+		case string:
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return nil
+			}
+		}
+	case "bool":
+		switch v := value.(type) {
+		case bool:
+			return nil
+		case string:
+			if v == "true" || v == "false" {
+				return nil
+			}
+		}
+	case "list", "set":
+		items, ok := value.([]interface{})
+		if !ok {
+			// A single element is coerced to a one-element list, see CoerceVariableValues
+			if isScalar(value) && len(ValueTypeProblems(path, value, argument, nil)) == 0 {
+				return nil
+			}
+			break
+		}
+		var problems []string
+		for i, item := range items {
+			problems = append(problems, ValueTypeProblems(fmt.Sprintf("%s[%d]", path, i), item, argument, nil)...)
+		}
+		return problems
+	case "map":
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		var problems []string
+		for _, key := range sortedKeys(entries) {
+			problems = append(problems, ValueTypeProblems(path+"."+key, entries[key], argument, nil)...)
+		}
+		return problems
+	case "object":
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		types := objectAttributeTypes(argument, attributes)
+		var problems []string
+		for _, key := range sortedKeys(entries) {
+			if attrType, ok := types[key]; ok {
+				problems = append(problems, ValueTypeProblems(path+"."+key, entries[key], attrType, nil)...)
+			}
+		}
+		return problems
+	case "tuple":
+		items, ok := value.([]interface{})
+		if !ok {
+			break
+		}
+		types := tupleElementTypes(argument, attributes)
+		if len(types) > 0 && len(items) != len(types) {
+			return []string{fmt.Sprintf("%s: %s needs %d elements, got %d", path, varType, len(types), len(items))}
+		}
+		var problems []string
+		for i, item := range items {
+			if i < len(types) {
+				problems = append(problems, ValueTypeProblems(fmt.Sprintf("%s[%d]", path, i), item, types[i], nil)...)
+			}
+		}
+		return problems
+	case "optional":
+		// optional(type, default) inside an object type
+		if parts := splitTopLevel(argument); len(parts) > 0 {
+			return ValueTypeProblems(path, value, parts[0], nil)
+		}
+		return nil
+	default:
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: %s is not a valid %s", path, hclLiteral(value), varType)}
+}
+
+// inferVariableType fills in a missing type from the variable's default, or its value when there is no default.
+func inferVariableType(scope, name string, variable models.Variable) models.Variable {
+	if variable.Type != "" {
+		return variable
+	}
+	sample := variable.Default
+	if sample == nil {
+		sample = variable.Value
+	}
+	// Per-environment defaults are keyed by environment; infer from one of the entries
+	if byEnvironment, ok := sample.(map[string]interface{}); ok && variable.PerEnvironment {
+		sample = byEnvironment["default"]
+		for _, env := range sortedKeys(byEnvironment) {
+			if sample != nil {
+				break
+			}
+			sample = byEnvironment[env]
+		}
+	}
+	if inferred := InferType(sample); inferred != "" {
+		variable.Type = inferred
+		log.Printf("inferred type %s for %s %s", inferred, scope, name)
+	}
+	return variable
+}
+
+// InferVariableTypes fills in missing variable types across the generic and module variables of the configuration.
+func InferVariableTypes(config *models.Config) {
+	for name, variable := range config.Variables {
+		config.Variables[name] = inferVariableType("variable", name, variable)
+	}
+	for _, module := range config.Modules {
+		for name, variable := range module.Variables {
+			variable.Variable = inferVariableType("module "+module.BlockLabel()+" variable", name, variable.Variable)
+			module.Variables[name] = variable
+		}
+	}
+}
+
+// CoerceVariableValues wraps a single value given for a list or set variable, a common mistake of leaving out the
+// brackets, in a one-element list across the generic and module variables of the configuration. Values that cannot
+// be coerced are reported by ValidateConfig.
+func CoerceVariableValues(config *models.Config) {
+	for name, variable := range config.Variables {
+		config.Variables[name] = coerceVariable("variable", name, variable)
+	}
+	for _, module := range config.Modules {
+		for name, variable := range module.Variables {
+			variable.Variable = coerceVariable("module "+module.BlockLabel()+" variable", name, variable.Variable)
+			module.Variables[name] = variable
+		}
+	}
+}
+
+// coerceVariable coerces the default, each per-environment default and the value of a variable
+func coerceVariable(scope, name string, variable models.Variable) models.Variable {
+	if variable.Expression {
+		return variable
+	}
+	coerce := func(field string, value interface{}) interface{} {
+		coerced := coerceValue(value, variable.Type)
+		if !reflect.DeepEqual(coerced, value) {
+			log.Printf("coerced %s of %s %s to a one-element %s", field, scope, name, variable.Type)
+		}
+		return coerced
+	}
+
+	if byEnvironment, ok := variable.Default.(map[string]interface{}); ok && variable.PerEnvironment {
+		coerced := make(map[string]interface{}, len(byEnvironment))
+		for env, value := range byEnvironment {
+			coerced[env] = coerce("default."+env, value)
+		}
+		variable.Default = coerced
+	} else {
+		variable.Default = coerce("default", variable.Default)
+	}
+	variable.Value = coerce("value", variable.Value)
+	return variable
+}
+
+// coerceValue wraps a scalar in a one-element list when varType is a list or set type. References are left alone,
+// since they may point at a list.
+func coerceValue(value interface{}, varType string) interface{} {
+	if kind, _ := typeKind(varType); kind != "list" && kind != "set" {
+		return value
+	}
+	if expr, ok := value.(string); !isScalar(value) || (ok && isReference(expr)) {
+		return value
+	}
+	return []interface{}{value}
+}
+
+// isScalar reports whether a decoded JSON value is a string, number or bool
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, float64, bool:
+		return true
+	}
+	return false
+}
+
+// ParseVariableValue converts raw text, such as a query parameter, to a value of the Terraform type varType and
+// reports problems under path. Strings are taken as they are, numbers and bools are parsed, and other types are read
+// as JSON, e.g. ["a","b"]. A single element is accepted for a list or set, as in the configuration.
+func ParseVariableValue(path, raw, varType string) (interface{}, error) {
+	var value interface{} = raw
+	switch kind, _ := typeKind(varType); kind {
+	case "", "string":
+	case "number":
+		if number, err := strconv.ParseFloat(raw, 64); err == nil {
+			value = number
+		}
+	case "bool":
+		if raw == "true" || raw == "false" {
+			value = raw == "true"
+		}
+	default:
+		// Text that is not JSON is kept as a string, a single element of a list of strings
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(raw), &decoded); err == nil {
+			value = decoded
+		}
+	}
+
+	if problems := ValueTypeProblems(path, value, varType, nil); len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return coerceValue(value, varType), nil
+}
+
 // ResolveModuleDependencies resolves all dependencies for the requested modules.
 func ResolveModuleDependencies(requestedModules []string, availableModules []models.Module) ([]models.Module, error) {
 	moduleMap := make(map[string]models.Module)
+	instances := make(map[string][]string)
 	for _, module := range availableModules {
-		moduleMap[module.ModuleName] = module
+		label := module.BlockLabel()
+		if _, exists := moduleMap[label]; exists {
+			return nil, fmt.Errorf("duplicate module label '%s'", label)
+		}
+		moduleMap[label] = module
+		instances[module.ModuleName] = append(instances[module.ModuleName], label)
 	}
 
 	visited := make(map[string]bool)
 	var resolved []models.Module
 
 	var resolve func(string) error
-	resolve = func(moduleName string) error {
-		if visited[moduleName] {
+	resolve = func(label string) error {
+		if visited[label] {
 			return nil
 		}
-		visited[moduleName] = true
+		visited[label] = true
 
-		module, exists := moduleMap[moduleName]
+		module, exists := moduleMap[label]
 		if !exists {
-			return fmt.Errorf("module '%s' not found in available modules", moduleName)
+			return fmt.Errorf("module '%s' not found in available modules", label)
 		}
 
 		// Resolve dependencies first
@@ -63,8 +664,15 @@ func ResolveModuleDependencies(requestedModules []string, availableModules []mod
 	}
 
 	for _, moduleName := range requestedModules {
-		if err := resolve(moduleName); err != nil {
-			return nil, err
+		// A module name without a matching label selects every labelled instance of that module
+		labels := []string{moduleName}
+		if _, exists := moduleMap[moduleName]; !exists && len(instances[moduleName]) > 0 {
+			labels = instances[moduleName]
+		}
+		for _, label := range labels {
+			if err := resolve(label); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -0,0 +1,296 @@
+// backend/utils/terraform_utils_test.go
+
+package utils
+
+import (
+	"backend/models"
+	"reflect"
+	"testing"
+)
+
+func TestMergeTags(t *testing.T) {
+	configTags := map[string]string{"Owner": "platform", "CostCentre": "1234"}
+
+	tests := []struct {
+		name        string
+		requestTags map[string]string
+		env         string
+		want        map[string]string
+	}{
+		{
+			name: "environment injected",
+			env:  "prod",
+			want: map[string]string{"Owner": "platform", "CostCentre": "1234", "Environment": "prod"},
+		},
+		{
+			name:        "request overrides config",
+			requestTags: map[string]string{"Owner": "payments", "Product": "checkout"},
+			env:         "nonprod",
+			want:        map[string]string{"Owner": "payments", "CostCentre": "1234", "Product": "checkout", "Environment": "nonprod"},
+		},
+		{
+			name:        "request overrides environment",
+			requestTags: map[string]string{"Environment": "staging"},
+			env:         "nonprod",
+			want:        map[string]string{"Owner": "platform", "CostCentre": "1234", "Environment": "staging"},
+		},
+		{
+			name: "no environment",
+			want: map[string]string{"Owner": "platform", "CostCentre": "1234"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergeTags(configTags, tt.requestTags, tt.env)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("MergeTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := configTags["Environment"]; ok {
+		t.Fatal("MergeTags() modified the configured tags")
+	}
+}
+
+func TestResolveProviderSource(t *testing.T) {
+	tests := []struct {
+		source string
+		host   string
+		want   string
+	}{
+		{source: "hashicorp/azurerm", host: "", want: "hashicorp/azurerm"},
+		{source: "", host: "registry.internal", want: ""},
+		{source: "hashicorp/azurerm", host: "registry.internal", want: "registry.internal/hashicorp/azurerm"},
+		{source: "registry.terraform.io/hashicorp/aws", host: "registry.internal", want: "registry.internal/hashicorp/aws"},
+		{source: "Registry.Terraform.io/hashicorp/aws", host: "registry.internal", want: "registry.internal/hashicorp/aws"},
+		// Sources already pointing at another registry are kept
+		{source: "app.terraform.io/acme/network", host: "registry.internal", want: "app.terraform.io/acme/network"},
+		{source: "azurerm", host: "registry.internal", want: "azurerm"},
+	}
+	for _, tt := range tests {
+		if got := ResolveProviderSource(tt.source, tt.host); got != tt.want {
+			t.Errorf("ResolveProviderSource(%q, %q) = %q, want %q", tt.source, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestFilterVariablesByProvider(t *testing.T) {
+	variables := map[string]models.Variable{
+		"location":     {},
+		"subscription": {Providers: []string{"azurerm"}},
+		"aws_region":   {Providers: []string{"AWS"}},
+		"project":      {Providers: []string{"gcp", "aws"}},
+		"legacy":       {Providers: []string{"oci"}},
+	}
+
+	tests := []struct {
+		provider string
+		want     []string
+	}{
+		{provider: "azure", want: []string{"location", "subscription"}},
+		{provider: "azurerm", want: []string{"location", "subscription"}},
+		{provider: "aws", want: []string{"aws_region", "location", "project"}},
+		{provider: "google", want: []string{"location", "project"}},
+		// A provider without an alias still matches its own name
+		{provider: "OCI", want: []string{"legacy", "location"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			if got := sortedKeys(FilterVariablesByProvider(variables, tt.provider)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterVariablesByProvider(%s) = %q, want %q", tt.provider, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoerceVariableValues(t *testing.T) {
+	config := &models.Config{
+		Variables: map[string]models.Variable{
+			"zones":   {Type: "list(string)", Default: "1", Value: []interface{}{"1", "2"}},
+			"ports":   {Type: "set(number)", Value: float64(443)},
+			"subnets": {Type: "list(string)", Value: "var.subnets"},
+			"name":    {Type: "string", Value: "web"},
+			"cidrs":   {Type: "list(string)", PerEnvironment: true, Default: map[string]interface{}{"prod": "10.0.0.0/16"}},
+		},
+	}
+	CoerceVariableValues(config)
+
+	want := map[string]models.Variable{
+		"zones":   {Type: "list(string)", Default: []interface{}{"1"}, Value: []interface{}{"1", "2"}},
+		"ports":   {Type: "set(number)", Value: []interface{}{float64(443)}},
+		"subnets": {Type: "list(string)", Value: "var.subnets"},
+		"name":    {Type: "string", Value: "web"},
+		"cidrs":   {Type: "list(string)", PerEnvironment: true, Default: map[string]interface{}{"prod": []interface{}{"10.0.0.0/16"}}},
+	}
+	if !reflect.DeepEqual(config.Variables, want) {
+		t.Fatalf("CoerceVariableValues() = %v, want %v", config.Variables, want)
+	}
+}
+
+func TestValueTypeProblems(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      interface{}
+		varType    string
+		attributes map[string]interface{}
+		want       []string
+	}{
+		{name: "number", value: float64(3), varType: "number"},
+		{name: "numeric string converts", value: "3.5", varType: "number"},
+		{name: "string for number", value: "abc", varType: "number", want: []string{`v: "abc" is not a valid number`}},
+		{name: "bool string converts", value: "true", varType: "bool"},
+		{name: "number for bool", value: float64(1), varType: "bool", want: []string{"v: 1 is not a valid bool"}},
+		{name: "scalars convert to string", value: true, varType: "string"},
+		{name: "list for string", value: []interface{}{"a"}, varType: "string", want: []string{`v: ["a"] is not a valid string`}},
+		{name: "null", value: nil, varType: "number"},
+		{name: "reference", value: "var.count", varType: "number"},
+		{name: "list elements", value: []interface{}{float64(1), "x"}, varType: "list(number)", want: []string{`v[1]: "x" is not a valid number`}},
+		{name: "string for list coerces", value: "a", varType: "list(string)"},
+		{name: "scalar for list elements", value: "abc", varType: "set(number)", want: []string{`v: "abc" is not a valid set(number)`}},
+		{name: "map for list", value: map[string]interface{}{"a": "b"}, varType: "list(string)", want: []string{`v: { "a" = "b" } is not a valid list(string)`}},
+		{name: "map values", value: map[string]interface{}{"a": "1", "b": []interface{}{}}, varType: "map(string)", want: []string{"v.b: [] is not a valid string"}},
+		{
+			name:    "object attributes",
+			value:   map[string]interface{}{"name": "vm", "size": "large", "extra": true},
+			varType: "object({ name = string, size = optional(number, 1) })",
+			want:    []string{`v.size: "large" is not a valid number`},
+		},
+		{
+			name:       "bare object attributes",
+			value:      map[string]interface{}{"enabled": "yes"},
+			varType:    "object",
+			attributes: map[string]interface{}{"enabled": "bool"},
+			want:       []string{`v.enabled: "yes" is not a valid bool`},
+		},
+		{name: "tuple elements", value: []interface{}{"a", "b"}, varType: "tuple([string, number])", want: []string{`v[1]: "b" is not a valid number`}},
+		{name: "tuple length", value: []interface{}{"a"}, varType: "tuple([string, number])", want: []string{"v: tuple([string, number]) needs 2 elements, got 1"}},
+		{name: "unknown type", value: "anything", varType: "any"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValueTypeProblems("v", tt.value, tt.varType, tt.attributes)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ValueTypeProblems() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVariableValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		varType string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "string", raw: "westeurope", varType: "string", want: "westeurope"},
+		{name: "untyped", raw: "42", want: "42"},
+		{name: "number", raw: "3.5", varType: "number", want: float64(3.5)},
+		{name: "invalid number", raw: "abc", varType: "number", wantErr: `v: "abc" is not a valid number`},
+		{name: "bool", raw: "false", varType: "bool", want: false},
+		{name: "invalid bool", raw: "yes", varType: "bool", wantErr: `v: "yes" is not a valid bool`},
+		{name: "list", raw: `["a","b"]`, varType: "list(string)", want: []interface{}{"a", "b"}},
+		{name: "single element list", raw: "a", varType: "list(string)", want: []interface{}{"a"}},
+		{name: "invalid list element", raw: `[1,"x"]`, varType: "list(number)", wantErr: `v[1]: "x" is not a valid number`},
+		{name: "map", raw: `{"env":"prod"}`, varType: "map(string)", want: map[string]interface{}{"env": "prod"}},
+		{name: "invalid map", raw: "prod", varType: "map(string)", wantErr: `v: "prod" is not a valid map(string)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVariableValue("v", tt.raw, tt.varType)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseVariableValue() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVariableValue() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseVariableValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountVariables(t *testing.T) {
+	modules := []models.Module{{ModuleName: "vnet", Count: map[string]int{"prod": 3, "default": 1}}, {ModuleName: "aks"}}
+	resources := []models.Resource{{Type: "azurerm_public_ip", Name: "egress", Count: map[string]int{"prod": 2, "nonprod": 0}}}
+
+	want := map[string]models.Variable{
+		"vnet_count": {
+			Type: "number", Description: "Number of vnet module instances", PerEnvironment: true,
+			Default: map[string]interface{}{"prod": float64(3), "default": float64(1)},
+		},
+		"azurerm_public_ip_egress_count": {
+			Type: "number", Description: "Number of azurerm_public_ip.egress resources", PerEnvironment: true,
+			Default: map[string]interface{}{"prod": float64(2), "nonprod": float64(0)},
+		},
+	}
+	got := CountVariables(modules, resources)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("CountVariables() = %+v, want %+v", got, want)
+	}
+
+	// Each environment's vars file gets its own number, and an environment without an entry the default
+	resolved := ResolveEnvironmentVariables(got, "staging")
+	if resolved["vnet_count"].Value != float64(1) {
+		t.Errorf("vnet_count in staging = %v, want the default 1", resolved["vnet_count"].Value)
+	}
+	if resolved = ResolveEnvironmentVariables(got, "prod"); resolved["vnet_count"].Value != float64(3) || resolved["azurerm_public_ip_egress_count"].Value != float64(2) {
+		t.Errorf("counts in prod = %v and %v, want 3 and 2", resolved["vnet_count"].Value, resolved["azurerm_public_ip_egress_count"].Value)
+	}
+}
+
+func TestUncoveredCounts(t *testing.T) {
+	modules := []models.Module{
+		{ModuleName: "vnet", Count: map[string]int{"prod": 3}},
+		{ModuleName: "aks", Count: map[string]int{"default": 1}},
+		{ModuleName: "dns"},
+	}
+	resources := []models.Resource{{Type: "azurerm_public_ip", Name: "egress", Count: map[string]int{"prod": 2, "nonprod": 1}}}
+
+	got := UncoveredCounts(modules, resources, []string{"nonprod", "staging", "prod"})
+	want := []string{
+		"module.vnet: count has no entry for nonprod, staging and no default",
+		"azurerm_public_ip.egress: count has no entry for staging and no default",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("UncoveredCounts() = %q, want %q", got, want)
+	}
+	if got := UncoveredCounts(modules, resources, []string{"prod"}); len(got) != 0 {
+		t.Errorf("UncoveredCounts(prod) = %q, want none", got)
+	}
+}
+
+func TestOrderVariables(t *testing.T) {
+	variables := map[string]models.Variable{"sku": {}, "location": {}, "tags": {}, "environment": {}}
+
+	tests := []struct {
+		name  string
+		order []string
+		want  []string
+	}{
+		{name: "by name", order: nil, want: []string{"environment", "location", "sku", "tags"}},
+		{name: "listed first", order: []string{"tags", "location"}, want: []string{"tags", "location", "environment", "sku"}},
+		{name: "all listed", order: []string{"sku", "tags", "location", "environment"}, want: []string{"sku", "tags", "location", "environment"}},
+		// Variables a customer or environment lacks are skipped, and a repeated name keeps its first place
+		{name: "absent and repeated", order: []string{"vnet_cidr", "tags", "tags", "sku"}, want: []string{"tags", "sku", "environment", "location"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OrderVariables(variables, tt.order); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OrderVariables(%q) = %q, want %q", tt.order, got, tt.want)
+			}
+		})
+	}
+	if got := OrderVariables(nil, []string{"sku"}); len(got) != 0 {
+		t.Errorf("OrderVariables(nil) = %q, want none", got)
+	}
+}
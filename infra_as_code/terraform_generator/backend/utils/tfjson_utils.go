@@ -0,0 +1,414 @@
+// backend/utils/tfjson_utils.go
+
+package utils
+
+import (
+	"backend/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// reference wraps a Terraform expression as a JSON syntax template
+func reference(expr string) string {
+	return "${" + expr + "}"
+}
+
+// jsonExpression converts a config value for JSON configuration syntax, detecting references the same way formatValue does
+func jsonExpression(value interface{}, varType string, literal bool) interface{} {
+	if expr, ok := value.(string); ok {
+		switch varType {
+		case "bool", "number", "string":
+			if isReference(expr) {
+				return reference(expr)
+			}
+		case "list(string)", "set(string)", "map(string)":
+		default:
+			if traversalPattern.MatchString(expr) {
+				return reference(expr)
+			}
+		}
+	}
+	return jsonValue(value, literal)
+}
+
+// variableExpression converts a variable's value for a module argument in JSON configuration syntax, wrapping it as
+// a template when the variable is marked as an expression
+func variableExpression(value interface{}, varDef models.Variable) interface{} {
+	if expr, ok := value.(string); ok && varDef.Expression {
+		return reference(expr)
+	}
+	return jsonExpression(value, varDef.Type, varDef.LiteralDollar)
+}
+
+// jsonValue converts a literal value for JSON configuration syntax, where every string is a template
+func jsonValue(value interface{}, literal bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		if literal {
+			return escapeTemplate(v)
+		}
+		return v
+	case []interface{}:
+		items := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			items = append(items, jsonValue(item, literal))
+		}
+		return items
+	case map[string]interface{}:
+		entries := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			entries[key] = jsonValue(item, literal)
+		}
+		return entries
+	default:
+		return v
+	}
+}
+
+// resourceBody converts resource arguments for JSON syntax, where nested blocks are arrays of objects
+func resourceBody(arguments map[string]interface{}) map[string]interface{} {
+	body := make(map[string]interface{}, len(arguments))
+	for key, value := range arguments {
+		if blocks, ok := isBlockList(value); ok {
+			nested := make([]interface{}, 0, len(blocks))
+			for _, block := range blocks {
+				nested = append(nested, resourceBody(block))
+			}
+			body[key] = nested
+			continue
+		}
+		body[key] = jsonExpression(value, "", false)
+	}
+	return body
+}
+
+// ResourcesJSON builds the JSON syntax equivalent of resources.tf
+func ResourcesJSON(resources []models.Resource) map[string]interface{} {
+	byType := make(map[string]interface{})
+	for _, resource := range resources {
+		named, ok := byType[resource.Type].(map[string]interface{})
+		if !ok {
+			named = make(map[string]interface{})
+			byType[resource.Type] = named
+		}
+		body := resourceBody(resource.Arguments)
+		if len(resource.Count) > 0 {
+			body["count"] = "${var." + resource.CountVariable() + "}"
+		}
+		named[resource.Name] = body
+	}
+	return map[string]interface{}{"resource": byType}
+}
+
+// RemovedJSON builds the JSON syntax equivalent of removed.tf, where each removed block is an array element
+func RemovedJSON(removed []models.RemovedBlock) map[string]interface{} {
+	blocks := make([]interface{}, 0, len(removed))
+	for _, block := range removed {
+		blocks = append(blocks, map[string]interface{}{
+			"from":      block.From,
+			"lifecycle": map[string]interface{}{"destroy": block.Destroy},
+		})
+	}
+	return map[string]interface{}{"removed": blocks}
+}
+
+// ProvidersJSON builds the JSON syntax equivalent of providers.tf. With aliases the provider becomes an array of
+// configurations: the default one followed by an aliased one per region.
+func ProvidersJSON(provider models.Provider, linked []models.LinkedProvider, terraformVersion string, defaultTags map[string]string, aliases []ProviderAlias) map[string]interface{} {
+	var providerBlock interface{} = providerJSON(provider, defaultTags, ProviderAlias{})
+	if len(aliases) > 0 {
+		blocks := []interface{}{providerBlock}
+		for _, alias := range aliases {
+			blocks = append(blocks, providerJSON(provider, defaultTags, alias))
+		}
+		providerBlock = blocks
+	}
+
+	requiredProviders := map[string]interface{}{
+		provider.Name: map[string]interface{}{
+			"source":  provider.Source,
+			"version": provider.Version,
+		},
+	}
+	providerBlocks := map[string]interface{}{
+		provider.Name: providerBlock,
+	}
+	for _, linkedProvider := range linked {
+		requiredProviders[linkedProvider.Name] = map[string]interface{}{
+			"source":  linkedProvider.Source,
+			"version": linkedProvider.Version,
+		}
+		providerBlocks[linkedProvider.Name] = resourceBody(linkedProvider.Settings)
+	}
+
+	return map[string]interface{}{
+		"terraform": map[string]interface{}{
+			"required_providers": requiredProviders,
+			"required_version":   terraformVersion,
+		},
+		"provider": providerBlocks,
+	}
+}
+
+// providerJSON builds a single provider configuration; an alias pins the configuration to its region
+func providerJSON(provider models.Provider, defaultTags map[string]string, alias ProviderAlias) map[string]interface{} {
+	block := map[string]interface{}{}
+	if alias.Alias != "" {
+		block["alias"] = alias.Alias
+	}
+	switch provider.Name {
+	case "azurerm":
+		block["features"] = jsonValue(provider.Features, false)
+		if provider.Features == nil {
+			block["features"] = map[string]interface{}{}
+		}
+		block["subscription_id"] = reference("var.azure_subscription_id")
+		block["tenant_id"] = reference("var.azure_tenant_id")
+		block["client_id"] = reference("var.azure_client_id")
+		if provider.AuthVariables["client_secret"] != "" {
+			block["client_secret"] = reference("var.azure_client_secret")
+		}
+	case "aws":
+		block["region"] = reference("var.aws_region")
+		if alias.Region != "" {
+			block["region"] = alias.Region
+		}
+		if role := provider.AssumeRole; role != nil {
+			if role.SAMLProviderARN != "" {
+				block["//"] = fmt.Sprintf("Federated through %s; refresh the %s profile with your SAML credential helper first", role.SAMLProviderARN, role.Profile)
+			}
+			if role.Profile != "" {
+				block["profile"] = role.Profile
+			}
+			assumeRole := map[string]interface{}{"role_arn": role.RoleARN, "session_name": role.Session()}
+			if role.ExternalID != "" {
+				assumeRole["external_id"] = role.ExternalID
+			}
+			block["assume_role"] = assumeRole
+		} else if provider.AuthVariables["web_identity_token_file"] != "" {
+			block["assume_role_with_web_identity"] = map[string]interface{}{
+				"role_arn":                reference("var.aws_role_arn"),
+				"web_identity_token_file": reference("var.aws_oidc_token_file"),
+				"session_name":            "terraform-session",
+			}
+		} else {
+			block["access_key"] = reference("var.aws_access_key")
+			block["secret_key"] = reference("var.aws_secret_key")
+		}
+		if retry := provider.Retry; retry != nil {
+			if retry.MaxRetries != nil {
+				block["max_retries"] = *retry.MaxRetries
+			}
+			if retry.Mode != "" {
+				block["retry_mode"] = retry.Mode
+			}
+		}
+		if len(defaultTags) > 0 {
+			block["default_tags"] = map[string]interface{}{"tags": defaultTags}
+		}
+	case "google":
+		block["project"] = reference("var.gcp_project_id")
+		block["region"] = reference("var.gcp_region")
+		if alias.Region != "" {
+			block["region"] = alias.Region
+		}
+		if provider.AuthVariables["workload_identity_pool_provider"] != "" {
+			block["impersonate_service_account"] = reference("var.gcp_service_account_email")
+			block["workload_identity_pool_provider"] = reference("var.gcp_workload_identity_provider")
+		} else {
+			block["credentials"] = reference("file(var.gcp_credentials_file)")
+		}
+		if retry := provider.Retry; retry != nil && retry.RequestTimeout != "" {
+			block["request_timeout"] = retry.RequestTimeout
+		}
+	}
+	for key, value := range provider.Settings {
+		block[key] = jsonExpression(value, "", false)
+	}
+	return block
+}
+
+// MainJSON builds the JSON syntax equivalent of main.tf with a block per module
+func MainJSON(modules []models.Module, moduleVariables map[string]map[string]models.Variable) map[string]interface{} {
+	moduleBlocks := make(map[string]interface{}, len(modules))
+	for _, module := range modules {
+		block := map[string]interface{}{"source": module.Source}
+		if len(module.Count) > 0 {
+			block["count"] = "${var." + module.CountVariable() + "}"
+		}
+		for name, variable := range moduleVariables[module.BlockLabel()] {
+			block[name] = variableExpression(variable.Value, variable)
+		}
+		if len(module.DependsOn) > 0 {
+			dependencies := make([]string, 0, len(module.DependsOn))
+			for _, dependency := range module.DependsOn {
+				dependencies = append(dependencies, "module."+dependency)
+			}
+			block["depends_on"] = dependencies
+		}
+		moduleBlocks[module.BlockLabel()] = block
+	}
+
+	main := map[string]interface{}{}
+	if len(moduleBlocks) > 0 {
+		main["module"] = moduleBlocks
+	}
+	return main
+}
+
+// BackendJSON builds the JSON syntax equivalent of backend.tf.tmpl: a terraform block declaring the backend as an
+// empty, partial configuration whose settings come from the backend tfvars passed to terraform init
+func BackendJSON(backend models.Backend) map[string]interface{} {
+	backendType := backend.Type
+	if backendType == "" {
+		backendType = "azurerm"
+	}
+	return map[string]interface{}{
+		"terraform": map[string]interface{}{
+			"backend": map[string]interface{}{
+				backendType: map[string]interface{}{},
+			},
+		},
+	}
+}
+
+// CloudJSON builds the JSON syntax equivalent of terraform.tf with the HCP Terraform cloud block
+func CloudJSON(cloud models.Cloud) map[string]interface{} {
+	workspaces := map[string]interface{}{}
+	if cloud.Workspaces.Name != "" {
+		workspaces["name"] = cloud.Workspaces.Name
+	} else {
+		workspaces["tags"] = cloud.Workspaces.Tags
+	}
+	if cloud.Workspaces.Project != "" {
+		workspaces["project"] = cloud.Workspaces.Project
+	}
+
+	block := map[string]interface{}{
+		"organization": cloud.Organization,
+		"workspaces":   workspaces,
+	}
+	if cloud.Hostname != "" {
+		block["hostname"] = cloud.Hostname
+	}
+	return map[string]interface{}{
+		"terraform": map[string]interface{}{"cloud": block},
+	}
+}
+
+// VariablesJSON builds the JSON syntax equivalent of variables.tf
+func VariablesJSON(variables map[string]models.Variable) map[string]interface{} {
+	blocks := make(map[string]interface{}, len(variables))
+	for name, varDef := range variables {
+		description := varDef.Description
+		if description == "" {
+			description = "No description provided"
+		}
+		block := map[string]interface{}{
+			"description": description,
+			"type":        formatType(varDef.Type, varDef.Attributes),
+		}
+		if varDef.Default != nil {
+			block["default"] = jsonExpression(varDef.Default, varDef.Type, varDef.LiteralDollar)
+		}
+		if varDef.Sensitive {
+			block["sensitive"] = true
+		}
+		if varDef.Nullable != nil {
+			block["nullable"] = *varDef.Nullable
+		}
+		if varDef.Deprecated != "" {
+			// "//" is the JSON syntax comment property
+			block["//"] = "DEPRECATED: " + varDef.Deprecated
+		}
+		if varDef.Validation != nil {
+			block["validation"] = map[string]interface{}{
+				"condition":     reference(varDef.Validation.Condition),
+				"error_message": varDef.Validation.ErrorMessage,
+			}
+		}
+		blocks[name] = block
+	}
+	return map[string]interface{}{"variable": blocks}
+}
+
+// TfvarsJSON builds a .tfvars.json document from the variable values; values in tfvars files are never interpolated
+func TfvarsJSON(variables map[string]models.Variable) map[string]interface{} {
+	values := make(map[string]interface{}, len(variables))
+	for name, varDef := range variables {
+		if varDef.Value != nil {
+			values[name] = varDef.Value
+		}
+	}
+	return values
+}
+
+// CDKTFConfig builds a cdktf.json declaring the provider, any linked providers and the generated modules for `cdktf get`
+func CDKTFConfig(provider models.Provider, linked []models.LinkedProvider, modules []models.Module) map[string]interface{} {
+	providerConstraints := []string{providerConstraint(provider.Source, provider.Version)}
+	for _, linkedProvider := range linked {
+		providerConstraints = append(providerConstraints, providerConstraint(linkedProvider.Source, linkedProvider.Version))
+	}
+
+	var moduleEntries []map[string]string
+	seen := make(map[string]bool)
+	for _, module := range modules {
+		if seen[module.ModuleName] {
+			continue
+		}
+		seen[module.ModuleName] = true
+		moduleEntries = append(moduleEntries, map[string]string{"name": module.ModuleName, "source": module.Source})
+	}
+
+	config := map[string]interface{}{
+		"language":           "typescript",
+		"app":                "npx ts-node main.ts",
+		"terraformProviders": providerConstraints,
+		"context":            map[string]interface{}{},
+	}
+	if len(moduleEntries) > 0 {
+		config["terraformModules"] = moduleEntries
+	}
+	return config
+}
+
+// providerConstraint formats a provider for cdktf.json as <source>@<version>, or the source alone when unpinned
+func providerConstraint(source, version string) string {
+	if version == "" {
+		return source
+	}
+	return source + "@" + version
+}
+
+// MergeJSONDocuments combines JSON configuration documents into one, merging objects present in several, such as the
+// terraform block. Nil documents are skipped; for any other clash the later document wins.
+func MergeJSONDocuments(documents ...map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for _, document := range documents {
+		for key, value := range document {
+			existing, ok := merged[key].(map[string]interface{})
+			if incoming, isMap := value.(map[string]interface{}); ok && isMap {
+				value = MergeJSONDocuments(existing, incoming)
+			}
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// WriteJSONFile writes value as indented JSON and reports what was written
+func WriteJSONFile(path string, value interface{}, opts GenerateOptions) (models.FileResult, error) {
+	// Terraform expressions such as "~> 3.0" must not be HTML-escaped
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return models.FileResult{}, err
+	}
+	if err := ensureDir(path, opts); err != nil {
+		return models.FileResult{}, err
+	}
+	return WriteFileWithResult(path, content.Bytes(), opts)
+}
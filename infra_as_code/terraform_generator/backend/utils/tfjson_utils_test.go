@@ -0,0 +1,91 @@
+// backend/utils/tfjson_utils_test.go
+
+package utils
+
+import (
+	"backend/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeJSONDocuments(t *testing.T) {
+	providers := map[string]interface{}{
+		"terraform": map[string]interface{}{"required_version": ">= 1.5.0"},
+		"provider":  map[string]interface{}{"azurerm": []interface{}{map[string]interface{}{"features": map[string]interface{}{}}}},
+	}
+	backend := BackendJSON(models.Backend{Type: "s3"})
+	main := map[string]interface{}{
+		"module":   map[string]interface{}{"vnet": map[string]interface{}{"source": "./modules/vnet"}},
+		"provider": "replaced",
+	}
+
+	got := MergeJSONDocuments(providers, nil, backend, main)
+	want := map[string]interface{}{
+		"terraform": map[string]interface{}{
+			"required_version": ">= 1.5.0",
+			"backend":          map[string]interface{}{"s3": map[string]interface{}{}},
+		},
+		"provider": "replaced",
+		"module":   map[string]interface{}{"vnet": map[string]interface{}{"source": "./modules/vnet"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeJSONDocuments() = %v, want %v", got, want)
+	}
+
+	// The documents merged are left as they were
+	if _, ok := providers["terraform"].(map[string]interface{})["backend"]; ok {
+		t.Error("MergeJSONDocuments() modified its first document")
+	}
+	if got := MergeJSONDocuments(); len(got) != 0 {
+		t.Errorf("MergeJSONDocuments() of nothing = %v, want an empty document", got)
+	}
+}
+
+func TestBackendJSON(t *testing.T) {
+	for backendType, want := range map[string]string{"": "azurerm", "gcs": "gcs", "local": "local"} {
+		got := BackendJSON(models.Backend{Type: backendType, Bucket: "state"})
+		block := got["terraform"].(map[string]interface{})["backend"].(map[string]interface{})
+		if settings, ok := block[want].(map[string]interface{}); !ok || len(block) != 1 || len(settings) != 0 {
+			t.Errorf("BackendJSON(%q) = %v, want an empty %s block", backendType, got, want)
+		}
+	}
+}
+
+func TestRemovedJSON(t *testing.T) {
+	got := RemovedJSON([]models.RemovedBlock{
+		{From: "module.legacy_vnet", Destroy: true},
+		{From: "azurerm_resource_group.old"},
+	})
+	want := map[string]interface{}{"removed": []interface{}{
+		map[string]interface{}{"from": "module.legacy_vnet", "lifecycle": map[string]interface{}{"destroy": true}},
+		map[string]interface{}{"from": "azurerm_resource_group.old", "lifecycle": map[string]interface{}{"destroy": false}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RemovedJSON() = %v, want %v", got, want)
+	}
+
+	// Nothing removed is still an array, never null
+	if got := RemovedJSON(nil); !reflect.DeepEqual(got, map[string]interface{}{"removed": []interface{}{}}) {
+		t.Errorf("RemovedJSON(nil) = %v, want an empty array", got)
+	}
+}
+
+func TestWriteJSONFileKeepsExpressions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.tf.json")
+	value := map[string]interface{}{"version": "~> 3.0", "condition": "${var.count > 0 && var.name != \"<none>\"}"}
+	if _, err := WriteJSONFile(path, value, GenerateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"~> 3.0"`, `"${var.count > 0 && var.name != \"<none>\"}"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("WriteJSONFile() wrote %s, want it to contain %s", content, want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// backend/utils/vault_utils.go
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// vaultReferenceKey marks a configuration value read from Vault at generation time, e.g. {"$vault": "secret/data/azure#client_secret"}
+const vaultReferenceKey = "$vault"
+
+// vaultTimeout bounds each request to Vault so an unreachable server fails generation instead of hanging it.
+const vaultTimeout = 10 * time.Second
+
+// VaultClient reads secrets over the Vault HTTP API.
+type VaultClient struct {
+	Address   string // Vault server, e.g. "https://vault.internal:8200"
+	Token     string
+	Namespace string // Vault Enterprise namespace; empty for the root namespace
+	HTTP      *http.Client
+	secrets   map[string]map[string]interface{} // Secrets already read, by path
+}
+
+// NewVaultClientFromEnv configures a client from VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE, falling back to the
+// token the vault CLI saves in ~/.vault-token.
+func NewVaultClientFromEnv() (*VaultClient, error) {
+	address := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if address == "" {
+		return nil, errors.New("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			saved, _ := os.ReadFile(filepath.Join(home, ".vault-token"))
+			token = strings.TrimSpace(string(saved))
+		}
+	}
+	if token == "" {
+		return nil, errors.New("VAULT_TOKEN is not set and there is no ~/.vault-token")
+	}
+	return &VaultClient{Address: address, Token: token, Namespace: os.Getenv("VAULT_NAMESPACE"), HTTP: &http.Client{Timeout: vaultTimeout}}, nil
+}
+
+// Lookup returns the value of key in the secret at path. Secrets in a KV version 2 engine are addressed through its
+// data/ path, e.g. secret/data/azure, and their fields are read from the version's data.
+func (c *VaultClient) Lookup(path, key string) (interface{}, error) {
+	secret, ok := c.secrets[path]
+	if !ok {
+		var err error
+		if secret, err = c.read(path); err != nil {
+			return nil, err
+		}
+		if c.secrets == nil {
+			c.secrets = make(map[string]map[string]interface{})
+		}
+		c.secrets[path] = secret
+	}
+	value, ok := secret[key]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in secret %s", key, path)
+	}
+	return value, nil
+}
+
+// read fetches the data of the secret at path
+func (c *VaultClient) read(path string) (map[string]interface{}, error) {
+	request, err := http.NewRequest(http.MethodGet, c.Address+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Vault-Token", c.Token)
+	if c.Namespace != "" {
+		request.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+
+	response, err := c.HTTP.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach Vault at %s: %w", c.Address, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading secret %s: %w", path, err)
+	}
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("no secret at %s", path)
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("permission denied reading secret %s", path)
+	default:
+		return nil, fmt.Errorf("unexpected %s from Vault reading secret %s", response.Status, path)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("error decoding secret %s: %w", path, err)
+	}
+	// KV version 2 nests the fields under data next to the version metadata
+	if fields, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, versioned := secret.Data["metadata"]; versioned {
+			return fields, nil
+		}
+	}
+	return secret.Data, nil
+}
+
+// ContainsVaultReferences reports whether raw configuration JSON mentions a $vault reference, so configurations
+// without one never need a Vault connection.
+func ContainsVaultReferences(content []byte) bool {
+	return strings.Contains(string(content), `"`+vaultReferenceKey+`"`)
+}
+
+// resolveVaultConfig returns raw configuration JSON with its $vault references resolved through the Vault configured
+// in the environment.
+func resolveVaultConfig(content []byte) ([]byte, error) {
+	client, err := NewVaultClientFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("the configuration reads values from Vault: %w", err)
+	}
+
+	// Numbers are kept as written so re-encoding cannot change them
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	markVaultVariablesSensitive(raw)
+	resolved, err := ResolveVaultReferences(raw, client)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resolved)
+}
+
+// markVaultVariablesSensitive marks every variable, of the configuration or of a module, whose default, value or
+// other settings read from Vault as sensitive, so its secrets are redacted wherever variables are logged and
+// Terraform hides them in plan output.
+func markVaultVariablesSensitive(raw interface{}) {
+	root, _ := raw.(map[string]interface{})
+	variableSets := []interface{}{root["variables"]}
+	modules, _ := root["modules"].([]interface{})
+	for _, module := range modules {
+		if module, ok := module.(map[string]interface{}); ok {
+			variableSets = append(variableSets, module["variables"])
+		}
+	}
+	for _, set := range variableSets {
+		variables, _ := set.(map[string]interface{})
+		for _, variable := range variables {
+			if variable, ok := variable.(map[string]interface{}); ok && hasVaultReference(variable) {
+				variable["sensitive"] = true
+			}
+		}
+	}
+}
+
+// hasVaultReference reports whether a decoded JSON value holds a $vault reference at any depth
+func hasVaultReference(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, ok := v[vaultReferenceKey]; ok && len(v) == 1 {
+			return true
+		}
+		for _, item := range v {
+			if hasVaultReference(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if hasVaultReference(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ResolveVaultReferences replaces {"$vault": "<path>#<key>"} objects found at any depth in decoded configuration
+// JSON with the value of key in the secret at path. Errors name the configuration path of the reference.
+func ResolveVaultReferences(value interface{}, client *VaultClient) (interface{}, error) {
+	return resolveVaultValue(value, client, "")
+}
+
+// resolveVaultValue resolves the references in value, found at scope
+func resolveVaultValue(value interface{}, client *VaultClient, scope string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if reference, ok := v[vaultReferenceKey]; ok && len(v) == 1 {
+			resolved, err := lookupVaultReference(reference, client)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", strings.TrimPrefix(scope, "."), err)
+			}
+			return resolved, nil
+		}
+		for _, key := range sortedKeys(v) {
+			resolved, err := resolveVaultValue(v[key], client, scope+"."+key)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			resolved, err := resolveVaultValue(item, client, fmt.Sprintf("%s[%d]", scope, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+// lookupVaultReference reads the secret value a $vault reference names
+func lookupVaultReference(reference interface{}, client *VaultClient) (interface{}, error) {
+	location, _ := reference.(string)
+	path, key, ok := strings.Cut(location, "#")
+	if !ok || strings.TrimSpace(path) == "" || key == "" {
+		return nil, fmt.Errorf("%s must be a secret path and key such as \"secret/data/azure#client_secret\", got %v", vaultReferenceKey, reference)
+	}
+	value, err := client.Lookup(path, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %w", vaultReferenceKey, location, err)
+	}
+	return value, nil
+}
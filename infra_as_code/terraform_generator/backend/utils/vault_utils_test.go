@@ -0,0 +1,78 @@
+// backend/utils/vault_utils_test.go
+
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigVaultReferences(t *testing.T) {
+	reads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.test" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/azure":
+			reads++
+			w.Write([]byte(`{"data": {"data": {"subscription_id": "sub-123", "client_secret": "hunter2"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/azure":
+			w.Write([]byte(`{"data": {"tenant_id": "tenant-456"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "s.test")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "terraform-generator.json")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"backend": {"subscription_id": {"$vault": "secret/data/azure#subscription_id"}, "tenant_id": {"$vault": "kv/azure#tenant_id"}},
+		"variables": {"secret": {"type": "string", "default": {"$vault": "secret/data/azure#client_secret"}}, "location": {"type": "string", "default": "eastus"}},
+		"modules": [{"module_name": "aks", "variables": {"client_secret": {"type": "string", "value": {"default": {"$vault": "secret/data/azure#client_secret"}}}}}]}`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if config.Backend.SubscriptionId != "sub-123" || config.Backend.TenantID != "tenant-456" || config.Variables["secret"].Default != "hunter2" {
+		t.Fatalf("LoadConfig() did not resolve the references: %+v", config)
+	}
+
+	// Variables holding a secret are sensitive and redacted when logged; the others are untouched
+	moduleSecret := config.Modules[0].Variables["client_secret"]
+	if !config.Variables["secret"].Sensitive || !moduleSecret.Sensitive || config.Variables["location"].Sensitive {
+		t.Fatalf("sensitive = %v, %v, %v; want only the Vault variables", config.Variables["secret"].Sensitive, moduleSecret.Sensitive, config.Variables["location"].Sensitive)
+	}
+	redacted, _ := json.Marshal(RedactTemplateData(map[string]interface{}{"Variables": config.Variables, "Modules": config.Modules}))
+	if strings.Contains(string(redacted), "hunter2") {
+		t.Fatalf("redacted template data contains the secret: %s", redacted)
+	}
+	if reads != 1 {
+		t.Fatalf("secret/data/azure read %d times, want once", reads)
+	}
+
+	write(`{"backend": {"subscription_id": {"$vault": "secret/data/azure#missing"}}}`)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), `backend.subscription_id: $vault "secret/data/azure#missing": key "missing" not found`) {
+		t.Fatalf("LoadConfig() error = %v, want the missing key named", err)
+	}
+
+	t.Setenv("VAULT_ADDR", "http://127.0.0.1:1")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "cannot reach Vault") {
+		t.Fatalf("LoadConfig() error = %v, want Vault unreachable", err)
+	}
+}